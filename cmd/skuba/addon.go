@@ -33,6 +33,9 @@ func NewAddonCmd() *cobra.Command {
 	cmd.AddCommand(
 		addons.NewRefreshCmd(),
 		addons.NewUpgradeCmd(),
+		addons.NewEnableCmd(),
+		addons.NewDisableCmd(),
+		addons.NewDiffCmd(),
 	)
 
 	return cmd
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+	"github.com/SUSE/skuba/pkg/skuba/actions/addon/toggle"
+)
+
+// NewEnableCmd creates a new `skuba addon enable` cobra command
+func NewEnableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "enable <addon-name>",
+		Short: "Enables and deploys a disabled addon",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return toggle.Enable(clientSet, args[0])
+			})
+			if err != nil {
+				fmt.Printf("Unable to enable addon: %s\n", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.ExactArgs(1),
+	}
+}
+
+// NewDisableCmd creates a new `skuba addon disable` cobra command
+func NewDisableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "disable <addon-name>",
+		Short: "Deletes an addon from the cluster and stops deploying and upgrading it",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return toggle.Disable(clientSet, args[0])
+			})
+			if err != nil {
+				fmt.Printf("Unable to disable addon: %s\n", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.ExactArgs(1),
+	}
+}
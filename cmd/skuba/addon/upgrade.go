@@ -24,7 +24,9 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
+	skubaaddons "github.com/SUSE/skuba/internal/pkg/skuba/addons"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
 	addons "github.com/SUSE/skuba/pkg/skuba/actions/addon/upgrade"
 )
 
@@ -44,7 +46,9 @@ func NewUpgradeCmd() *cobra.Command {
 }
 
 func newUpgradePlanCmd() *cobra.Command {
-	return &cobra.Command{
+	var showDiff bool
+
+	cmd := &cobra.Command{
 		Use:   "plan",
 		Short: "Plan addon upgrade",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -53,17 +57,23 @@ func newUpgradePlanCmd() *cobra.Command {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := addons.Plan(clientSet); err != nil {
+			if err := addons.Plan(clientSet, showDiff); err != nil {
 				fmt.Printf("Unable to plan addon upgrade: %s\n", err)
 				os.Exit(1)
 			}
 		},
 		Args: cobra.NoArgs,
 	}
+
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Print the changes the upgrade makes to the objects of the cluster")
+
+	return cmd
 }
 
 func newUpgradeApplyCmd() *cobra.Command {
-	return &cobra.Command{
+	applyOptions := skubaaddons.ApplyOptions{}
+
+	cmd := &cobra.Command{
 		Use:   "apply",
 		Short: "Apply addon upgrade",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -72,11 +82,19 @@ func newUpgradeApplyCmd() *cobra.Command {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := addons.Apply(clientSet); err != nil {
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return addons.Apply(clientSet, applyOptions)
+			})
+			if err != nil {
 				fmt.Printf("Unable to Apply addons upgrade: %s\n", err)
 				os.Exit(1)
 			}
 		},
 		Args: cobra.NoArgs,
 	}
+
+	cmd.Flags().BoolVar(&applyOptions.NoPrune, "no-prune", false, "Do not delete the addon objects that are not in the addon manifests anymore")
+	cmd.Flags().DurationVar(&applyOptions.RolloutTimeout, "rollout-timeout", skubaaddons.DefaultRolloutTimeout, "Time to wait for the workloads of every addon to roll out before recording its version; 0 does not wait")
+
+	return cmd
 }
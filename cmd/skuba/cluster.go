@@ -33,8 +33,11 @@ func NewClusterCmd() *cobra.Command {
 	cmd.AddCommand(
 		cluster.NewInitCmd(),
 		cluster.NewStatusCmd(),
+		cluster.NewCheckCmd(),
+		cluster.NewEtcdCmd(),
 		cluster.NewUpgradeCmd(),
 		cluster.NewImagesCmd(),
+		cluster.NewUnlockCmd(),
 	)
 
 	return cmd
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	clientset "github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/check"
+)
+
+// NewCheckCmd creates a new `skuba cluster check` cobra command
+func NewCheckCmd() *cobra.Command {
+	checkOptions := cluster.CheckOptions{}
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the health of the cluster components",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := clientset.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+
+			if err := cluster.Check(clientSet, checkOptions); err != nil {
+				klog.Errorf("cluster check failed: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().DurationVar(&checkOptions.CertExpirationThreshold, "cert-expiration-threshold", cluster.DefaultCertExpirationThreshold, "Report certificates expiring within this duration as failed")
+	cmd.Flags().DurationVar(&checkOptions.EndpointTimeout, "endpoint-timeout", cluster.DefaultEndpointTimeout, "Time to wait for every API endpoint to answer")
+	return cmd
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+	"github.com/SUSE/skuba/pkg/skuba"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/etcd"
+	"github.com/SUSE/skuba/pkg/skuba/actions/node/join"
+)
+
+// NewEtcdCmd creates a new `skuba cluster etcd` cobra command
+func NewEtcdCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "etcd",
+		Short: "Manages the etcd cluster of the control plane",
+	}
+
+	cmd.AddCommand(
+		newEtcdBackupCmd(),
+		newEtcdRestoreCmd(),
+		newEtcdMembersCmd(),
+		newEtcdDefragCmd(),
+		newEtcdReconcileCmd(),
+	)
+
+	return cmd
+}
+
+func newEtcdBackupCmd() *cobra.Command {
+	backupOptions := cluster.BackupOptions{}
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Saves a snapshot of etcd in the cluster definition folder",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, config, err := kubernetes.GetAdminClientSetWithConfig()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			if _, err := cluster.Backup(clientSet, config, backupOptions); err != nil {
+				klog.Errorf("unable to back up etcd: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().StringVar(&backupOptions.Node, "node", "", "Control plane node taking the snapshot (default any ready control plane node)")
+	cmd.Flags().StringVar(&backupOptions.Dir, "dir", skuba.EtcdSnapshotsDir(), "Directory where the snapshot is saved")
+	cmd.Flags().StringVar(&backupOptions.Name, "name", "", "File name of the snapshot, without extension (default etcd-snapshot-<node>-<timestamp>)")
+	return cmd
+}
+
+func newEtcdRestoreCmd() *cobra.Command {
+	restoreOptions := cluster.RestoreOptions{}
+	inventory := ""
+	target := ssh.Target{}
+	cmd := &cobra.Command{
+		Use:   "restore <snapshot-file>",
+		Short: "Restores an etcd snapshot on all the control plane nodes",
+		Run: func(cmd *cobra.Command, args []string) {
+			restoreOptions.SnapshotPath = args[0]
+			nodes, err := join.LoadInventory(inventory)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			targets := []*deployments.Target{}
+			for _, node := range nodes.Nodes {
+				role, err := deployments.GetRoleFromString(node.Role)
+				if err != nil {
+					klog.Fatal(err)
+				}
+				if role != deployments.MasterRole {
+					continue
+				}
+				nodeTarget := target.ForNode(node.Address, node.User)
+				if err := nodeTarget.Validate(); err != nil {
+					klog.Fatalf("node %s: %s", node.Name, err)
+				}
+				targets = append(targets, nodeTarget.GetDeployment(node.Name, &role, flags.GetVerboseFlagLevel()))
+			}
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Fatalf("unable to get admin client set: %s", err)
+			}
+			restoreOptions.Command = cmd.CommandPath()
+			if err := cluster.Restore(clientSet, targets, restoreOptions, os.Stdin, os.Stdout); err != nil {
+				klog.Fatalf("unable to restore etcd snapshot: %s", err)
+			}
+		},
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().AddFlagSet(target.GetInventoryFlags())
+	cmd.Flags().StringVar(&inventory, "inventory", "", "Inventory file listing every control plane node of the cluster")
+	cmd.Flags().BoolVarP(&restoreOptions.AssumeYes, "yes", "y", false, "Do not ask for confirmation before stopping the control plane")
+	_ = cmd.MarkFlagRequired("inventory")
+	return cmd
+}
+
+func newEtcdMembersCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "members",
+		Short: "Lists the etcd members and the control plane nodes running them",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			if err := cluster.Members(clientSet, os.Stdout); err != nil {
+				klog.Errorf("unable to list etcd members: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+}
+
+func newEtcdDefragCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "defrag",
+		Short: "Defragments the etcd members one at a time, leader last",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return cluster.Defrag(clientSet, os.Stdout)
+			})
+			if err != nil {
+				klog.Errorf("unable to defragment etcd: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+}
+
+func newEtcdReconcileCmd() *cobra.Command {
+	reconcileOptions := cluster.ReconcileOptions{}
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Removes the etcd members that do not run on any control plane node",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return cluster.Reconcile(clientSet, reconcileOptions, os.Stdin, os.Stdout)
+			})
+			if err != nil {
+				klog.Errorf("unable to reconcile etcd members: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().BoolVarP(&reconcileOptions.AssumeYes, "yes", "y", false, "Do not ask for confirmation before removing stale members")
+	return cmd
+}
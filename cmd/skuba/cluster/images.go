@@ -18,25 +18,86 @@
 package cluster
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+	"github.com/SUSE/skuba/pkg/skuba"
 	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/images"
 )
 
 // NewImagesCmd creates a `skuba cluster images` cobra command
 func NewImagesCmd() *cobra.Command {
-	return &cobra.Command{
+	imagesOptions := cluster.ImagesOptions{}
+	cmd := &cobra.Command{
 		Use:   "images",
 		Short: "Show a list of images being used in the cluster",
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := cluster.Images(); err != nil {
+			if !cmd.Flags().Changed("image-repository") {
+				imagesOptions.ImageRepository = clusterImageRepository()
+			}
+			if err := cluster.Images(os.Stdout, imagesOptions); err != nil {
 				klog.Errorf("unable to get cluster images: %s", err)
 				os.Exit(1)
 			}
 		},
 		Args: cobra.NoArgs,
 	}
+
+	cmd.Flags().StringVarP(&imagesOptions.OutputFormat, "output", "o", cluster.OutputTable, fmt.Sprintf("Output format (%s)", strings.Join(cluster.OutputFormats, "|")))
+	cmd.Flags().StringVar(&imagesOptions.Version, "version", "", "Kubernetes version whose images are listed (default all the available versions)")
+	cmd.Flags().StringVar(&imagesOptions.Addon, "addon", "", "Only list the images of the addon")
+	cmd.Flags().BoolVar(&imagesOptions.ResolveDigests, "resolve-digests", false, "Resolve the digest of every image in its registry")
+	cmd.Flags().StringVar(&imagesOptions.CredentialsFile, "credentials-file", "", "Docker config.json file with the credentials of the registries the digests are resolved in (default the Docker configuration of the user)")
+	cmd.Flags().StringVar(&imagesOptions.ImageRepository, "image-repository", "", "List the images of this image repository when the cluster is not reachable (default the image repository of the cluster)")
+
+	cmd.AddCommand(newImagesMirrorCmd())
+
+	return cmd
+}
+
+// clusterImageRepository returns the image repository recorded in the
+// cluster, or the default image repository when the current folder is not a
+// cluster definition folder
+func clusterImageRepository() string {
+	if _, err := os.Stat(skuba.KubeConfigAdminFile()); err != nil {
+		return ""
+	}
+	client, err := kubernetes.GetAdminClientSet()
+	if err != nil {
+		klog.Warningf("unable to get admin client set, listing the images of the default image repository: %s", err)
+		return ""
+	}
+	imageRepository, err := skubaconfig.GetImageRepository(client)
+	if err != nil {
+		klog.Warningf("unable to get the image repository of the cluster, listing the images of the default image repository: %s", err)
+		return ""
+	}
+	return imageRepository
+}
+
+func newImagesMirrorCmd() *cobra.Command {
+	mirrorOptions := cluster.MirrorOptions{}
+	cmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "Copies the images used in the cluster to a registry or to an OCI image layout tarball",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := cluster.Mirror(mirrorOptions); err != nil {
+				klog.Errorf("unable to mirror cluster images: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().StringVar(&mirrorOptions.Registry, "to", "", "Registry the images are copied to, optionally followed by a repository prefix")
+	cmd.Flags().StringVar(&mirrorOptions.Version, "version", "", "Kubernetes version whose images are copied (default all the available versions)")
+	cmd.Flags().StringVar(&mirrorOptions.CredentialsFile, "credentials-file", "", "Docker config.json file with the credentials of the registries (default the Docker configuration of the user)")
+	cmd.Flags().StringVar(&mirrorOptions.OCILayout, "oci-layout", "", "Tarball the images are written to as an OCI image layout, instead of copying them to a registry")
+	cmd.Flags().StringVar(&mirrorOptions.FromOCILayout, "from-oci-layout", "", "OCI image layout tarball written with --oci-layout, whose images are copied to the registry")
+	return cmd
 }
@@ -33,6 +33,7 @@ type initOptions struct {
 	CloudProvider     string
 	StrictCapDefaults bool
 	CniPlugin         string
+	ImageRepository   string
 }
 
 // NewInitCmd creates a new `skuba cluster init` cobra command
@@ -49,7 +50,8 @@ func NewInitCmd() *cobra.Command {
 				initOptions.ControlPlane,
 				initOptions.KubernetesVersion,
 				initOptions.StrictCapDefaults,
-				initOptions.CniPlugin)
+				initOptions.CniPlugin,
+				initOptions.ImageRepository)
 			if err != nil {
 				klog.Fatalf("init failed due to error: %s", err)
 			}
@@ -79,5 +81,7 @@ func NewInitCmd() *cobra.Command {
 
 	cmd.Flags().StringVar(&initOptions.CniPlugin, "cni-plugin", "cilium", "Specify the CNI plugin to be used across the cluster. Valid values: cilium")
 
+	cmd.Flags().StringVar(&initOptions.ImageRepository, "image-repository", "", "Container image registry replacing the SUSE registry for every image of the cluster, e.g. a mirror filled by 'skuba cluster images mirror'")
+
 	return cmd
 }
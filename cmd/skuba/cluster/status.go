@@ -18,7 +18,9 @@
 package cluster
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
@@ -29,7 +31,8 @@ import (
 
 // NewStatusCmd creates a new `skuba cluster status` cobra command
 func NewStatusCmd() *cobra.Command {
-	return &cobra.Command{
+	outputFormat := cluster.OutputTable
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show cluster status",
 		Run: func(cmd *cobra.Command, args []string) {
@@ -39,11 +42,13 @@ func NewStatusCmd() *cobra.Command {
 				os.Exit(1)
 			}
 
-			if err := cluster.Status(clientSet); err != nil {
+			if err := cluster.Status(clientSet, outputFormat); err != nil {
 				klog.Errorf("unable to get cluster status: %s", err)
 				os.Exit(1)
 			}
 		},
 		Args: cobra.NoArgs,
 	}
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", cluster.OutputTable, fmt.Sprintf("Output format (%s)", strings.Join(cluster.OutputFormats, "|")))
+	return cmd
 }
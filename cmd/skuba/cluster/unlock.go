@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	cluster "github.com/SUSE/skuba/pkg/skuba/actions/cluster/unlock"
+)
+
+// NewUnlockCmd creates a new `skuba cluster unlock` cobra command
+func NewUnlockCmd() *cobra.Command {
+	force := false
+	cmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "Removes the cluster lock left behind by an interrupted skuba command",
+		Run: func(cmd *cobra.Command, args []string) {
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			if err := cluster.Unlock(clientSet, force); err != nil {
+				klog.Errorf("unable to unlock the cluster: %s", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "Remove the cluster lock even though it is held by another skuba command")
+	return cmd
+}
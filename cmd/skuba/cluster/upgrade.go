@@ -21,12 +21,22 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
 	"github.com/SUSE/skuba/internal/pkg/skuba/upgrade/cluster"
+	"github.com/SUSE/skuba/pkg/skuba"
+	"github.com/SUSE/skuba/pkg/skuba/actions"
 	"github.com/SUSE/skuba/pkg/skuba/actions/cluster/upgrade"
+	"github.com/SUSE/skuba/pkg/skuba/actions/node/join"
+	nodeupgrade "github.com/SUSE/skuba/pkg/skuba/actions/node/upgrade"
 )
 
 // NewUpgradeCmd creates a new `skuba cluster upgrade` cobra command
@@ -38,6 +48,7 @@ func NewUpgradeCmd() *cobra.Command {
 
 	cmd.AddCommand(
 		newUpgradePlanCmd(),
+		newUpgradeApplyCmd(),
 		newUpgradeLocalConfigCmd(),
 	)
 
@@ -63,6 +74,77 @@ func newUpgradePlanCmd() *cobra.Command {
 	}
 }
 
+func newUpgradeApplyCmd() *cobra.Command {
+	target := ssh.Target{}
+	inventory := ""
+	applyOptions := upgrade.ApplyOptions{
+		NodeApplyOptions: nodeupgrade.ApplyOptions{
+			Drain: kubernetes.DefaultDrainOptions(nodeupgrade.DefaultDrainTimeout),
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Upgrades all the nodes of the cluster, one control plane at a time and workers in batches",
+		Run: func(cmd *cobra.Command, args []string) {
+			nodes, err := join.LoadInventory(inventory)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			inventoryNodes := map[string]join.InventoryNode{}
+			for _, node := range nodes.Nodes {
+				if err := target.ForNode(node.Address, node.User).Validate(); err != nil {
+					klog.Fatalf("node %s: %s", node.Name, err)
+				}
+				inventoryNodes[node.Name] = node
+			}
+			clientSet, config, err := kubernetes.GetAdminClientSetWithConfig()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			applyOptions.NodeApplyOptions.RestConfig = config
+			var results []upgrade.NodeUpgradeResult
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				results, err = upgrade.Apply(clientSet, applyOptions, func(nodeName string, role *deployments.Role) (*deployments.Target, error) {
+					node, ok := inventoryNodes[nodeName]
+					if !ok {
+						return nil, errors.Errorf("not found in inventory %s", inventory)
+					}
+					d := target.ForNode(node.Address, node.User).GetDeployment(nodeName, role, flags.GetVerboseFlagLevel())
+					journal, err := deployments.NewJournal(skuba.NodeJournalFile(d.Target, deployments.UpgradeOperation), deployments.UpgradeOperation, false)
+					if err != nil {
+						return nil, err
+					}
+					d.Journal = journal
+					return d, nil
+				})
+				return err
+			})
+			if results != nil {
+				fmt.Println()
+				upgrade.PrintApplySummary(os.Stdout, results)
+			}
+			if err != nil {
+				fmt.Printf("Unable to apply cluster upgrade: %s\n", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().AddFlagSet(target.GetInventoryFlags())
+	cmd.Flags().StringVar(&inventory, "inventory", "", "Inventory file with the address of every node of the cluster")
+	cmd.Flags().IntVar(&applyOptions.WorkerBatchSize, "worker-batch-size", upgrade.DefaultWorkerBatchSize, "Number of worker nodes upgraded at the same time")
+	cmd.Flags().DurationVar(&applyOptions.NodeHealthTimeout, "node-health-timeout", upgrade.DefaultNodeHealthTimeout, "Time to wait for an upgraded node to be ready and for its pods to be healthy")
+	cmd.Flags().BoolVar(&applyOptions.NodeApplyOptions.SkipEtcdSnapshot, "skip-etcd-snapshot", false, "Do not save an etcd snapshot before upgrading the first control plane node")
+	cmd.Flags().StringVar(&applyOptions.NodeApplyOptions.EtcdSnapshotDir, "etcd-snapshot-dir", skuba.EtcdSnapshotsDir(), "Directory where the etcd snapshot taken before upgrading the first control plane node is saved")
+	cmd.Flags().DurationVar(&applyOptions.NodeApplyOptions.Drain.Timeout, "drain-timeout", applyOptions.NodeApplyOptions.Drain.Timeout, "Time to wait for every node to drain before its upgrade; 0 waits indefinitely")
+	actions.AddDrainFlags(cmd, &applyOptions.NodeApplyOptions.Drain)
+	cmd.Flags().BoolVar(&applyOptions.NoAddonPrune, "no-prune", false, "Do not delete the addon objects that are not in the addon manifests anymore")
+	cmd.Flags().DurationVar(&applyOptions.AddonRolloutTimeout, "addon-rollout-timeout", addons.DefaultRolloutTimeout, "Time to wait for the workloads of every upgraded addon to roll out; 0 does not wait")
+	_ = cmd.MarkFlagRequired("inventory")
+	return cmd
+}
+
 func newUpgradeLocalConfigCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "localconfig",
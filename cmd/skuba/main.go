@@ -27,6 +27,7 @@ import (
 	"k8s.io/klog"
 
 	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
 	skubapkg "github.com/SUSE/skuba/pkg/skuba"
 )
 
@@ -34,6 +35,13 @@ func newRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		// grab the base filename if the binary file is link
 		Use: filepath.Base(os.Args[0]),
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// addons defined in the cluster definition folder, the commands
+			// deploying addons fail when they cannot be loaded
+			if err := addons.LoadExternalAddons(); err != nil {
+				klog.Warningf("unable to load addons: %s", err)
+			}
+		},
 	}
 
 	cmd.AddCommand(
@@ -34,6 +34,7 @@ func NewNodeCmd() *cobra.Command {
 		node.NewBootstrapCmd(),
 		node.NewJoinCmd(),
 		node.NewRemoveCmd(),
+		node.NewRegistriesCmd(),
 		node.NewUpgradeCmd(),
 	)
 
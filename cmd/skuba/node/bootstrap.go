@@ -21,7 +21,6 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
-	"github.com/SUSE/skuba/cmd/skuba/flags"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
 	"github.com/SUSE/skuba/pkg/skuba/actions"
@@ -31,6 +30,8 @@ import (
 
 type bootstrapOptions struct {
 	ignorePreflightErrors string
+	resume                bool
+	deployment            deploymentOptions
 }
 
 // NewBootstrapCmd creates a new `skuba node bootstrap` cobra command
@@ -45,13 +46,18 @@ func NewBootstrapCmd() *cobra.Command {
 			if err := validate.NodeName(nodenames[0]); err != nil {
 				klog.Fatal(err)
 			}
-
 			bootstrapConfiguration := deployments.BootstrapConfiguration{
 				KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": bootstrapOptions.ignorePreflightErrors},
 			}
 
 			role := deployments.MasterRole
-			d := target.GetDeployment(nodenames[0], &role, flags.GetVerboseFlagLevel())
+			d, err := getDeployment(cmd, &target, bootstrapOptions.deployment, nodenames[0], &role)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			if err := setJournal(d, deployments.BootstrapOperation, bootstrapOptions.resume); err != nil {
+				klog.Fatal(err)
+			}
 			if err := node.Bootstrap(bootstrapConfiguration, d); err != nil {
 				klog.Fatalf("error bootstrapping node: %s", err)
 			}
@@ -61,5 +67,7 @@ func NewBootstrapCmd() *cobra.Command {
 
 	cmd.Flags().AddFlagSet(target.GetFlags())
 	actions.AddCommonFlags(&cmd, &bootstrapOptions.ignorePreflightErrors)
+	actions.AddResumeFlag(&cmd, &bootstrapOptions.resume)
+	actions.AddDeploymentFlags(&cmd, &bootstrapOptions.deployment.local, &bootstrapOptions.deployment.dryRun)
 	return &cmd
 }
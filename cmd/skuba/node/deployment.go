@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package node
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/local"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+// deploymentOptions choose how the states are applied on a single node
+type deploymentOptions struct {
+	local  bool
+	dryRun bool
+}
+
+// getDeployment returns the deployment applying the states on the machine
+// skuba is running on with --local, or on the target reached using SSH
+func getDeployment(cmd *cobra.Command, target *ssh.Target, options deploymentOptions, nodename string, role *deployments.Role) (*deployments.Target, error) {
+	var d *deployments.Target
+	if options.local {
+		for _, name := range []string{"target", "user", "sudo", "port", "bastion", "bastion-user", "bastion-port"} {
+			if cmd.Flags().Changed(name) {
+				return nil, errors.Errorf("--%s cannot be used with --local, the states are applied without using SSH", name)
+			}
+		}
+		d = local.GetDeployment(nodename, role)
+	} else {
+		if err := target.Validate(); err != nil {
+			return nil, err
+		}
+		d = target.GetDeployment(nodename, role, flags.GetVerboseFlagLevel())
+	}
+	d.DryRun = options.dryRun
+	return d, nil
+}
+
+// setJournal attaches the journal of the operation on the node to the
+// deployment. A dry run without --resume neither skips nor records any state,
+// so it leaves the journal of a previous run untouched.
+func setJournal(d *deployments.Target, operation string, resume bool) error {
+	if d.DryRun && !resume {
+		return nil
+	}
+	journal, err := deployments.NewJournal(skuba.NodeJournalFile(d.Target, operation), operation, resume)
+	if err != nil {
+		return err
+	}
+	d.Journal = journal
+	return nil
+}
@@ -18,6 +18,7 @@
 package node
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -27,6 +28,7 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
 	"github.com/SUSE/skuba/pkg/skuba/actions"
 	node "github.com/SUSE/skuba/pkg/skuba/actions/node/join"
 	"github.com/SUSE/skuba/pkg/skuba/actions/validate"
@@ -35,6 +37,10 @@ import (
 type joinOptions struct {
 	role                  string
 	ignorePreflightErrors string
+	inventory             string
+	concurrency           int
+	resume                bool
+	deployment            deploymentOptions
 }
 
 // NewBootstrapCmd creates a new `skuba node join` cobra command
@@ -45,9 +51,20 @@ func NewJoinCmd() *cobra.Command {
 		Use:   "join <node-name>",
 		Short: "Joins a new node to the cluster",
 		Run: func(cmd *cobra.Command, nodenames []string) {
+			if joinOptions.inventory != "" {
+				if joinOptions.deployment.local {
+					klog.Fatal("--local cannot be used with --inventory, the nodes of the inventory are reached using SSH")
+				}
+				joinInventory(cmd.CommandPath(), joinOptions, &target)
+				return
+			}
+
 			if err := validate.NodeName(nodenames[0]); err != nil {
 				klog.Fatal(err)
 			}
+			if joinOptions.role == "" {
+				klog.Fatal(`required flag(s) "role" not set`)
+			}
 
 			joinConfiguration := deployments.JoinConfiguration{
 				KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": joinOptions.ignorePreflightErrors},
@@ -59,19 +76,77 @@ func NewJoinCmd() *cobra.Command {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := node.Join(clientSet, joinConfiguration, target.GetDeployment(nodenames[0], &joinConfiguration.Role, flags.GetVerboseFlagLevel())); err != nil {
+			d, err := getDeployment(cmd, &target, joinOptions.deployment, nodenames[0], &joinConfiguration.Role)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			if err := setJournal(d, deployments.JoinOperation, joinOptions.resume); err != nil {
+				klog.Fatal(err)
+			}
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return node.Join(clientSet, joinConfiguration, d)
+			})
+			if err != nil {
 				klog.Fatalf("error joining node %s: %s", nodenames[0], err)
 			}
 		},
-		Args: cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if joinOptions.inventory != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 	}
 
 	cmd.Flags().AddFlagSet(target.GetFlags())
-	cmd.Flags().StringVarP(&joinOptions.role, "role", "r", "", "Role that this node will have in the cluster (master|worker) (required)")
+	cmd.Flags().StringVarP(&joinOptions.role, "role", "r", "", "Role that this node will have in the cluster (master|worker) (required unless --inventory)")
+	cmd.Flags().StringVar(&joinOptions.inventory, "inventory", "", "Inventory file with the list of nodes to join, instead of a single node")
+	cmd.Flags().IntVar(&joinOptions.concurrency, "concurrency", 5, "Maximum number of nodes of the inventory joined at the same time")
 
 	actions.AddCommonFlags(cmd, &joinOptions.ignorePreflightErrors)
-
-	_ = cmd.MarkFlagRequired("role")
+	actions.AddResumeFlag(cmd, &joinOptions.resume)
+	actions.AddDeploymentFlags(cmd, &joinOptions.deployment.local, &joinOptions.deployment.dryRun)
 
 	return cmd
 }
+
+func joinInventory(command string, joinOptions joinOptions, target *ssh.Target) {
+	inventory, err := node.LoadInventory(joinOptions.inventory)
+	if err != nil {
+		klog.Fatal(err)
+	}
+	for _, inventoryNode := range inventory.Nodes {
+		if err := target.ForNode(inventoryNode.Address, inventoryNode.User).Validate(); err != nil {
+			klog.Fatalf("node %s: %s", inventoryNode.Name, err)
+		}
+	}
+
+	clientSet, err := kubernetes.GetAdminClientSet()
+	if err != nil {
+		klog.Errorf("unable to get admin client set: %s", err)
+		os.Exit(1)
+	}
+	options := node.InventoryJoinOptions{
+		Concurrency:      joinOptions.concurrency,
+		KubeadmExtraArgs: map[string]string{"ignore-preflight-errors": joinOptions.ignorePreflightErrors},
+	}
+	var results []node.InventoryJoinResult
+	err = lock.Run(clientSet, command, func() error {
+		results, err = node.JoinInventory(clientSet, inventory, options, func(inventoryNode node.InventoryNode, role *deployments.Role) (*deployments.Target, error) {
+			d := target.ForNode(inventoryNode.Address, inventoryNode.User).GetDeployment(inventoryNode.Name, role, flags.GetVerboseFlagLevel())
+			d.DryRun = joinOptions.deployment.dryRun
+			if err := setJournal(d, deployments.JoinOperation, joinOptions.resume); err != nil {
+				return nil, err
+			}
+			return d, nil
+		})
+		return err
+	})
+	if results != nil {
+		fmt.Println()
+		node.PrintInventorySummary(os.Stdout, results)
+	}
+	if err != nil {
+		klog.Fatalf("error joining nodes: %s", err)
+	}
+}
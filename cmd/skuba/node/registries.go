@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2019 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package node
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+	"github.com/SUSE/skuba/pkg/skuba/actions/node/join"
+	"github.com/SUSE/skuba/pkg/skuba/actions/node/registries"
+)
+
+// NewRegistriesCmd creates a new `skuba node registries` cobra command
+func NewRegistriesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registries",
+		Short: "Manages the container registries configuration of the nodes",
+	}
+
+	cmd.AddCommand(
+		newRegistriesApplyCmd(),
+	)
+
+	return cmd
+}
+
+func newRegistriesApplyCmd() *cobra.Command {
+	target := ssh.Target{}
+	all := false
+	inventory := ""
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Applies the registries configuration of the cluster definition folder and reloads crio",
+		Run: func(cmd *cobra.Command, args []string) {
+			if !all {
+				if err := target.Validate(); err != nil {
+					klog.Fatal(err)
+				}
+				clientSet, err := kubernetes.GetAdminClientSet()
+				if err != nil {
+					klog.Errorf("unable to get admin client set: %s", err)
+					os.Exit(1)
+				}
+				err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+					return registries.Apply(target.GetDeployment("", nil, flags.GetVerboseFlagLevel()))
+				})
+				if err != nil {
+					klog.Fatalf("error applying the registries configuration: %s", err)
+				}
+				return
+			}
+
+			if cmd.Flags().Changed("target") {
+				klog.Fatal("--target is not supported with --all, the nodes are read from the inventory")
+			}
+			if inventory == "" {
+				klog.Fatal("--inventory is required with --all")
+			}
+			nodes, err := join.LoadInventory(inventory)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			inventoryNodes := map[string]join.InventoryNode{}
+			for _, node := range nodes.Nodes {
+				if err := target.ForNode(node.Address, node.User).Validate(); err != nil {
+					klog.Fatalf("node %s: %s", node.Name, err)
+				}
+				inventoryNodes[node.Name] = node
+			}
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			var results []registries.NodeApplyResult
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				results, err = registries.ApplyAll(clientSet, func(nodeName string) (*deployments.Target, error) {
+					node, ok := inventoryNodes[nodeName]
+					if !ok {
+						return nil, errors.Errorf("not found in inventory %s", inventory)
+					}
+					return target.ForNode(node.Address, node.User).GetDeployment(nodeName, nil, flags.GetVerboseFlagLevel()), nil
+				})
+				return err
+			})
+			if results != nil {
+				fmt.Println()
+				registries.PrintApplySummary(os.Stdout, results)
+			}
+			if err != nil {
+				klog.Fatalf("error applying the registries configuration: %s", err)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().AddFlagSet(target.GetFlags())
+	cmd.Flags().BoolVar(&all, "all", false, "Apply the configuration on every node of the cluster, reached at the addresses of the inventory")
+	cmd.Flags().StringVar(&inventory, "inventory", "", "Inventory file with the address of every node of the cluster (required with --all)")
+	return cmd
+}
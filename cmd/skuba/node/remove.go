@@ -19,44 +19,45 @@ package node
 
 import (
 	"os"
-	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+	"github.com/SUSE/skuba/pkg/skuba/actions"
 	node "github.com/SUSE/skuba/pkg/skuba/actions/node/remove"
 )
 
-type removeOptions struct {
-	drainTimeout time.Duration
-}
-
 // NewRemoveCmd creates a new `skuba node remove` cobra command
 func NewRemoveCmd() *cobra.Command {
-	removeOptions := removeOptions{}
+	drainOptions := kubernetes.DefaultDrainOptions(0)
 	cmd := &cobra.Command{
 		Use:   "remove <node-name>",
 		Short: "Removes a node from the cluster",
 		Run: func(cmd *cobra.Command, nodenames []string) {
-			if removeOptions.drainTimeout < 0 {
+			if drainOptions.Timeout < 0 {
 				klog.Infof("the passed duration was negative and will be ignored")
-				removeOptions.drainTimeout = 0
+				drainOptions.Timeout = 0
 			}
 			clientSet, err := kubernetes.GetAdminClientSet()
 			if err != nil {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := node.Remove(clientSet, nodenames[0], removeOptions.drainTimeout); err != nil {
+			err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+				return node.Remove(clientSet, nodenames[0], drainOptions)
+			})
+			if err != nil {
 				klog.Fatalf("error removing node %s: %s", nodenames[0], err)
 			}
 		},
 		Args: cobra.ExactArgs(1),
 	}
-	cmd.Flags().DurationVar(&removeOptions.drainTimeout, "drain-timeout", 0, `Time to wait for the node to drain, before proceeding with node removal.
+	cmd.Flags().DurationVar(&drainOptions.Timeout, "drain-timeout", 0, `Time to wait for the node to drain, before proceeding with node removal.
 The time can be specified using abbreviations for units: e.g. 1h15m15s (Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h").
 Will wait indefinitely by default.`)
+	actions.AddDrainFlags(cmd, &drainOptions)
 
 	return cmd
 }
@@ -25,8 +25,12 @@ import (
 	"k8s.io/klog"
 
 	"github.com/SUSE/skuba/cmd/skuba/flags"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+	"github.com/SUSE/skuba/pkg/skuba"
+	"github.com/SUSE/skuba/pkg/skuba/actions"
 	"github.com/SUSE/skuba/pkg/skuba/actions/node/upgrade"
 )
 
@@ -40,6 +44,7 @@ func NewUpgradeCmd() *cobra.Command {
 	cmd.AddCommand(
 		newUpgradePlanCmd(),
 		newUpgradeApplyCmd(),
+		newUpgradeRollbackCmd(),
 	)
 
 	return cmd
@@ -66,16 +71,36 @@ func newUpgradePlanCmd() *cobra.Command {
 
 func newUpgradeApplyCmd() *cobra.Command {
 	target := ssh.Target{}
+	resume := false
+	deploymentOptions := deploymentOptions{}
+	applyOptions := upgrade.ApplyOptions{
+		Drain: kubernetes.DefaultDrainOptions(upgrade.DefaultDrainTimeout),
+	}
 	cmd := cobra.Command{
 		Use:   "apply",
 		Short: "Apply node upgrade",
 		Run: func(cmd *cobra.Command, args []string) {
-			clientSet, err := kubernetes.GetAdminClientSet()
+			d, err := getDeployment(cmd, &target, deploymentOptions, "", nil)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			clientSet, config, err := kubernetes.GetAdminClientSetWithConfig()
 			if err != nil {
 				klog.Errorf("unable to get admin client set: %s", err)
 				os.Exit(1)
 			}
-			if err := upgrade.Apply(clientSet, target.GetDeployment("", nil, flags.GetVerboseFlagLevel())); err != nil {
+			applyOptions.RestConfig = config
+			if err := setJournal(d, deployments.UpgradeOperation, resume); err != nil {
+				klog.Fatal(err)
+			}
+			if d.DryRun {
+				err = upgrade.Apply(clientSet, d, applyOptions)
+			} else {
+				err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+					return upgrade.Apply(clientSet, d, applyOptions)
+				})
+			}
+			if err != nil {
 				fmt.Printf("Unable to apply node upgrade: %s\n", err)
 				os.Exit(1)
 			}
@@ -83,5 +108,51 @@ func newUpgradeApplyCmd() *cobra.Command {
 		Args: cobra.NoArgs,
 	}
 	cmd.Flags().AddFlagSet(target.GetFlags())
+	cmd.Flags().BoolVar(&applyOptions.SkipEtcdSnapshot, "skip-etcd-snapshot", false, "Do not save an etcd snapshot before upgrading the first control plane node")
+	cmd.Flags().StringVar(&applyOptions.EtcdSnapshotDir, "etcd-snapshot-dir", skuba.EtcdSnapshotsDir(), "Directory where the etcd snapshot taken before upgrading the first control plane node is saved")
+	cmd.Flags().DurationVar(&applyOptions.Drain.Timeout, "drain-timeout", applyOptions.Drain.Timeout, "Time to wait for the node to drain before the upgrade; 0 waits indefinitely")
+	actions.AddDrainFlags(&cmd, &applyOptions.Drain)
+	actions.AddResumeFlag(&cmd, &resume)
+	actions.AddDeploymentFlags(&cmd, &deploymentOptions.local, &deploymentOptions.dryRun)
+	return &cmd
+}
+
+func newUpgradeRollbackCmd() *cobra.Command {
+	target := ssh.Target{}
+	cmd := cobra.Command{
+		Use:   "rollback",
+		Short: "Roll back a failed node upgrade",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := target.Validate(); err != nil {
+				klog.Fatal(err)
+			}
+			clientSet, err := kubernetes.GetAdminClientSet()
+			if err != nil {
+				klog.Errorf("unable to get admin client set: %s", err)
+				os.Exit(1)
+			}
+			d := target.GetDeployment("", nil, flags.GetVerboseFlagLevel())
+			// the upgrade journal no longer applies once the node is rolled
+			// back, so it is replaced
+			journal, err := deployments.NewJournal(skuba.NodeJournalFile(d.Target, deployments.UpgradeOperation), deployments.UpgradeOperation, false)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			d.Journal = journal
+			if d.DryRun {
+				err = upgrade.Rollback(clientSet, d)
+			} else {
+				err = lock.Run(clientSet, cmd.CommandPath(), func() error {
+					return upgrade.Rollback(clientSet, d)
+				})
+			}
+			if err != nil {
+				fmt.Printf("Unable to roll back node upgrade: %s\n", err)
+				os.Exit(1)
+			}
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().AddFlagSet(target.GetFlags())
 	return &cmd
 }
@@ -3,13 +3,14 @@ module github.com/SUSE/skuba
 require (
 	github.com/blang/semver v3.5.0+incompatible
 	github.com/coreos/go-oidc v2.1.0+incompatible
+	github.com/google/go-containerregistry v0.1.4
 	github.com/pkg/errors v0.9.1
 	github.com/pmezard/go-difflib v1.0.0
-	github.com/spf13/cobra v0.0.5
+	github.com/spf13/cobra v1.0.0
 	github.com/spf13/pflag v1.0.5
-	golang.org/x/crypto v0.0.0-20200220183623-bac4c82f6975
-	golang.org/x/net v0.0.0-20191004110552-13f9640d40b9
-	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
+	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0
+	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	k8s.io/api v0.18.10
 	k8s.io/apimachinery v0.18.10
 	k8s.io/client-go v0.18.10
@@ -17,6 +18,7 @@ require (
 	k8s.io/klog v1.0.0
 	k8s.io/kubectl v0.18.10
 	k8s.io/kubernetes v1.18.10
+	sigs.k8s.io/kustomize/api v0.6.5
 	sigs.k8s.io/yaml v1.2.0
 )
 
@@ -24,29 +24,26 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/version"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
 )
 
-type addonPriority uint
-
-const (
-	highPriority   addonPriority = iota
-	normalPriority addonPriority = iota
-)
-
 const (
 	addonTemplateWarning = `# Do not edit this file directly.
 #
@@ -88,9 +85,13 @@ type Addon struct {
 	templater          addonTemplater
 	preflightTemplater preflightAddonTemplater
 	callbacks          addonCallbacks
-	addonPriority      addonPriority
-	getImageCallbacks  []getImageCallback
-	AddOnType          AddOnType
+	// dependencies are the addons that are applied before this one
+	dependencies      []kubernetes.Addon
+	getImageCallbacks []getImageCallback
+	AddOnType         AddOnType
+	// external addons are defined in the cluster definition folder, which
+	// holds their manifests instead of skuba rendering them
+	external bool
 }
 
 type addonCallbacks interface {
@@ -102,11 +103,51 @@ type AddonConfiguration struct {
 	ClusterVersion *version.Version
 	ControlPlane   string
 	ClusterName    string
+	// ImageRepository is the custom image registry of the cluster, the
+	// images of skuba are used when it is empty
+	ImageRepository string
+}
+
+// NewAddonConfiguration returns the configuration the addons of a running
+// cluster are rendered with, for the given cluster version
+func NewAddonConfiguration(client clientset.Interface, clusterVersion *version.Version) (AddonConfiguration, error) {
+	clusterConfiguration, err := kubeadm.GetClusterConfiguration(client)
+	if err != nil {
+		return AddonConfiguration{}, errors.Wrap(err, "could not fetch cluster configuration")
+	}
+	imageRepository, err := skuba.GetImageRepository(client)
+	if err != nil {
+		return AddonConfiguration{}, err
+	}
+	return AddonConfiguration{
+		ClusterVersion:  clusterVersion,
+		ControlPlane:    clusterConfiguration.ControlPlaneEndpoint,
+		ClusterName:     clusterConfiguration.ClusterName,
+		ImageRepository: imageRepository,
+	}, nil
+}
+
+// ApplyOptions are the options of an addon apply
+type ApplyOptions struct {
+	// DryRun only reports the changes the apply makes to the cluster
+	DryRun bool
+	// NoPrune keeps the objects of the addon that are not in its manifest
+	// anymore, instead of deleting them
+	NoPrune bool
+	// RolloutTimeout is the time to wait for the Deployments, DaemonSets and
+	// StatefulSets of the addon to roll out before recording the addon
+	// version; they are not waited for when it is 0
+	RolloutTimeout time.Duration
+}
+
+// applyAddon applies one addon of DeployAddons
+var applyAddon = func(addon Addon, client clientset.Interface, addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration, options ApplyOptions) error {
+	return addon.Apply(client, addonConfiguration, skubaConfiguration, options)
 }
 
 type addonTemplater func(AddonConfiguration) string
 type preflightAddonTemplater func(AddonConfiguration) string
-type getImageCallback func(clusterVersion *version.Version, imageTag string) string
+type getImageCallback func(clusterVersion *version.Version, imageRepository, imageTag string) string
 
 type renderContext struct {
 	addon  Addon
@@ -127,35 +168,38 @@ func (renderContext renderContext) ManifestVersion() string {
 
 // registerAddon incorporates one addon information to the Addons map that keeps track of the
 // addons which will get deployed
-func registerAddon(addon kubernetes.Addon, addonType AddOnType, addonTemplater addonTemplater, preflightAddonTemplater preflightAddonTemplater, callbacks addonCallbacks, addonPriority addonPriority, getImageCallbacks []getImageCallback) {
+func registerAddon(addon kubernetes.Addon, addonType AddOnType, addonTemplater addonTemplater, preflightAddonTemplater preflightAddonTemplater, callbacks addonCallbacks, dependencies []kubernetes.Addon, getImageCallbacks []getImageCallback) {
 	Addons[addon] = Addon{
 		Addon:              addon,
 		templater:          addonTemplater,
 		preflightTemplater: preflightAddonTemplater,
 		callbacks:          callbacks,
-		addonPriority:      addonPriority,
+		dependencies:       dependencies,
 		getImageCallbacks:  getImageCallbacks,
 		AddOnType:          addonType,
 	}
 }
 
-// addonsByPriority sorts the addons in the Addons map by their priority set by the
-// addon.addonPriority uint and returns a slice
-func addonsByPriority() []Addon {
-	sortedAddons := make([]Addon, len(Addons))
-	i := 0
-	for _, addon := range Addons {
-		sortedAddons[i] = addon
-		i++
+// GetAddon returns the registered addon with the given name
+func GetAddon(addonName string) (Addon, error) {
+	addon, found := Addons[kubernetes.Addon(addonName)]
+	if !found {
+		available := []string{}
+		for name := range Addons {
+			available = append(available, string(name))
+		}
+		sort.Strings(available)
+		return Addon{}, errors.Errorf("unknown addon %q, available addons: %s", addonName, strings.Join(available, ", "))
 	}
-	sort.Slice(sortedAddons, func(i, j int) bool {
-		return sortedAddons[i].addonPriority < sortedAddons[j].addonPriority
-	})
-	return sortedAddons
+	return addon, nil
 }
 
 func CheckLocalAddonsBaseManifests(addonConfiguration AddonConfiguration) (bool, error) {
-	for _, addon := range addonsByPriority() {
+	addons, err := addonsInDependencyOrder()
+	if err != nil {
+		return false, err
+	}
+	for _, addon := range addons {
 		if !addon.IsPresentForClusterVersion(addonConfiguration.ClusterVersion) {
 			// This registered addon is not available on the chosen Kubernetes version, skip it
 			continue
@@ -167,38 +211,104 @@ func CheckLocalAddonsBaseManifests(addonConfiguration AddonConfiguration) (bool,
 	return true, nil
 }
 
-// DeployAddons loops over the sorted list of addons, checks if each needs to be deployed and
-// triggers its deployment
-func DeployAddons(client clientset.Interface, addonConfiguration AddonConfiguration, dryRun bool) error {
+// DeployAddons checks which addons need to be deployed and deploys them once
+// the addons they depend on are deployed, deploying the addons that do not
+// depend on each other in parallel. When an addon fails, the addons that
+// depend on it are skipped, and an *AddonsError reports all of them once the
+// rest are deployed.
+func DeployAddons(client clientset.Interface, addonConfiguration AddonConfiguration, options ApplyOptions) error {
+	if err := checkExternalAddons(); err != nil {
+		return err
+	}
 	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
 	if err != nil {
 		return err
 	}
-	for _, addon := range addonsByPriority() {
+	addons, err := addonsInDependencyOrder()
+	if err != nil {
+		return err
+	}
+	if err := pruneAddons(client, addonConfiguration, skubaConfiguration, options.DryRun); err != nil {
+		return err
+	}
+	addonsToApply := map[kubernetes.Addon]bool{}
+	for _, addon := range addons {
 		addonName := addon.Addon
 		if !addon.IsPresentForClusterVersion(addonConfiguration.ClusterVersion) {
 			// This registered addon is not available on the chosen Kubernetes version, skip it
 			continue
 		}
+		if skubaConfiguration.IsAddonDisabled(addonName) {
+			klog.V(1).Infof("skipping %q addon apply, it is disabled", addonName)
+			continue
+		}
 		hasToBeApplied, err := addon.HasToBeApplied(addonConfiguration, skubaConfiguration)
 		if err != nil {
 			klog.Errorf("cannot determine if %q addon needs to be applied, skipping...", addonName)
 			continue
 		}
-		if hasToBeApplied {
-			if err := addon.Apply(client, addonConfiguration, skubaConfiguration, dryRun); err == nil {
-				klog.V(1).Infof("%q addon correctly applied", addonName)
-			} else {
-				klog.Errorf("failed to apply %q addon (%v)", addonName, err)
-				return err
-			}
-		} else {
+		if !hasToBeApplied {
 			klog.V(1).Infof("skipping %q addon apply", addonName)
+			continue
+		}
+		addonsToApply[addonName] = true
+	}
+	return runInDependencyOrder(addons, func(addon Addon) error {
+		if !addonsToApply[addon.Addon] {
+			return nil
+		}
+		if err := applyAddon(addon, client, addonConfiguration, skubaConfiguration, options); err != nil {
+			klog.Errorf("failed to apply %q addon (%v)", addon.Addon, err)
+			return err
+		}
+		klog.V(1).Infof("%q addon correctly applied", addon.Addon)
+		return nil
+	})
+}
+
+// pruneAddons deletes the addons deployed in the cluster that are not
+// supported by the cluster version anymore
+func pruneAddons(client clientset.Interface, addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration, dryRun bool) error {
+	for _, addon := range prunedAddons(addonConfiguration, skubaConfiguration) {
+		klog.Infof("pruning %q addon, it is not supported on Kubernetes %s", addon.Addon, addonConfiguration.ClusterVersion)
+		if err := addon.Delete(client, skubaConfiguration, dryRun); err != nil {
+			klog.Errorf("failed to prune %q addon (%v)", addon.Addon, err)
+			return err
 		}
 	}
 	return nil
 }
 
+// prunedAddons returns the addons deployed in the cluster that are not
+// supported by the cluster version anymore, and whose manifest can be found
+// in the cluster definition folder
+func prunedAddons(addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration) []Addon {
+	deployedAddons := []kubernetes.Addon{}
+	for addonName := range skubaConfiguration.AddonsVersion {
+		if kubernetes.AddonVersionForClusterVersion(addonName, addonConfiguration.ClusterVersion) == nil {
+			deployedAddons = append(deployedAddons, addonName)
+		}
+	}
+	sort.Slice(deployedAddons, func(i, j int) bool {
+		return deployedAddons[i] < deployedAddons[j]
+	})
+	addons := []Addon{}
+	for _, addonName := range deployedAddons {
+		addon, found := Addons[addonName]
+		if !found {
+			// the addon is not known anymore, but its manifests may still
+			// be in the cluster definition folder
+			addon = Addon{Addon: addonName, external: true}
+		}
+		if !addon.hasLocalManifests() {
+			klog.Warningf("cannot prune %q addon, it is not supported on Kubernetes %s but its manifest is not in the cluster definition folder", addonName, addonConfiguration.ClusterVersion)
+			continue
+		}
+		addons = append(addons, addon)
+	}
+	return addons
+}
+
 func (addon Addon) renderTemplate(template *template.Template, addonConfiguration AddonConfiguration) (string, error) {
 	var rendered bytes.Buffer
 	if err := template.Execute(&rendered, renderContext{
@@ -233,19 +343,38 @@ func (addon Addon) IsPresentForClusterVersion(clusterVersion *version.Version) b
 	return kubernetes.AddonVersionForClusterVersion(addon.Addon, clusterVersion) != nil
 }
 
+// IsRequired returns whether the cluster cannot work without the Addon, so it
+// cannot be disabled
+func (addon Addon) IsRequired() bool {
+	return addon.AddOnType == CniAddOn || addon.Addon == kubernetes.PSP
+}
+
+// DependsOn returns whether the Addon is applied after the given addon, so it
+// does not work without it
+func (addon Addon) DependsOn(dependency kubernetes.Addon) bool {
+	for _, addonDependency := range addon.dependencies {
+		if addonDependency == dependency {
+			return true
+		}
+	}
+	return false
+}
+
 // HasToBeApplied decides if the Addon is deployed by checking its version with addonVersionLower
 func (addon Addon) HasToBeApplied(addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration) (bool, error) {
 	if !addon.IsPresentForClusterVersion(addonConfiguration.ClusterVersion) {
-		// TODO (ereslibre): this logic can be triggered if some registered
-		//       addons are not supported in all Kubernetes versions. Either:
+		// This logic can be triggered if some registered addons are not
+		// supported in all Kubernetes versions, either:
 		//
 		//   a) When rendering all addons on `skuba cluster init`, we skip those
 		//      that don't apply to the chosen Kubernetes version.
 		//
-		//   b) When running `skuba addon upgrade apply`; in this case (hence the
-		//      TODO), should we trigger a deletion of the addons that are not present
-		//      in the new version but were present on the old Kubernetes version? For
-		//      now, just return that it doesn't have to be applied.
+		//   b) When running `skuba addon upgrade apply`, where the addons that
+		//      were present on the old Kubernetes version are pruned by
+		//      DeployAddons.
+		return false, nil
+	}
+	if skubaConfiguration.IsAddonDisabled(addon.Addon) {
 		return false, nil
 	}
 	// Check whether this is a CNI addon and whether its base config has been rendered.
@@ -282,10 +411,6 @@ func (addon Addon) manifestFilename() string {
 	return fmt.Sprintf("%s.yaml", addon.Addon)
 }
 
-func (addon Addon) preflightManifestFilename() string {
-	return fmt.Sprintf("%s-preflight.yaml", addon.Addon)
-}
-
 func (addon Addon) legacyManifestPath(rootDir string) string {
 	return filepath.Join(rootDir, addon.manifestFilename())
 }
@@ -294,10 +419,6 @@ func (addon Addon) manifestPath(rootDir string) string {
 	return filepath.Join(addon.baseResourcesDir(rootDir), addon.manifestFilename())
 }
 
-func (addon Addon) preflightManifestPath(rootDir string) string {
-	return filepath.Join(addon.baseResourcesDir(rootDir), addon.preflightManifestFilename())
-}
-
 func (addon Addon) kustomizeContents(resourceManifests []string, patchManifests []string) (string, error) {
 	template, err := template.New("").Parse(kustomizeTemplate)
 	if err != nil {
@@ -322,6 +443,10 @@ func (addon Addon) kustomizePath(rootDir string) string {
 }
 
 func (addon Addon) compareLocalBaseManifest(addonConfiguration AddonConfiguration) (bool, error) {
+	if addon.external {
+		// the manifests are not rendered by skuba
+		return true, nil
+	}
 	if f, err := os.Stat(addon.legacyManifestPath(addon.addonDir())); !os.IsNotExist(err) && !f.IsDir() {
 		return false, nil
 	}
@@ -342,8 +467,12 @@ func (addon Addon) compareLocalBaseManifest(addonConfiguration AddonConfiguratio
 	return true, nil
 }
 
-// Write creates the manifest yaml file of the Addon after rendering its template
+// Write creates the manifest yaml file of the Addon after rendering its
+// template. External addons are left untouched.
 func (addon Addon) Write(addonConfiguration AddonConfiguration) error {
+	if addon.external {
+		return nil
+	}
 	addonManifest, err := addon.Render(addonConfiguration)
 	if err != nil {
 		return errors.Wrapf(err, "unable to render %s addon template", addon.Addon)
@@ -372,62 +501,46 @@ func (addon Addon) Write(addonConfiguration AddonConfiguration) error {
 }
 
 // applyPreflight applies the preflight deployment/daemonset manifest if such
-// is defined by the addon. It returns a bool whether preflight was defined
-// by the addon and an error.
-func (addon Addon) applyPreflight(addonConfiguration AddonConfiguration, rootDir string, dryRun bool) (bool, error) {
+// is defined by the addon. It returns the applied preflight objects, which are
+// empty when the addon does not define a preflight manifest.
+func (addon Addon) applyPreflight(applier *objectApplier, addonConfiguration AddonConfiguration, dryRun bool) ([]*unstructured.Unstructured, error) {
 	renderedPreflightManifest, err := addon.RenderPreflight(addonConfiguration)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	if renderedPreflightManifest == "" {
-		return false, nil
-	}
-	preflightManifestPath := addon.preflightManifestPath(rootDir)
-	if err := ioutil.WriteFile(preflightManifestPath, []byte(renderedPreflightManifest), 0600); err != nil {
-		return true, errors.Wrapf(err, "could not create %q addon manifests", addon.Addon)
+		return nil, nil
 	}
-	kubectlArgs := []string{
-		"apply",
-		"--kubeconfig", skubaconstants.KubeConfigAdminFile(),
-		"-f", preflightManifestPath,
-	}
-	if dryRun {
-		flag, err := kubectlServerSideDryRunFlag()
-		if err != nil {
-			return true, err
-		}
-		kubectlArgs = append(kubectlArgs, flag)
+	preflightObjects, err := decodeManifest(renderedPreflightManifest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decode %q addon preflight manifest", addon.Addon)
 	}
-	cmd := exec.Command("kubectl", kubectlArgs...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		// prints out stderr
-		fmt.Printf("%s", stderr.Bytes())
-		return true, err
+	if err := applier.apply(preflightObjects, dryRun); err != nil {
+		return nil, errors.Wrapf(err, "could not apply %q addon preflight manifest", addon.Addon)
 	}
-	return true, nil
+	return preflightObjects, nil
 }
 
-func (addon Addon) deletePreflight(rootDir string) error {
-	preflightManifestPath := addon.preflightManifestPath(rootDir)
-	cmd := exec.Command("kubectl", "delete", "--kubeconfig", skubaconstants.KubeConfigAdminFile(), "-f", preflightManifestPath)
-	if combinedOutput, err := cmd.CombinedOutput(); err != nil {
-		klog.Errorf("failed to run kubectl delete: %s", combinedOutput)
+// Apply deploys the addon by server-side applying the objects of its
+// manifest, built with the patches of the cluster definition folder. The
+// applied objects are labeled with the addon name, and unless
+// options.NoPrune is set, the labeled objects that are not in the manifest
+// anymore are deleted afterwards. The addon version is recorded in the
+// skuba-config ConfigMap once its workloads rolled out.
+func (addon Addon) Apply(client clientset.Interface, addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration, options ApplyOptions) error {
+	if err := checkExternalAddons(); err != nil {
 		return err
 	}
-	return nil
-}
-
-// Apply deploys the addon by calling kubectl apply and pointing to the generated addon
-// manifest
-func (addon Addon) Apply(client clientset.Interface, addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration, dryRun bool) error {
 	klog.V(1).Infof("applying %q addon", addon.Addon)
+	dryRun := options.DryRun
 
-	var err error
-	hasPreflight := false
+	applier, err := newObjectApplier()
+	if err != nil {
+		return err
+	}
+	var preflightObjects []*unstructured.Unstructured
 	if addon.preflightTemplater != nil {
-		hasPreflight, err = addon.applyPreflight(addonConfiguration, addon.addonDir(), dryRun)
+		preflightObjects, err = addon.applyPreflight(applier, addonConfiguration, dryRun)
 		if err != nil {
 			return err
 		}
@@ -438,43 +551,32 @@ func (addon Addon) Apply(client clientset.Interface, addonConfiguration AddonCon
 			return err
 		}
 	}
-	if hasPreflight && !dryRun {
+	if len(preflightObjects) > 0 && !dryRun {
 		// since we did not run applyPreflight if dryRun=true
 		// bypass delete preflight manifests
-		if err = addon.deletePreflight(addon.addonDir()); err != nil {
-			return err
+		if err = applier.delete(preflightObjects, false); err != nil {
+			return errors.Wrapf(err, "could not delete %q addon preflight objects", addon.Addon)
 		}
 	}
-	patchList, err := addon.listPatches()
-	if err != nil {
-		return errors.Wrapf(err, "could not list patches for %q addon", addon.Addon)
+	if err := addon.writeKustomization(); err != nil {
+		return err
 	}
-	kustomizeContents, err := addon.kustomizeContents([]string{addon.manifestFilename()}, patchList)
+	objects, err := buildKustomization(addon.addonDir())
 	if err != nil {
-		return errors.Wrapf(err, "could not render kustomize file")
-	}
-	if err = ioutil.WriteFile(addon.kustomizePath(addon.addonDir()), []byte(kustomizeContents), 0600); err != nil {
-		return errors.Wrapf(err, "could not create %q kustomize file", addon.Addon)
+		return err
 	}
-	kubectlArgs := []string{
-		"apply",
-		"--kubeconfig", skubaconstants.KubeConfigAdminFile(),
-		"-k", addon.addonDir(),
+	setAddonLabel(objects, addon.Addon)
+	if err := applier.apply(objects, dryRun); err != nil {
+		return errors.Wrapf(err, "could not apply %q addon", addon.Addon)
 	}
-	if dryRun {
-		flag, err := kubectlServerSideDryRunFlag()
+	if !options.NoPrune {
+		pruned, err := applier.prune(addon.Addon, objects, dryRun)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "could not prune %q addon", addon.Addon)
+		}
+		for _, object := range pruned {
+			klog.Infof("pruned %s %s from %q addon, it is not in the addon manifest anymore", object.GetKind(), objectName(object.GetNamespace(), object.GetName()), addon.Addon)
 		}
-		kubectlArgs = append(kubectlArgs, flag)
-	}
-	cmd := exec.Command("kubectl", kubectlArgs...)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		// prints out stderr
-		fmt.Printf("%s", stderr.Bytes())
-		return err
 	}
 	if addon.callbacks != nil && !dryRun {
 		if err = addon.callbacks.afterApply(client, addonConfiguration, skubaConfiguration); err != nil {
@@ -488,14 +590,76 @@ func (addon Addon) Apply(client clientset.Interface, addonConfiguration AddonCon
 		// immediately return, do not update skuba-config ConfigMap
 		return nil
 	}
+	if options.RolloutTimeout > 0 {
+		if err := waitForRollout(client, objects, options.RolloutTimeout); err != nil {
+			return errors.Wrapf(err, "%q addon failed to roll out", addon.Addon)
+		}
+	}
 	return updateSkubaConfigMapWithAddonVersion(client, addon.Addon, addonConfiguration.ClusterVersion, skubaConfiguration)
 }
 
+// Delete deletes the objects of the addon manifest rendered in the cluster
+// definition folder, and removes the addon version from the skuba-config
+// ConfigMap
+func (addon Addon) Delete(client clientset.Interface, skubaConfiguration *skuba.SkubaConfiguration, dryRun bool) error {
+	klog.V(1).Infof("deleting %q addon", addon.Addon)
+
+	if !addon.hasLocalManifests() {
+		return errors.Errorf("could not find %q addon manifests in %s", addon.Addon, addon.baseResourcesDir(addon.addonDir()))
+	}
+	if err := addon.writeKustomization(); err != nil {
+		return err
+	}
+	objects, err := buildKustomization(addon.addonDir())
+	if err != nil {
+		return err
+	}
+	applier, err := newObjectApplier()
+	if err != nil {
+		return err
+	}
+	if err := applier.delete(objects, dryRun); err != nil {
+		return errors.Wrapf(err, "could not delete %q addon", addon.Addon)
+	}
+	// delete the objects of former manifests of the addon too
+	if _, err := applier.prune(addon.Addon, objects, dryRun); err != nil {
+		return errors.Wrapf(err, "could not delete %q addon", addon.Addon)
+	}
+
+	if dryRun {
+		// immediately return, do not update skuba-config ConfigMap
+		return nil
+	}
+	delete(skubaConfiguration.AddonsVersion, addon.Addon)
+	return skuba.UpdateSkubaConfiguration(client, skubaConfiguration)
+}
+
+// writeKustomization writes the kustomize file applying the patches of the
+// cluster definition folder to the addon manifest
+func (addon Addon) writeKustomization() error {
+	patchList, err := addon.listPatches()
+	if err != nil {
+		return errors.Wrapf(err, "could not list patches for %q addon", addon.Addon)
+	}
+	resourceManifests, err := addon.resourceManifests()
+	if err != nil {
+		return errors.Wrapf(err, "could not list manifests for %q addon", addon.Addon)
+	}
+	kustomizeContents, err := addon.kustomizeContents(resourceManifests, patchList)
+	if err != nil {
+		return errors.Wrapf(err, "could not render kustomize file")
+	}
+	if err = ioutil.WriteFile(addon.kustomizePath(addon.addonDir()), []byte(kustomizeContents), 0600); err != nil {
+		return errors.Wrapf(err, "could not create %q kustomize file", addon.Addon)
+	}
+	return nil
+}
+
 // Images returns the images required for this Addon to properly function
-func (addon Addon) Images(clusterVersion *version.Version, imageTag string) []string {
+func (addon Addon) Images(clusterVersion *version.Version, imageRepository, imageTag string) []string {
 	images := []string{}
 	for _, cb := range addon.getImageCallbacks {
-		image := cb(clusterVersion, imageTag)
+		image := cb(clusterVersion, imageRepository, imageTag)
 		if image != "" {
 			images = append(images, image)
 		}
@@ -524,9 +688,15 @@ func addonVersionLower(current *kubernetes.AddonVersion, updated *kubernetes.Add
 	return current.ManifestVersion < updated.ManifestVersion
 }
 
+// skubaConfigurationMutex serializes the updates of the skuba-config ConfigMap
+// of the addons applied in parallel
+var skubaConfigurationMutex sync.Mutex
+
 // updateSkubaConfigMapWithAddonVersion updates the general Skuba config to include the
 // information of the Addon which was deployed
 func updateSkubaConfigMapWithAddonVersion(client clientset.Interface, addon kubernetes.Addon, clusterVersion *version.Version, skubaConfiguration *skuba.SkubaConfiguration) error {
+	skubaConfigurationMutex.Lock()
+	defer skubaConfigurationMutex.Unlock()
 	addonVersion := kubernetes.AddonVersionForClusterVersion(addon, clusterVersion)
 	if skubaConfiguration.AddonsVersion == nil {
 		skubaConfiguration.AddonsVersion = map[kubernetes.Addon]*kubernetes.AddonVersion{}
@@ -534,24 +704,3 @@ func updateSkubaConfigMapWithAddonVersion(client clientset.Interface, addon kube
 	skubaConfiguration.AddonsVersion[addon] = addonVersion
 	return skuba.UpdateSkubaConfiguration(client, skubaConfiguration)
 }
-
-func kubectlServerSideDryRunFlag() (string, error) {
-	// If k8s client version < v1.18.0, the server-side dry-run flag is `--server-dry-run`
-	// If k8s client version >= v1.18.0, the server-side dry-run flag is `--dry-run=server`
-	cmd := exec.Command("kubectl", "version", "--client", "--short")
-	combinedOutput, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", err
-	}
-
-	var clientVersion string
-	_, err = fmt.Sscanf(string(combinedOutput), "Client Version: %s", &clientVersion)
-	if err != nil {
-		return "", err
-	}
-
-	if version.MustParseSemantic(clientVersion).LessThan(version.MustParseSemantic("v1.18.0")) {
-		return "--server-dry-run", nil
-	}
-	return "--dry-run=server", nil
-}
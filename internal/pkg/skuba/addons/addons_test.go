@@ -21,12 +21,52 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes/fake"
+
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
 )
 
+func TestNewAddonConfiguration(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: metav1.NamespaceSystem},
+		Data: map[string]string{
+			"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.18.10
+clusterName: my-cluster
+controlPlaneEndpoint: 10.0.0.1:6443
+`,
+		},
+	})
+	if err := skuba.SaveImageRepository(client, "registry.example.com/caasp"); err != nil {
+		t.Fatalf("could not record the image repository: %v", err)
+	}
+
+	clusterVersion := version.MustParseSemantic("v1.18.10")
+	addonConfiguration, err := NewAddonConfiguration(client, clusterVersion)
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	expected := AddonConfiguration{
+		ClusterVersion:  clusterVersion,
+		ControlPlane:    "10.0.0.1:6443",
+		ClusterName:     "my-cluster",
+		ImageRepository: "registry.example.com/caasp",
+	}
+	if !reflect.DeepEqual(addonConfiguration, expected) {
+		t.Errorf("got addon configuration %+v, expected %+v", addonConfiguration, expected)
+	}
+}
+
 func TestAddonLegacyManifestMigration(t *testing.T) {
 	pwd, err := os.Getwd()
 	if err != nil {
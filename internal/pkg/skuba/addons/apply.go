@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+// FieldManager is the server-side apply field manager owning the fields of
+// the addon objects
+const FieldManager = "skuba"
+
+// ObjectError is the error of an operation on one object of a manifest
+type ObjectError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e ObjectError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Kind, objectName(e.Namespace, e.Name), e.Err)
+}
+
+// ObjectErrors are the errors of the objects of a manifest that could not be
+// applied or deleted; the other objects of the manifest were handled
+type ObjectErrors struct {
+	Operation string
+	Errors    []ObjectError
+}
+
+func (e *ObjectErrors) Error() string {
+	lines := []string{fmt.Sprintf("could not %s %d objects:", e.Operation, len(e.Errors))}
+	for _, objectErr := range e.Errors {
+		lines = append(lines, fmt.Sprintf("  - %s", objectErr.Error()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// objectApplier applies and deletes manifest objects through the dynamic
+// client, resolving their resources with the discovery information
+type objectApplier struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+}
+
+// newObjectApplier returns an objectApplier for the cluster of the admin
+// kubeconfig
+var newObjectApplier = func() (*objectApplier, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", skubaconstants.KubeConfigAdminFile())
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create dynamic client")
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create discovery client")
+	}
+	return &objectApplier{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}, nil
+}
+
+// buildKustomization renders the objects of the kustomization in dir
+func buildKustomization(dir string) ([]*unstructured.Unstructured, error) {
+	resources, err := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), krusty.MakeDefaultOptions()).Run(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not build kustomization %s", dir)
+	}
+	rendered, err := resources.AsYaml()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not render kustomization %s", dir)
+	}
+	return decodeManifest(string(rendered))
+}
+
+// decodeManifest decodes the objects of a multi-document YAML manifest
+func decodeManifest(manifest string) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "could not decode manifest")
+		}
+		raw.Raw = bytes.TrimSpace(raw.Raw)
+		if len(raw.Raw) == 0 || bytes.Equal(raw.Raw, []byte("null")) {
+			// empty document
+			continue
+		}
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(raw.Raw); err != nil {
+			return nil, errors.Wrap(err, "could not decode manifest object")
+		}
+		if object.GetKind() == "" || object.GetName() == "" {
+			return nil, errors.Errorf("manifest object without kind or name: %v", object.Object)
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// resourceFor returns the client of the resource of the object
+func (a *objectApplier) resourceFor(object *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// the kind may be defined by a custom resource definition applied
+		// right before, refresh the discovery information once
+		if resettable, ok := a.mapper.(interface{ Reset() }); ok {
+			resettable.Reset()
+			mapping, err = a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return a.client.Resource(mapping.Resource), nil
+	}
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+		object.SetNamespace(namespace)
+	}
+	return a.client.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// apply applies the objects with server-side apply, in order. Every object
+// is tried, the failing ones are returned as ObjectErrors.
+func (a *objectApplier) apply(objects []*unstructured.Unstructured, dryRun bool) error {
+	force := true
+	options := metav1.PatchOptions{
+		FieldManager: FieldManager,
+		// take over the fields previously applied by kubectl
+		Force: &force,
+	}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	objectErrors := &ObjectErrors{Operation: "apply"}
+	for _, object := range objects {
+		resource, err := a.resourceFor(object)
+		if err == nil {
+			var data []byte
+			data, err = object.MarshalJSON()
+			if err == nil {
+				_, err = resource.Patch(context.TODO(), object.GetName(), types.ApplyPatchType, data, options)
+			}
+		}
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+		}
+	}
+	if len(objectErrors.Errors) > 0 {
+		return objectErrors
+	}
+	return nil
+}
+
+// delete deletes the objects in reverse order, ignoring the ones that do not
+// exist. Every object is tried, the failing ones are returned as
+// ObjectErrors.
+func (a *objectApplier) delete(objects []*unstructured.Unstructured, dryRun bool) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	options := metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	objectErrors := &ObjectErrors{Operation: "delete"}
+	for i := len(objects) - 1; i >= 0; i-- {
+		object := objects[i]
+		resource, err := a.resourceFor(object)
+		if meta.IsNoMatchError(err) {
+			// the kind is not served anymore, so neither is the object
+			continue
+		}
+		if err == nil {
+			err = resource.Delete(context.TODO(), object.GetName(), options)
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+		}
+	}
+	if len(objectErrors.Errors) > 0 {
+		return objectErrors
+	}
+	return nil
+}
+
+func newObjectError(object *unstructured.Unstructured, err error) ObjectError {
+	return ObjectError{
+		Kind:      object.GetKind(),
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		Err:       err,
+	}
+}
+
+func objectName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ktest "k8s.io/client-go/testing"
+)
+
+func TestDecodeManifest(t *testing.T) {
+	objects, err := decodeManifest(testManifest)
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	names := []string{}
+	for _, object := range objects {
+		names = append(names, object.GetKind()+"/"+object.GetName())
+	}
+	expected := []string{"ServiceAccount/kured", "ClusterRole/kured", "DaemonSet/kured"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("got objects %v, expected %v", names, expected)
+	}
+
+	if _, err := decodeManifest("apiVersion: v1\nkind: ConfigMap\n"); err == nil {
+		t.Error("error expected decoding an object without name, but no error reported")
+	}
+}
+
+func TestObjectApplierApply(t *testing.T) {
+	applier, client := newTestApplier()
+	patched := []string{}
+	client.PrependReactor("patch", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		patchAction := action.(ktest.PatchAction)
+		if patchAction.GetPatchType() != types.ApplyPatchType {
+			t.Errorf("server-side apply expected, got patch type %s", patchAction.GetPatchType())
+		}
+		patched = append(patched, patchAction.GetResource().Resource+" "+patchAction.GetNamespace()+"/"+patchAction.GetName())
+		if patchAction.GetResource().Resource == "clusterroles" {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}, "kured", errors.New("escalation"))
+		}
+		return true, nil, nil
+	})
+
+	objects, err := decodeManifest(testManifest + "---\napiVersion: example.com/v1\nkind: Unknown\nmetadata:\n  name: foo\n")
+	if err != nil {
+		t.Fatalf("error not expected decoding the manifest (%v)", err)
+	}
+	err = applier.apply(objects, false)
+
+	expected := []string{"serviceaccounts kube-system/kured", "clusterroles /kured", "daemonsets default/kured"}
+	if !reflect.DeepEqual(patched, expected) {
+		t.Errorf("got patched objects %v, expected %v", patched, expected)
+	}
+	objectErrors, ok := err.(*ObjectErrors)
+	if !ok {
+		t.Fatalf("object errors expected, got %v", err)
+	}
+	failed := []string{}
+	for _, objectErr := range objectErrors.Errors {
+		failed = append(failed, objectErr.Kind+" "+objectName(objectErr.Namespace, objectErr.Name))
+	}
+	if expected := []string{"ClusterRole kured", "Unknown foo"}; !reflect.DeepEqual(failed, expected) {
+		t.Errorf("got failed objects %v, expected %v", failed, expected)
+	}
+	if !strings.HasPrefix(err.Error(), "could not apply 2 objects:\n  - ClusterRole kured: ") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestObjectApplierDelete(t *testing.T) {
+	applier, client := newTestApplier()
+	deleted := []string{}
+	client.PrependReactor("delete", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		deleteAction := action.(ktest.DeleteAction)
+		deleted = append(deleted, deleteAction.GetResource().Resource)
+		if deleteAction.GetResource().Resource == "serviceaccounts" {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "serviceaccounts"}, deleteAction.GetName())
+		}
+		return true, nil, nil
+	})
+
+	objects, err := decodeManifest(testManifest)
+	if err != nil {
+		t.Fatalf("error not expected decoding the manifest (%v)", err)
+	}
+	if err := applier.delete(objects, true); err != nil {
+		t.Errorf("error not expected, but an error was reported (%v)", err)
+	}
+	if expected := []string{"daemonsets", "clusterroles", "serviceaccounts"}; !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("got deleted resources %v, expected %v", deleted, expected)
+	}
+}
+
+func TestBuildKustomization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skuba-kustomization")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"base/kured.yaml": testManifest,
+		"patches/replicas.yaml": `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: kured
+spec:
+  minReadySeconds: 10
+`,
+		"kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - base/kured.yaml
+patches:
+  - patches/replicas.yaml
+`,
+	}
+	for name, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700); err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	objects, err := buildKustomization(dir)
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("3 objects expected, got %d", len(objects))
+	}
+	for _, object := range objects {
+		if object.GetKind() != "DaemonSet" {
+			continue
+		}
+		if minReadySeconds, _, _ := unstructured.NestedInt64(object.Object, "spec", "minReadySeconds"); minReadySeconds != 10 {
+			t.Errorf("patch expected to be applied, got minReadySeconds %d", minReadySeconds)
+		}
+	}
+}
@@ -35,35 +35,35 @@ import (
 )
 
 func init() {
-	registerAddon(kubernetes.Cilium, CniAddOn, renderCiliumTemplate, renderCiliumPreflightTemplate, ciliumCallbacks{}, normalPriority, []getImageCallback{GetCiliumInitImage, GetCiliumOperatorImage, GetCiliumImage})
+	registerAddon(kubernetes.Cilium, CniAddOn, renderCiliumTemplate, renderCiliumPreflightTemplate, ciliumCallbacks{}, []kubernetes.Addon{kubernetes.PSP}, []getImageCallback{GetCiliumInitImage, GetCiliumOperatorImage, GetCiliumImage})
 }
 
-func GetCiliumInitImage(clusterVersion *version.Version, imageTag string) string {
+func GetCiliumInitImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
 	// A separate cilium-init image exists only for Cilium < 1.6.
 	if util.VersionCompare(imageTag, ">=1.6.0") {
 		return ""
 	}
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "cilium-init", imageTag)
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "cilium-init", imageTag)
 }
 
-func GetCiliumOperatorImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "cilium-operator", imageTag)
+func GetCiliumOperatorImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "cilium-operator", imageTag)
 }
 
-func GetCiliumImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "cilium", imageTag)
+func GetCiliumImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "cilium", imageTag)
 }
 
 func (renderContext renderContext) CiliumInitImage() string {
-	return GetCiliumInitImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, renderContext.config.ClusterVersion).Version)
+	return GetCiliumInitImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, renderContext.config.ClusterVersion).Version)
 }
 
 func (renderContext renderContext) CiliumOperatorImage() string {
-	return GetCiliumOperatorImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, renderContext.config.ClusterVersion).Version)
+	return GetCiliumOperatorImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, renderContext.config.ClusterVersion).Version)
 }
 
 func (renderContext renderContext) CiliumImage() string {
-	return GetCiliumImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, renderContext.config.ClusterVersion).Version)
+	return GetCiliumImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, renderContext.config.ClusterVersion).Version)
 }
 
 func renderCiliumTemplate(addonConfiguration AddonConfiguration) string {
@@ -58,10 +58,10 @@ func TestGetCiliumInitImage(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
 				var imageUri string
 				if tt.want != "" {
-					imageUri = fmt.Sprintf("%s/%s", img.ImageRepository(ver), tt.want)
+					imageUri = fmt.Sprintf("%s/%s", img.ImageRepository(ver, ""), tt.want)
 				}
 
-				if got := GetCiliumInitImage(ver, tt.imageTag); got != imageUri {
+				if got := GetCiliumInitImage(ver, "", tt.imageTag); got != imageUri {
 					t.Errorf("GetCiliumInitImage() = %v, want %v", got, tt.want)
 				}
 			})
@@ -91,9 +91,9 @@ func TestGetCiliumOperatorImage(t *testing.T) {
 		for _, tt := range tests {
 			tt := tt // Parallel testing
 			t.Run(tt.name, func(t *testing.T) {
-				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver), tt.want)
+				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver, ""), tt.want)
 
-				if got := GetCiliumOperatorImage(ver, tt.imageTag); got != imageUri {
+				if got := GetCiliumOperatorImage(ver, "", tt.imageTag); got != imageUri {
 					t.Errorf("GetCiliumOperatorImage() = %v, want %v", got, imageUri)
 				}
 			})
@@ -123,9 +123,9 @@ func TestGetCiliumImage(t *testing.T) {
 		for _, tt := range tests {
 			tt := tt // Parallel testing
 			t.Run(tt.name, func(t *testing.T) {
-				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver), tt.want)
+				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver, ""), tt.want)
 
-				if got := GetCiliumImage(ver, tt.imageTag); got != imageUri {
+				if got := GetCiliumImage(ver, "", tt.imageTag); got != imageUri {
 					t.Errorf("GetCiliumImage() = %v, want %v", got, tt.want)
 				}
 			})
@@ -149,7 +149,7 @@ func Test_renderContext_CiliumInitImage(t *testing.T) {
 					ClusterName:    "",
 				},
 			},
-			want: img.ImageRepository(ver) + "/cilium-init:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
+			want: img.ImageRepository(ver, "") + "/cilium-init:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
 		}
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.renderContext.CiliumInitImage()
@@ -187,7 +187,7 @@ func Test_renderContext_CiliumOperatorImage(t *testing.T) {
 					ClusterName:    "",
 				},
 			},
-			want: img.ImageRepository(ver) + "/cilium-operator:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
+			want: img.ImageRepository(ver, "") + "/cilium-operator:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
 		}
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.renderContext.CiliumOperatorImage()
@@ -220,7 +220,7 @@ func Test_renderContext_CiliumImage(t *testing.T) {
 					ClusterName:    "",
 				},
 			},
-			want: img.ImageRepository(ver) + "/cilium:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
+			want: img.ImageRepository(ver, "") + "/cilium:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
 		}
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.renderContext.CiliumImage()
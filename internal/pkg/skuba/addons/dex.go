@@ -31,15 +31,15 @@ import (
 )
 
 func init() {
-	registerAddon(kubernetes.Dex, GenericAddOn, renderDexTemplate, nil, dexCallbacks{}, normalPriority, []getImageCallback{GetDexImage})
+	registerAddon(kubernetes.Dex, GenericAddOn, renderDexTemplate, nil, dexCallbacks{}, []kubernetes.Addon{kubernetes.PSP, kubernetes.Cilium}, []getImageCallback{GetDexImage})
 }
 
-func GetDexImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "caasp-dex", imageTag)
+func GetDexImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "caasp-dex", imageTag)
 }
 
 func (renderContext renderContext) DexImage() string {
-	return GetDexImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Dex, renderContext.config.ClusterVersion).Version)
+	return GetDexImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Dex, renderContext.config.ClusterVersion).Version)
 }
 
 func renderDexTemplate(addonConfiguration AddonConfiguration) string {
@@ -51,9 +51,9 @@ func TestGetDexImage(t *testing.T) {
 		for _, tt := range tests {
 			tt := tt // Parallel testing
 			t.Run(tt.name, func(t *testing.T) {
-				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver), tt.want)
+				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver, ""), tt.want)
 
-				if got := GetDexImage(ver, tt.imageTag); got != imageUri {
+				if got := GetDexImage(ver, "", tt.imageTag); got != imageUri {
 					t.Errorf("GetDexImage() = %v, want %v", got, imageUri)
 				}
 			})
@@ -77,7 +77,7 @@ func Test_renderContext_DexImage(t *testing.T) {
 					ClusterName:    "",
 				},
 			},
-			want: img.ImageRepository(ver) + "/caasp-dex:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
+			want: img.ImageRepository(ver, "") + "/caasp-dex:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
 		}
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.renderContext.DexImage()
@@ -0,0 +1,290 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+// DiffAction is the change an addon apply makes to one object
+type DiffAction string
+
+const (
+	ObjectAdded   DiffAction = "added"
+	ObjectDeleted DiffAction = "deleted"
+	ObjectChanged DiffAction = "changed"
+)
+
+// noisyFields are the fields of the objects that are maintained by the
+// cluster, and are left out of the diffs
+var noisyFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "uid"},
+	{"metadata", "selfLink"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+	{"metadata", "annotations", "deprecated.daemonset.template.generation"},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+}
+
+// ObjectDiff is the unified diff between the live object and the object an
+// addon apply results in
+type ObjectDiff struct {
+	Addon     kubernetes.Addon
+	Kind      string
+	Namespace string
+	Name      string
+	Action    DiffAction
+	Diff      string
+}
+
+// PrintObjectDiffs prints the diffs of the objects
+func PrintObjectDiffs(diffs []ObjectDiff) {
+	for _, diff := range diffs {
+		fmt.Printf("%s %s (%s %s)\n", diff.Kind, objectName(diff.Namespace, diff.Name), diff.Addon, diff.Action)
+		fmt.Print(diff.Diff)
+	}
+}
+
+// DiffAddons returns the changes to the objects of the cluster that
+// DeployAddons would make
+func DiffAddons(client clientset.Interface, addonConfiguration AddonConfiguration) ([]ObjectDiff, error) {
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		return nil, err
+	}
+	addons, err := addonsInDependencyOrder()
+	if err != nil {
+		return nil, err
+	}
+	diffs := []ObjectDiff{}
+	for _, addon := range prunedAddons(addonConfiguration, skubaConfiguration) {
+		addonDiffs, err := addon.Diff(addonConfiguration, skubaConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, addonDiffs...)
+	}
+	for _, addon := range addons {
+		hasToBeApplied, err := addon.HasToBeApplied(addonConfiguration, skubaConfiguration)
+		if err != nil || !hasToBeApplied {
+			continue
+		}
+		addonDiffs, err := addon.Diff(addonConfiguration, skubaConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, addonDiffs...)
+	}
+	return diffs, nil
+}
+
+// Diff returns the changes to the objects of the cluster that applying the
+// addon manifest rendered in the cluster definition folder would make. The
+// objects labeled as belonging to the addon that are not in its manifest
+// anymore are reported as deleted, and so are all the objects of an addon
+// that is disabled or not supported by the cluster version.
+func (addon Addon) Diff(addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration) ([]ObjectDiff, error) {
+	if !addon.hasLocalManifests() {
+		return nil, errors.Errorf("could not find %q addon manifests in %s", addon.Addon, addon.baseResourcesDir(addon.addonDir()))
+	}
+	if err := addon.writeKustomization(); err != nil {
+		return nil, err
+	}
+	objects, err := buildKustomization(addon.addonDir())
+	if err != nil {
+		return nil, err
+	}
+	applier, err := newObjectApplier()
+	if err != nil {
+		return nil, err
+	}
+	var diffs []ObjectDiff
+	if !addon.IsPresentForClusterVersion(addonConfiguration.ClusterVersion) || skubaConfiguration.IsAddonDisabled(addon.Addon) {
+		diffs, err = applier.diffDeleted(objects)
+	} else {
+		setAddonLabel(objects, addon.Addon)
+		diffs, err = applier.diff(objects)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not diff %q addon", addon.Addon)
+	}
+	prunable, err := applier.prunable(addon.Addon, objects)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not diff %q addon", addon.Addon)
+	}
+	for _, object := range prunable {
+		diffs = append(diffs, newObjectDiff(object, ObjectDeleted, object, nil))
+	}
+	for i := range diffs {
+		diffs[i].Addon = addon.Addon
+	}
+	return diffs, nil
+}
+
+// get returns the live object, or nil when it does not exist
+func (a *objectApplier) get(object *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resource, err := a.resourceFor(object)
+	if meta.IsNoMatchError(err) {
+		// the kind is not served, so neither is the object
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	live, err := resource.Get(context.TODO(), object.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
+
+// diff compares the live objects with the result of a server-side dry run
+// apply of the objects. Unchanged objects are not reported.
+func (a *objectApplier) diff(objects []*unstructured.Unstructured) ([]ObjectDiff, error) {
+	force := true
+	options := metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+		DryRun:       []string{metav1.DryRunAll},
+	}
+	diffs := []ObjectDiff{}
+	objectErrors := &ObjectErrors{Operation: "diff"}
+	for _, object := range objects {
+		live, err := a.get(object)
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+			continue
+		}
+		if live == nil {
+			// the object namespace or kind may not exist yet, so the object
+			// cannot be dry run applied; report it as rendered
+			diffs = append(diffs, newObjectDiff(object, ObjectAdded, nil, object))
+			continue
+		}
+		data, err := object.MarshalJSON()
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+			continue
+		}
+		resource, err := a.resourceFor(object)
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+			continue
+		}
+		applied, err := resource.Patch(context.TODO(), object.GetName(), types.ApplyPatchType, data, options)
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+			continue
+		}
+		if diff := newObjectDiff(object, ObjectChanged, live, applied); diff.Diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	if len(objectErrors.Errors) > 0 {
+		return nil, objectErrors
+	}
+	return diffs, nil
+}
+
+// diffDeleted reports the live objects as deleted
+func (a *objectApplier) diffDeleted(objects []*unstructured.Unstructured) ([]ObjectDiff, error) {
+	diffs := []ObjectDiff{}
+	objectErrors := &ObjectErrors{Operation: "diff"}
+	for _, object := range objects {
+		live, err := a.get(object)
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, newObjectError(object, err))
+			continue
+		}
+		if live != nil {
+			diffs = append(diffs, newObjectDiff(object, ObjectDeleted, live, nil))
+		}
+	}
+	if len(objectErrors.Errors) > 0 {
+		return nil, objectErrors
+	}
+	return diffs, nil
+}
+
+// newObjectDiff returns the diff from one version of the object to another,
+// where a nil version is an object that does not exist. The diff is empty
+// when both versions match.
+func newObjectDiff(object *unstructured.Unstructured, action DiffAction, from, to *unstructured.Unstructured) ObjectDiff {
+	name := fmt.Sprintf("%s/%s", object.GetKind(), objectName(object.GetNamespace(), object.GetName()))
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(diffableObject(from)),
+		B:        splitLines(diffableObject(to)),
+		FromFile: "live/" + name,
+		ToFile:   "applied/" + name,
+		Context:  3,
+	})
+	return ObjectDiff{
+		Kind:      object.GetKind(),
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		Action:    action,
+		Diff:      diff,
+	}
+}
+
+// diffableObject returns the YAML of the object without its noisy fields
+func diffableObject(object *unstructured.Unstructured) string {
+	if object == nil {
+		return ""
+	}
+	object = object.DeepCopy()
+	for _, field := range noisyFields {
+		unstructured.RemoveNestedField(object.Object, field...)
+	}
+	if annotations, found, _ := unstructured.NestedMap(object.Object, "metadata", "annotations"); found && len(annotations) == 0 {
+		unstructured.RemoveNestedField(object.Object, "metadata", "annotations")
+	}
+	contents, err := yaml.Marshal(object.Object)
+	if err != nil {
+		return fmt.Sprintf("# could not marshal object: %v\n", err)
+	}
+	return string(contents)
+}
+
+// splitLines splits the contents in lines, keeping their line endings
+func splitLines(contents string) []string {
+	lines := strings.SplitAfter(contents, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ktest "k8s.io/client-go/testing"
+)
+
+func TestObjectApplierDiff(t *testing.T) {
+	objects, err := decodeManifest(testManifest)
+	if err != nil {
+		t.Fatalf("error not expected decoding the manifest (%v)", err)
+	}
+	applier, client := newTestApplier()
+	client.PrependReactor("get", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		getAction := action.(ktest.GetAction)
+		for _, object := range objects {
+			if getAction.GetResource().Resource == "daemonsets" {
+				break
+			}
+			if object.GetName() == getAction.GetName() && strings.ToLower(object.GetKind())+"s" == getAction.GetResource().Resource {
+				return true, newTestLiveObject(object), nil
+			}
+		}
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: getAction.GetResource().Resource}, getAction.GetName())
+	})
+	client.PrependReactor("patch", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		patchAction := action.(ktest.PatchAction)
+		if patchAction.GetPatchType() != types.ApplyPatchType {
+			t.Errorf("server-side apply expected, got patch type %s", patchAction.GetPatchType())
+		}
+		applied := &unstructured.Unstructured{}
+		if err := applied.UnmarshalJSON(patchAction.GetPatch()); err != nil {
+			t.Fatalf("could not decode patch: %v", err)
+		}
+		applied = newTestLiveObject(applied)
+		if applied.GetKind() == "ServiceAccount" {
+			applied.SetLabels(map[string]string{"app": "kured"})
+		}
+		return true, applied, nil
+	})
+
+	diffs, err := applier.diff(objects)
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	got := []string{}
+	for _, diff := range diffs {
+		got = append(got, string(diff.Action)+" "+diff.Kind+" "+objectName(diff.Namespace, diff.Name))
+	}
+	// the cluster role is unchanged
+	expected := []string{"changed ServiceAccount kube-system/kured", "added DaemonSet default/kured"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got diffs %v, expected %v", got, expected)
+	}
+
+	expectedDiff := `--- live/ServiceAccount/kube-system/kured
++++ applied/ServiceAccount/kube-system/kured
+@@ -1,5 +1,7 @@
+ apiVersion: v1
+ kind: ServiceAccount
+ metadata:
++  labels:
++    app: kured
+   name: kured
+   namespace: kube-system
+`
+	if diffs[0].Diff != expectedDiff {
+		t.Errorf("got diff\n%s\nexpected\n%s", diffs[0].Diff, expectedDiff)
+	}
+	if !strings.Contains(diffs[1].Diff, "+kind: DaemonSet\n") || strings.Contains(diffs[1].Diff, "\n-") {
+		t.Errorf("added object diff expected, got\n%s", diffs[1].Diff)
+	}
+}
+
+func TestObjectApplierDiffDeleted(t *testing.T) {
+	objects, err := decodeManifest(testManifest)
+	if err != nil {
+		t.Fatalf("error not expected decoding the manifest (%v)", err)
+	}
+	applier, client := newTestApplier()
+	client.PrependReactor("get", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		getAction := action.(ktest.GetAction)
+		if getAction.GetResource().Resource == "clusterroles" {
+			return true, newTestLiveObject(objects[1]), nil
+		}
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: getAction.GetResource().Resource}, getAction.GetName())
+	})
+
+	diffs, err := applier.diffDeleted(objects)
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if len(diffs) != 1 || diffs[0].Action != ObjectDeleted || diffs[0].Kind != "ClusterRole" {
+		t.Fatalf("deleted cluster role diff expected, got %v", diffs)
+	}
+	for _, noisy := range []string{"status", "resourceVersion", "uid", "last-applied-configuration"} {
+		if strings.Contains(diffs[0].Diff, noisy) {
+			t.Errorf("%s not expected in diff\n%s", noisy, diffs[0].Diff)
+		}
+	}
+	if !strings.Contains(diffs[0].Diff, "-kind: ClusterRole\n") {
+		t.Errorf("deleted object diff expected, got\n%s", diffs[0].Diff)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+// externalAddonDependencies are the addons every external addon depends on
+var externalAddonDependencies = []kubernetes.Addon{kubernetes.PSP, kubernetes.Cilium}
+
+// ExternalAddonDescriptorFilename is the name of the file describing an
+// addon defined in the cluster definition folder
+const ExternalAddonDescriptorFilename = "addon.yaml"
+
+// ExternalAddonDescriptor describes an addon defined in the cluster
+// definition folder, whose manifests are in its base directory
+type ExternalAddonDescriptor struct {
+	Version           string   `json:"version"`
+	ManifestVersion   uint     `json:"manifestVersion"`
+	Dependencies      []string `json:"dependencies,omitempty"`
+	Images            []string `json:"images,omitempty"`
+	MinClusterVersion string   `json:"minClusterVersion,omitempty"`
+	MaxClusterVersion string   `json:"maxClusterVersion,omitempty"`
+	// Priority is deprecated and ignored: addons are deployed after their
+	// dependencies, and every external addon depends on the CNI already
+	Priority *uint `json:"priority,omitempty"`
+}
+
+// externalAddonsError is the error of the last LoadExternalAddons, which
+// only fails the commands deploying addons
+var externalAddonsError error
+
+// LoadExternalAddons registers the addons defined in the cluster definition
+// folder, every one of them in a directory with an addon.yaml descriptor.
+// They are deployed, upgraded and tracked like the addons of skuba. When
+// they cannot be loaded, DeployAddons returns the error as well.
+func LoadExternalAddons() error {
+	externalAddonsError = loadExternalAddons()
+	return externalAddonsError
+}
+
+// checkExternalAddons returns the error of the last LoadExternalAddons, so
+// addons are not deployed nor pruned without the external ones
+func checkExternalAddons() error {
+	if externalAddonsError != nil {
+		return errors.Wrap(externalAddonsError, "unable to load addons")
+	}
+	return nil
+}
+
+func loadExternalAddons() error {
+	descriptors, err := filepath.Glob(filepath.Join(skubaconstants.AddonsDir(), "*", ExternalAddonDescriptorFilename))
+	if err != nil {
+		return err
+	}
+	for _, descriptorPath := range descriptors {
+		addonName := kubernetes.Addon(filepath.Base(filepath.Dir(descriptorPath)))
+		if err := loadExternalAddon(addonName, descriptorPath); err != nil {
+			return errors.Wrapf(err, "could not load addon %s", descriptorPath)
+		}
+	}
+	return checkAddonDependencies()
+}
+
+func loadExternalAddon(addonName kubernetes.Addon, descriptorPath string) error {
+	if addon, found := Addons[addonName]; found && !addon.external {
+		return errors.Errorf("addon %q is already defined by skuba", addonName)
+	}
+	if errs := validation.IsDNS1123Label(string(addonName)); len(errs) > 0 {
+		return errors.Errorf("invalid addon name %q: %s", addonName, strings.Join(errs, ", "))
+	}
+	contents, err := ioutil.ReadFile(descriptorPath)
+	if err != nil {
+		return err
+	}
+	descriptor := ExternalAddonDescriptor{}
+	if err := yaml.UnmarshalStrict(contents, &descriptor); err != nil {
+		return errors.Wrap(err, "could not parse descriptor")
+	}
+	if descriptor.Priority != nil {
+		klog.Warningf("addon %q: priority is deprecated and ignored, addons are deployed after the addons listed in dependencies", addonName)
+	}
+	addonVersion, err := descriptor.addonVersion()
+	if err != nil {
+		return err
+	}
+	addon := Addon{
+		Addon:        addonName,
+		dependencies: append([]kubernetes.Addon{}, externalAddonDependencies...),
+		AddOnType:    GenericAddOn,
+		external:     true,
+	}
+	for _, dependency := range descriptor.Dependencies {
+		if dependency == string(addonName) {
+			return errors.New("an addon cannot depend on itself")
+		}
+		addon.dependencies = append(addon.dependencies, kubernetes.Addon(dependency))
+	}
+	if manifests, err := addon.resourceManifests(); err != nil || len(manifests) == 0 {
+		return errors.Errorf("no manifests found in %s", addon.baseResourcesDir(addon.addonDir()))
+	}
+	for _, image := range descriptor.Images {
+		image := image
+		addon.getImageCallbacks = append(addon.getImageCallbacks, func(*version.Version, string, string) string {
+			return image
+		})
+	}
+	Addons[addonName] = addon
+	kubernetes.RegisterExternalAddonVersion(addonName, addonVersion)
+	return nil
+}
+
+func (descriptor ExternalAddonDescriptor) addonVersion() (kubernetes.ExternalAddonVersion, error) {
+	addonVersion := kubernetes.ExternalAddonVersion{
+		AddonVersion: kubernetes.AddonVersion{
+			Version:         descriptor.Version,
+			ManifestVersion: descriptor.ManifestVersion,
+		},
+	}
+	if descriptor.ManifestVersion == 0 {
+		return addonVersion, errors.New("manifestVersion is required, and has to be increased on every manifest change")
+	}
+	var err error
+	if descriptor.MinClusterVersion != "" {
+		if addonVersion.MinClusterVersion, err = version.ParseGeneric(descriptor.MinClusterVersion); err != nil {
+			return addonVersion, errors.Wrap(err, "invalid minClusterVersion")
+		}
+	}
+	if descriptor.MaxClusterVersion != "" {
+		if addonVersion.MaxClusterVersion, err = version.ParseGeneric(descriptor.MaxClusterVersion); err != nil {
+			return addonVersion, errors.Wrap(err, "invalid maxClusterVersion")
+		}
+	}
+	if addonVersion.MinClusterVersion != nil && addonVersion.MaxClusterVersion != nil && addonVersion.MaxClusterVersion.LessThan(addonVersion.MinClusterVersion) {
+		return addonVersion, errors.Errorf("maxClusterVersion %s is lower than minClusterVersion %s", descriptor.MaxClusterVersion, descriptor.MinClusterVersion)
+	}
+	return addonVersion, nil
+}
+
+// hasLocalManifests returns whether the manifests of the addon are in the
+// cluster definition folder
+func (addon Addon) hasLocalManifests() bool {
+	if !addon.external {
+		_, err := os.Stat(addon.manifestPath(addon.addonDir()))
+		return err == nil
+	}
+	manifests, err := addon.resourceManifests()
+	return err == nil && len(manifests) > 0
+}
+
+// resourceManifests returns the file names of the base manifests of the
+// addon. skuba renders one manifest for its addons; external addons may have
+// any number of them.
+func (addon Addon) resourceManifests() ([]string, error) {
+	if !addon.external {
+		return []string{addon.manifestFilename()}, nil
+	}
+	manifestPaths, err := filepath.Glob(filepath.Join(addon.baseResourcesDir(addon.addonDir()), "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	manifests := []string{}
+	for _, manifestPath := range manifestPaths {
+		manifests = append(manifests, filepath.Base(manifestPath))
+	}
+	return manifests, nil
+}
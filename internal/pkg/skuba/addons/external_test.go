@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+const externalTestDescriptor = `version: 1.2.0
+manifestVersion: 3
+dependencies:
+  - dex
+images:
+  - registry.example.com/test-operator:1.2.0
+minClusterVersion: 1.18.8
+`
+
+func TestLoadExternalAddons(t *testing.T) {
+	defer os.RemoveAll(skubaconstants.AddonsDir())
+	defer delete(Addons, "test-operator")
+
+	writeTestAddon(t, "test-operator", map[string]string{
+		ExternalAddonDescriptorFilename: externalTestDescriptor,
+		"base/operator.yaml":            "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: test-operator\n  namespace: kube-system\n",
+		"base/rbac.yaml":                "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: test-operator\n",
+	})
+	// directories without descriptor are not addons
+	writeTestAddon(t, "containers", map[string]string{"registries.conf": ""})
+
+	if err := LoadExternalAddons(); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	addon, found := Addons["test-operator"]
+	if !found {
+		t.Fatal("test-operator addon expected to be registered")
+	}
+	if _, found := Addons["containers"]; found {
+		t.Error("containers directory not expected to be registered as an addon")
+	}
+	expectedDependencies := []kubernetes.Addon{kubernetes.PSP, kubernetes.Cilium, kubernetes.Dex}
+	if !reflect.DeepEqual(addon.dependencies, expectedDependencies) || addon.AddOnType != GenericAddOn {
+		t.Errorf("unexpected addon dependencies %v and type %s", addon.dependencies, addon.AddOnType)
+	}
+	if addon.IsPresentForClusterVersion(version.MustParseSemantic("1.18.6")) {
+		t.Error("addon not expected to be present for a cluster version lower than its minimum")
+	}
+	clusterVersion := version.MustParseSemantic("1.18.10")
+	if !addon.IsPresentForClusterVersion(clusterVersion) {
+		t.Error("addon expected to be present for the cluster version")
+	}
+	if images := addon.Images(clusterVersion, "", "1.2.0"); !reflect.DeepEqual(images, []string{"registry.example.com/test-operator:1.2.0"}) {
+		t.Errorf("unexpected addon images %v", images)
+	}
+	addonConfiguration := AddonConfiguration{ClusterVersion: clusterVersion}
+	if match, err := addon.compareLocalBaseManifest(addonConfiguration); err != nil || !match {
+		t.Errorf("local manifests of external addons expected to match, got %v (%v)", match, err)
+	}
+	if err := addon.Write(addonConfiguration); err != nil {
+		t.Errorf("error not expected, but an error was reported (%v)", err)
+	}
+
+	if err := addon.writeKustomization(); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	objects, err := buildKustomization(addon.addonDir())
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	kinds := []string{}
+	for _, object := range objects {
+		kinds = append(kinds, object.GetKind())
+	}
+	if len(kinds) != 2 {
+		t.Errorf("objects of both manifests expected, got %v", kinds)
+	}
+}
+
+func TestLoadExternalAddonsDeprecatedPriority(t *testing.T) {
+	defer os.RemoveAll(skubaconstants.AddonsDir())
+	defer delete(Addons, "test-operator")
+
+	writeTestAddon(t, "test-operator", map[string]string{
+		ExternalAddonDescriptorFilename: externalTestDescriptor + "priority: 1\n",
+		"base/operator.yaml":            "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: test-operator\n",
+	})
+	if err := LoadExternalAddons(); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if _, found := Addons["test-operator"]; !found {
+		t.Error("test-operator addon expected to be registered")
+	}
+}
+
+func TestDeployAddonsExternalAddonsError(t *testing.T) {
+	defer func() { externalAddonsError = nil }()
+	defer os.RemoveAll(skubaconstants.AddonsDir())
+
+	writeTestAddon(t, "test-operator", map[string]string{
+		ExternalAddonDescriptorFilename: "version: 1.2.0\n",
+	})
+	if err := LoadExternalAddons(); err == nil {
+		t.Fatal("error expected, but no error reported")
+	}
+	err := DeployAddons(fake.NewSimpleClientset(), AddonConfiguration{ClusterVersion: kubernetes.LatestVersion()}, ApplyOptions{})
+	if err == nil {
+		t.Fatal("error expected, but no error reported")
+	}
+	if !strings.Contains(err.Error(), "unable to load addons") {
+		t.Errorf("error message %q expected to contain %q", err.Error(), "unable to load addons")
+	}
+}
+
+func TestLoadExternalAddonsErrors(t *testing.T) {
+	defer func() { externalAddonsError = nil }()
+
+	tests := []struct {
+		name         string
+		addon        kubernetes.Addon
+		descriptor   string
+		noManifests  bool
+		expectErrMsg string
+	}{
+		{
+			name:         "addon defined by skuba",
+			addon:        kubernetes.Kured,
+			descriptor:   externalTestDescriptor,
+			expectErrMsg: `addon "kured" is already defined by skuba`,
+		},
+		{
+			name:         "missing manifest version",
+			addon:        "test-operator",
+			descriptor:   "version: 1.2.0\n",
+			expectErrMsg: "manifestVersion is required",
+		},
+		{
+			name:         "unknown field",
+			addon:        "test-operator",
+			descriptor:   externalTestDescriptor + "maxVersion: 1.19.0\n",
+			expectErrMsg: "could not parse descriptor",
+		},
+		{
+			name:         "maximum lower than minimum",
+			addon:        "test-operator",
+			descriptor:   externalTestDescriptor + "maxClusterVersion: 1.18.6\n",
+			expectErrMsg: "maxClusterVersion 1.18.6 is lower than minClusterVersion 1.18.8",
+		},
+		{
+			name:         "no manifests",
+			addon:        "test-operator",
+			descriptor:   externalTestDescriptor,
+			noManifests:  true,
+			expectErrMsg: "no manifests found",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			defer os.RemoveAll(skubaconstants.AddonsDir())
+			files := map[string]string{ExternalAddonDescriptorFilename: tt.descriptor}
+			if !tt.noManifests {
+				files["base/operator.yaml"] = "apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: test-operator\n"
+			}
+			writeTestAddon(t, string(tt.addon), files)
+
+			err := LoadExternalAddons()
+			if err == nil {
+				t.Fatal("error expected, but no error reported")
+			}
+			if !strings.Contains(err.Error(), tt.expectErrMsg) {
+				t.Errorf("error message %q expected to contain %q", err.Error(), tt.expectErrMsg)
+			}
+			if _, found := Addons["test-operator"]; found {
+				delete(Addons, "test-operator")
+				t.Error("addon not expected to be registered")
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+// testManifest is the manifest of an addon with a namespaced, a cluster
+// scoped and a namespaced object without namespace
+const testManifest = `---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: kured
+  namespace: kube-system
+---
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: kured
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: kured
+`
+
+// newTestApplier returns an object applier on a fake dynamic client, knowing
+// the kinds of testManifest and config maps
+func newTestApplier() (*objectApplier, *dynamicfake.FakeDynamicClient) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}, meta.RESTScopeNamespace)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	return &objectApplier{client: client, mapper: mapper}, client
+}
+
+func newTestObject(apiVersion, kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetAPIVersion(apiVersion)
+	object.SetKind(kind)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	if labels != nil {
+		object.SetLabels(labels)
+	}
+	return object
+}
+
+// newTestLiveObject returns the object as the API server returns it, with
+// the fields set by the server
+func newTestLiveObject(object *unstructured.Unstructured) *unstructured.Unstructured {
+	live := object.DeepCopy()
+	live.SetResourceVersion("42")
+	live.SetUID("d6a0d2f5")
+	live.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"})
+	_ = unstructured.SetNestedField(live.Object, map[string]interface{}{"numberReady": int64(3)}, "status")
+	return live
+}
+
+// newTestDeployment returns a kube-system deployment of 2 replicas, with
+// updatedReplicas of them updated and available
+func newTestDeployment(name string, updatedReplicas int32) *appsv1.Deployment {
+	replicas := int32(2)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem, Generation: 1},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           2,
+			UpdatedReplicas:    updatedReplicas,
+			AvailableReplicas:  updatedReplicas,
+		},
+	}
+}
+
+// newTestEvent returns a warning event of a kube-system object, emitted the
+// given minutes after 2020-01-01
+func newTestEvent(name, kind, object, reason, message string, minutes int) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem},
+		InvolvedObject: v1.ObjectReference{Kind: kind, Name: object, Namespace: metav1.NamespaceSystem},
+		Type:           v1.EventTypeWarning,
+		Reason:         reason,
+		Message:        message,
+		LastTimestamp:  metav1.NewTime(time.Date(2020, 1, 1, 0, minutes, 0, 0, time.UTC)),
+	}
+}
+
+// writeTestAddon writes the files of an external addon in the cluster
+// definition folder
+func writeTestAddon(t *testing.T, name string, files map[string]string) {
+	for file, contents := range files {
+		path := filepath.Join(skubaconstants.AddonsDir(), name, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("unable to write %s: %v", path, err)
+		}
+	}
+}
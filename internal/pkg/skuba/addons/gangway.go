@@ -31,15 +31,15 @@ import (
 )
 
 func init() {
-	registerAddon(kubernetes.Gangway, GenericAddOn, renderGangwayTemplate, nil, gangwayCallbacks{}, normalPriority, []getImageCallback{GetGangwayImage})
+	registerAddon(kubernetes.Gangway, GenericAddOn, renderGangwayTemplate, nil, gangwayCallbacks{}, []kubernetes.Addon{kubernetes.Dex}, []getImageCallback{GetGangwayImage})
 }
 
-func GetGangwayImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "gangway", imageTag)
+func GetGangwayImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "gangway", imageTag)
 }
 
 func (renderContext renderContext) GangwayImage() string {
-	return GetGangwayImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Gangway, renderContext.config.ClusterVersion).Version)
+	return GetGangwayImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Gangway, renderContext.config.ClusterVersion).Version)
 }
 
 func renderGangwayTemplate(addonConfiguration AddonConfiguration) string {
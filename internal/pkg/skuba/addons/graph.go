@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// addonsInDependencyOrder returns the registered addons sorted so every
+// addon comes after the addons it depends on. Addons that do not depend on
+// each other are sorted by name, so the order is the same on every run.
+func addonsInDependencyOrder() ([]Addon, error) {
+	if err := checkAddonDependencies(); err != nil {
+		return nil, err
+	}
+	pending := map[kubernetes.Addon]int{}
+	dependents := map[kubernetes.Addon][]kubernetes.Addon{}
+	for addonName, addon := range Addons {
+		pending[addonName] = len(addon.dependencies)
+		for _, dependency := range addon.dependencies {
+			dependents[dependency] = append(dependents[dependency], addonName)
+		}
+	}
+	ready := []kubernetes.Addon{}
+	for addonName, count := range pending {
+		if count == 0 {
+			ready = append(ready, addonName)
+		}
+	}
+	sortedAddons := []Addon{}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			return ready[i] < ready[j]
+		})
+		addonName := ready[0]
+		ready = ready[1:]
+		sortedAddons = append(sortedAddons, Addons[addonName])
+		for _, dependent := range dependents[addonName] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return sortedAddons, nil
+}
+
+// checkAddonDependencies checks that the addons only depend on registered
+// addons, and that there are no dependency cycles
+func checkAddonDependencies() error {
+	addonNames := []kubernetes.Addon{}
+	for addonName := range Addons {
+		addonNames = append(addonNames, addonName)
+	}
+	sort.Slice(addonNames, func(i, j int) bool {
+		return addonNames[i] < addonNames[j]
+	})
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[kubernetes.Addon]int{}
+	path := []kubernetes.Addon{}
+	var visit func(addonName kubernetes.Addon) error
+	visit = func(addonName kubernetes.Addon) error {
+		switch state[addonName] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := []string{}
+			for i := len(path) - 1; i >= 0; i-- {
+				cycle = append([]string{string(path[i])}, cycle...)
+				if path[i] == addonName {
+					break
+				}
+			}
+			return errors.Errorf("addon dependency cycle: %s -> %s", strings.Join(cycle, " -> "), addonName)
+		}
+		state[addonName] = visiting
+		path = append(path, addonName)
+		for _, dependency := range Addons[addonName].dependencies {
+			if _, found := Addons[dependency]; !found {
+				return errors.Errorf("%q addon depends on unknown addon %q", addonName, dependency)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[addonName] = visited
+		return nil
+	}
+	for _, addonName := range addonNames {
+		if err := visit(addonName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddonsError reports the addons that failed to apply, and the addons that
+// were skipped because they depend on them
+type AddonsError struct {
+	Failed  map[kubernetes.Addon]error
+	Skipped []kubernetes.Addon
+}
+
+func (e *AddonsError) Error() string {
+	failed := []string{}
+	for addonName, err := range e.Failed {
+		failed = append(failed, fmt.Sprintf("%q (%v)", addonName, err))
+	}
+	sort.Strings(failed)
+	message := fmt.Sprintf("failed to apply addons: %s", strings.Join(failed, ", "))
+	if len(e.Skipped) > 0 {
+		skipped := []string{}
+		for _, addonName := range e.Skipped {
+			skipped = append(skipped, fmt.Sprintf("%q", addonName))
+		}
+		sort.Strings(skipped)
+		message += fmt.Sprintf("; skipped the addons depending on them: %s", strings.Join(skipped, ", "))
+	}
+	return message
+}
+
+// runInDependencyOrder runs the function for every addon once it finished for
+// all the addons it depends on, so addons that do not depend on each other run
+// in parallel. When the function fails for an addon, every addon depending on
+// it, directly or not, is skipped and the rest keep running.
+func runInDependencyOrder(addons []Addon, run func(Addon) error) error {
+	done := map[kubernetes.Addon]chan struct{}{}
+	for _, addon := range addons {
+		done[addon.Addon] = make(chan struct{})
+	}
+	var mutex sync.Mutex
+	failed := map[kubernetes.Addon]bool{}
+	addonsError := &AddonsError{Failed: map[kubernetes.Addon]error{}}
+	var wg sync.WaitGroup
+	for _, addon := range addons {
+		wg.Add(1)
+		go func(addon Addon) {
+			defer wg.Done()
+			defer close(done[addon.Addon])
+			for _, dependency := range addon.dependencies {
+				dependencyDone, found := done[dependency]
+				if !found {
+					// not run, so there is nothing to wait for
+					continue
+				}
+				<-dependencyDone
+				mutex.Lock()
+				dependencyFailed := failed[dependency]
+				if dependencyFailed {
+					failed[addon.Addon] = true
+					addonsError.Skipped = append(addonsError.Skipped, addon.Addon)
+				}
+				mutex.Unlock()
+				if dependencyFailed {
+					klog.Errorf("skipping %q addon, it depends on %q addon, which failed", addon.Addon, dependency)
+					return
+				}
+			}
+			if err := run(addon); err != nil {
+				mutex.Lock()
+				failed[addon.Addon] = true
+				addonsError.Failed[addon.Addon] = err
+				mutex.Unlock()
+			}
+		}(addon)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return addonsError
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+func TestAddonsInDependencyOrder(t *testing.T) {
+	addons, err := addonsInDependencyOrder()
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if len(addons) != len(Addons) {
+		t.Fatalf("expected %d addons, got %d", len(Addons), len(addons))
+	}
+	position := map[kubernetes.Addon]int{}
+	for i, addon := range addons {
+		position[addon.Addon] = i
+	}
+	for _, addon := range addons {
+		for _, dependency := range addon.dependencies {
+			if position[dependency] > position[addon.Addon] {
+				t.Errorf("%q addon expected to come after its dependency %q", addon.Addon, dependency)
+			}
+		}
+	}
+	if addons[0].Addon != kubernetes.PSP || addons[1].Addon != kubernetes.Cilium {
+		t.Errorf("psp and cilium addons expected first, got %q and %q", addons[0].Addon, addons[1].Addon)
+	}
+	for i := 0; i < 5; i++ {
+		again, _ := addonsInDependencyOrder()
+		if !reflect.DeepEqual(addonNames(again), addonNames(addons)) {
+			t.Fatalf("addon order expected to be stable, got %v and %v", addonNames(addons), addonNames(again))
+		}
+	}
+}
+
+func addonNames(addons []Addon) []kubernetes.Addon {
+	names := []kubernetes.Addon{}
+	for _, addon := range addons {
+		names = append(names, addon.Addon)
+	}
+	return names
+}
+
+func TestCheckAddonDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		addons       map[kubernetes.Addon][]kubernetes.Addon
+		expectErrMsg string
+	}{
+		{
+			name: "dependency cycle",
+			addons: map[kubernetes.Addon][]kubernetes.Addon{
+				"test-a": {kubernetes.Dex, "test-b"},
+				"test-b": {"test-c"},
+				"test-c": {"test-a"},
+			},
+			expectErrMsg: "addon dependency cycle: test-a -> test-b -> test-c -> test-a",
+		},
+		{
+			name: "unknown dependency",
+			addons: map[kubernetes.Addon][]kubernetes.Addon{
+				"test-a": {"test-b"},
+			},
+			expectErrMsg: `"test-a" addon depends on unknown addon "test-b"`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			for addonName, dependencies := range tt.addons {
+				Addons[addonName] = Addon{Addon: addonName, dependencies: dependencies}
+				defer delete(Addons, addonName)
+			}
+			_, err := addonsInDependencyOrder()
+			if err == nil {
+				t.Fatal("error expected, but no error reported")
+			}
+			if !strings.Contains(err.Error(), tt.expectErrMsg) {
+				t.Errorf("error message %q expected to contain %q", err.Error(), tt.expectErrMsg)
+			}
+		})
+	}
+}
+
+func TestDeployAddonsDependencies(t *testing.T) {
+	defer func(originalApplyAddon func(Addon, clientset.Interface, AddonConfiguration, *skuba.SkubaConfiguration, ApplyOptions) error) {
+		applyAddon = originalApplyAddon
+	}(applyAddon)
+
+	var mutex sync.Mutex
+	applied := []kubernetes.Addon{}
+	applyAddon = func(addon Addon, client clientset.Interface, addonConfiguration AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration, options ApplyOptions) error {
+		if addon.Addon == kubernetes.Dex {
+			return errors.New("dex failed")
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, dependency := range addon.dependencies {
+			found := false
+			for _, appliedAddon := range applied {
+				found = found || appliedAddon == dependency
+			}
+			if !found && dependency != kubernetes.Cilium {
+				t.Errorf("%q addon applied before its dependency %q", addon.Addon, dependency)
+			}
+		}
+		applied = append(applied, addon.Addon)
+		return nil
+	}
+
+	addonConfiguration := AddonConfiguration{ClusterVersion: kubernetes.LatestVersion()}
+	err := DeployAddons(fake.NewSimpleClientset(), addonConfiguration, ApplyOptions{})
+	addonsError, ok := err.(*AddonsError)
+	if !ok {
+		t.Fatalf("addons error expected, got %v", err)
+	}
+	if len(addonsError.Failed) != 1 || addonsError.Failed[kubernetes.Dex] == nil {
+		t.Errorf("only dex addon expected to fail, got %v", addonsError.Failed)
+	}
+	if !reflect.DeepEqual(addonsError.Skipped, []kubernetes.Addon{kubernetes.Gangway}) {
+		t.Errorf("only gangway addon expected to be skipped, got %v", addonsError.Skipped)
+	}
+	for _, addonName := range applied {
+		if addonName == kubernetes.Gangway {
+			t.Error("gangway addon not expected to be applied after dex failed")
+		}
+	}
+	for _, addonName := range []kubernetes.Addon{kubernetes.PSP, kubernetes.Kured, kubernetes.MetricsServer} {
+		found := false
+		for _, appliedAddon := range applied {
+			found = found || appliedAddon == addonName
+		}
+		if !found && Addons[addonName].IsPresentForClusterVersion(addonConfiguration.ClusterVersion) {
+			t.Errorf("%q addon expected to be applied, got %v", addonName, applied)
+		}
+	}
+	expectedErrMsg := `failed to apply addons: "dex" (dex failed); skipped the addons depending on them: "gangway"`
+	if err.Error() != expectedErrMsg {
+		t.Errorf("expected error %q, got %q", expectedErrMsg, err.Error())
+	}
+}
@@ -26,15 +26,15 @@ import (
 )
 
 func init() {
-	registerAddon(kubernetes.Kucero, GenericAddOn, renderKuceroTemplate, nil, nil, normalPriority, []getImageCallback{GetKuceroImage})
+	registerAddon(kubernetes.Kucero, GenericAddOn, renderKuceroTemplate, nil, nil, []kubernetes.Addon{kubernetes.PSP, kubernetes.Cilium}, []getImageCallback{GetKuceroImage})
 }
 
-func GetKuceroImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "kucero", imageTag)
+func GetKuceroImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "kucero", imageTag)
 }
 
 func (renderContext renderContext) KuceroImage() string {
-	return GetKuceroImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Kucero, renderContext.config.ClusterVersion).Version)
+	return GetKuceroImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Kucero, renderContext.config.ClusterVersion).Version)
 }
 
 func renderKuceroTemplate(addonConfiguration AddonConfiguration) string {
@@ -47,9 +47,9 @@ func TestGetKuceroImage(t *testing.T) {
 		for _, tt := range tests {
 			tt := tt // Parallel testing
 			t.Run(tt.name, func(t *testing.T) {
-				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver), tt.want)
+				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver, ""), tt.want)
 
-				if got := GetKuceroImage(ver, tt.imageTag); got != imageUri {
+				if got := GetKuceroImage(ver, "", tt.imageTag); got != imageUri {
 					t.Errorf("GetKuceroImage() = %v, want %v", got, imageUri)
 				}
 			})
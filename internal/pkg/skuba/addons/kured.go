@@ -25,15 +25,15 @@ import (
 )
 
 func init() {
-	registerAddon(kubernetes.Kured, GenericAddOn, renderKuredTemplate, nil, nil, normalPriority, []getImageCallback{GetKuredImage})
+	registerAddon(kubernetes.Kured, GenericAddOn, renderKuredTemplate, nil, nil, []kubernetes.Addon{kubernetes.PSP, kubernetes.Cilium}, []getImageCallback{GetKuredImage})
 }
 
-func GetKuredImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "kured", imageTag)
+func GetKuredImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "kured", imageTag)
 }
 
 func (renderContext renderContext) KuredImage() string {
-	return GetKuredImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.Kured, renderContext.config.ClusterVersion).Version)
+	return GetKuredImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.Kured, renderContext.config.ClusterVersion).Version)
 }
 
 func renderKuredTemplate(addonConfiguration AddonConfiguration) string {
@@ -48,9 +48,9 @@ func TestGetKuredImage(t *testing.T) {
 		for _, tt := range tests {
 			tt := tt // Parallel testing
 			t.Run(tt.name, func(t *testing.T) {
-				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver), tt.want)
+				imageUri := fmt.Sprintf("%s/%s", img.ImageRepository(ver, ""), tt.want)
 
-				if got := GetKuredImage(ver, tt.imageTag); got != imageUri {
+				if got := GetKuredImage(ver, "", tt.imageTag); got != imageUri {
 					t.Errorf("GetKuredImage() = %v, want %v", got, imageUri)
 				}
 			})
@@ -74,7 +74,7 @@ func Test_renderContext_KuredImage(t *testing.T) {
 					ClusterName:    "",
 				},
 			},
-			want: img.ImageRepository(ver) + "/kured:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
+			want: img.ImageRepository(ver, "") + "/kured:([[:digit:]]{1,}.){2}[[:digit:]]{1,}(-rev[:digit:]{1,})?",
 		}
 		t.Run(tt.name, func(t *testing.T) {
 			got := tt.renderContext.KuredImage()
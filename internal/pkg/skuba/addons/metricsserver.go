@@ -37,15 +37,15 @@ import (
 )
 
 func init() {
-	registerAddon(kubernetes.MetricsServer, GenericAddOn, renderMetricsServerTemplate, nil, metricsServerCallbacks{}, normalPriority, []getImageCallback{GetMetricsServerImage})
+	registerAddon(kubernetes.MetricsServer, GenericAddOn, renderMetricsServerTemplate, nil, metricsServerCallbacks{}, []kubernetes.Addon{kubernetes.PSP, kubernetes.Cilium}, []getImageCallback{GetMetricsServerImage})
 }
 
-func GetMetricsServerImage(clusterVersion *version.Version, imageTag string) string {
-	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion), "metrics-server", imageTag)
+func GetMetricsServerImage(clusterVersion *version.Version, imageRepository, imageTag string) string {
+	return images.GetGenericImage(skubaconstants.ImageRepository(clusterVersion, imageRepository), "metrics-server", imageTag)
 }
 
 func (renderContext renderContext) MetricsServerImage() string {
-	return GetMetricsServerImage(renderContext.config.ClusterVersion, kubernetes.AddonVersionForClusterVersion(kubernetes.MetricsServer, renderContext.config.ClusterVersion).Version)
+	return GetMetricsServerImage(renderContext.config.ClusterVersion, renderContext.config.ImageRepository, kubernetes.AddonVersionForClusterVersion(kubernetes.MetricsServer, renderContext.config.ClusterVersion).Version)
 }
 
 // CABundle returns base64 encoded Kubernetes CA certificate.
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// AddonLabel is the label of the objects applied by skuba, set to the name
+// of the addon they belong to
+const AddonLabel = "addon.caasp.suse.com/name"
+
+// pruneKinds are the kinds of the objects looked up when pruning an addon,
+// besides the kinds of the objects of its manifest
+var pruneKinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "Secret"},
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ServiceAccount"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Group: "batch", Version: "v1", Kind: "Job"},
+	{Group: "batch", Version: "v1beta1", Kind: "CronJob"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding"},
+	{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy"},
+	{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Kind: "MutatingWebhookConfiguration"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Kind: "ValidatingWebhookConfiguration"},
+}
+
+// setAddonLabel labels the objects as belonging to the addon
+func setAddonLabel(objects []*unstructured.Unstructured, addon kubernetes.Addon) {
+	for _, object := range objects {
+		labels := object.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[AddonLabel] = string(addon)
+		object.SetLabels(labels)
+	}
+}
+
+// objectKey identifies an object regardless of the version of its kind
+func objectKey(object *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s", object.GroupVersionKind().GroupKind(), objectName(object.GetNamespace(), object.GetName()))
+}
+
+// prunable returns the live objects labeled as belonging to the addon that
+// are not in objects anymore
+func (a *objectApplier) prunable(addon kubernetes.Addon, objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	kept := map[string]bool{}
+	kinds := []schema.GroupVersionKind{}
+	listed := map[schema.GroupKind]bool{}
+	for _, object := range objects {
+		// default the namespace of the object, as apply did
+		if _, err := a.resourceFor(object); err != nil && !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+		kept[objectKey(object)] = true
+		kinds = append(kinds, object.GroupVersionKind())
+	}
+	kinds = append(kinds, pruneKinds...)
+
+	prunable := []*unstructured.Unstructured{}
+	objectErrors := &ObjectErrors{Operation: "list"}
+	for _, gvk := range kinds {
+		if listed[gvk.GroupKind()] {
+			continue
+		}
+		listed[gvk.GroupKind()] = true
+		mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			// the kind is not served, so there are no objects to prune
+			continue
+		}
+		var list *unstructured.UnstructuredList
+		if err == nil {
+			list, err = a.client.Resource(mapping.Resource).List(context.TODO(), metav1.ListOptions{
+				LabelSelector: fmt.Sprintf("%s=%s", AddonLabel, addon),
+			})
+		}
+		if err != nil {
+			objectErrors.Errors = append(objectErrors.Errors, ObjectError{Kind: gvk.Kind, Err: err})
+			continue
+		}
+		for i := range list.Items {
+			object := &list.Items[i]
+			if object.GetDeletionTimestamp() != nil || kept[objectKey(object)] {
+				continue
+			}
+			prunable = append(prunable, object)
+		}
+	}
+	if len(objectErrors.Errors) > 0 {
+		return nil, objectErrors
+	}
+	return prunable, nil
+}
+
+// prune deletes the live objects labeled as belonging to the addon that are
+// not in objects anymore, and returns them
+func (a *objectApplier) prune(addon kubernetes.Addon, objects []*unstructured.Unstructured, dryRun bool) ([]*unstructured.Unstructured, error) {
+	prunable, err := a.prunable(addon, objects)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.delete(prunable, dryRun); err != nil {
+		return nil, err
+	}
+	return prunable, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktest "k8s.io/client-go/testing"
+)
+
+func TestObjectApplierPrune(t *testing.T) {
+	objects, err := decodeManifest(testManifest)
+	if err != nil {
+		t.Fatalf("error not expected decoding the manifest (%v)", err)
+	}
+	setAddonLabel(objects, "kured")
+	for _, object := range objects {
+		if object.GetLabels()[AddonLabel] != "kured" {
+			t.Errorf("%s %s expected to be labeled as a kured addon object", object.GetKind(), object.GetName())
+		}
+	}
+
+	applier, client := newTestApplier()
+	kuredLabels := map[string]string{AddonLabel: "kured"}
+	deletedObject := *newTestObject("v1", "ConfigMap", "kube-system", "kured-config", kuredLabels)
+	now := metav1.Now()
+	deletedObject.SetDeletionTimestamp(&now)
+	live := map[string][]unstructured.Unstructured{
+		"serviceaccounts": {*newTestObject("v1", "ServiceAccount", "kube-system", "kured", kuredLabels)},
+		"clusterroles":    {*newTestObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "kured", kuredLabels)},
+		"daemonsets": {
+			*newTestObject("apps/v1", "DaemonSet", "default", "kured", kuredLabels),
+			*newTestObject("apps/v1", "DaemonSet", "kube-system", "kured", kuredLabels),
+		},
+		"configmaps": {
+			*newTestObject("v1", "ConfigMap", "kube-system", "kured", kuredLabels),
+			deletedObject,
+		},
+	}
+	client.PrependReactor("list", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		listAction := action.(ktest.ListAction)
+		if selector := listAction.GetListRestrictions().Labels.String(); selector != AddonLabel+"=kured" {
+			t.Errorf("addon label selector expected, got %q", selector)
+		}
+		if listAction.GetNamespace() != "" {
+			t.Errorf("objects of all namespaces expected to be listed, got namespace %s", listAction.GetNamespace())
+		}
+		return true, &unstructured.UnstructuredList{Items: live[listAction.GetResource().Resource]}, nil
+	})
+	deleted := []string{}
+	client.PrependReactor("delete", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		deleteAction := action.(ktest.DeleteAction)
+		deleted = append(deleted, deleteAction.GetResource().Resource+" "+objectName(deleteAction.GetNamespace(), deleteAction.GetName()))
+		return true, nil, nil
+	})
+
+	pruned, err := applier.prune("kured", objects, false)
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	got := []string{}
+	for _, object := range pruned {
+		got = append(got, object.GetKind()+" "+objectName(object.GetNamespace(), object.GetName()))
+	}
+	// the daemon set of the manifest is in the default namespace
+	if expected := []string{"DaemonSet kube-system/kured", "ConfigMap kube-system/kured"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got pruned objects %v, expected %v", got, expected)
+	}
+	if expected := []string{"configmaps kube-system/kured", "daemonsets kube-system/kured"}; !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("got deleted objects %v, expected %v", deleted, expected)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"k8s.io/kubectl/pkg/polymorphichelpers"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// DefaultRolloutTimeout is the default time to wait for the workloads of an
+// addon to roll out
+const DefaultRolloutTimeout = 5 * time.Minute
+
+// rolloutEventCount is the number of most recent events reported for every
+// object of a failed rollout
+const rolloutEventCount = 5
+
+var rolloutInterval = 2 * time.Second
+
+// workload is a Deployment, DaemonSet or StatefulSet of an addon
+type workload struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (w workload) String() string {
+	return fmt.Sprintf("%s %s", w.kind, objectName(w.namespace, w.name))
+}
+
+// get returns the live workload and its pod selector
+func (w workload) get(client clientset.Interface) (runtime.Object, *metav1.LabelSelector, error) {
+	switch w.kind {
+	case "Deployment":
+		deployment, err := client.AppsV1().Deployments(w.namespace).Get(context.TODO(), w.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return deployment, deployment.Spec.Selector, nil
+	case "DaemonSet":
+		daemonSet, err := client.AppsV1().DaemonSets(w.namespace).Get(context.TODO(), w.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return daemonSet, daemonSet.Spec.Selector, nil
+	default:
+		statefulSet, err := client.AppsV1().StatefulSets(w.namespace).Get(context.TODO(), w.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return statefulSet, statefulSet.Spec.Selector, nil
+	}
+}
+
+// rolloutWorkloads returns the Deployments, DaemonSets and StatefulSets of
+// the objects
+func rolloutWorkloads(objects []*unstructured.Unstructured) []workload {
+	workloads := []workload{}
+	for _, object := range objects {
+		if object.GroupVersionKind().Group != appsv1.GroupName {
+			continue
+		}
+		switch object.GetKind() {
+		case "Deployment", "DaemonSet", "StatefulSet":
+			workloads = append(workloads, workload{kind: object.GetKind(), namespace: object.GetNamespace(), name: object.GetName()})
+		}
+	}
+	return workloads
+}
+
+// waitForRollout waits for the Deployments, DaemonSets and StatefulSets of
+// the objects to roll out. When they do not roll out in time, the returned
+// error describes the pods that are not ready and the recent events of the
+// workloads and of their pods.
+func waitForRollout(client clientset.Interface, objects []*unstructured.Unstructured, timeout time.Duration) error {
+	for _, w := range rolloutWorkloads(objects) {
+		klog.V(1).Infof("waiting for %s to roll out", w)
+		var status string
+		err := wait.PollImmediate(rolloutInterval, timeout, func() (bool, error) {
+			var done bool
+			var err error
+			status, done, err = rolloutStatus(client, w)
+			return done, err
+		})
+		if err == wait.ErrWaitTimeout {
+			err = errors.Errorf("not rolled out after %s: %s", timeout, status)
+		}
+		if err != nil {
+			return errors.Errorf("%s %v%s", w, err, rolloutProblems(client, w))
+		}
+	}
+	return nil
+}
+
+// rolloutStatus returns whether the workload finished its rollout, with the
+// status of the rollout otherwise
+func rolloutStatus(client clientset.Interface, w workload) (string, bool, error) {
+	object, _, err := w.get(client)
+	if err != nil {
+		return "", false, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return "", false, err
+	}
+	viewer, err := polymorphichelpers.StatusViewerFor(appsv1.SchemeGroupVersion.WithKind(w.kind).GroupKind())
+	if err != nil {
+		return "", false, err
+	}
+	status, done, err := viewer.Status(&unstructured.Unstructured{Object: content}, 0)
+	if done {
+		// workloads without rolling updates are not waited for
+		return status, true, nil
+	}
+	return strings.TrimSpace(status), false, err
+}
+
+// rolloutProblems describes the pods of the workload that are not ready, and
+// the recent events of the workload and of those pods
+func rolloutProblems(client clientset.Interface, w workload) string {
+	_, selector, err := w.get(client)
+	if err != nil {
+		return ""
+	}
+	lines := recentEvents(client, w.namespace, w.kind, w.name)
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return strings.Join(lines, "")
+	}
+	pods, err := client.CoreV1().Pods(w.namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return strings.Join(lines, "")
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if kubernetes.IsPodReady(pod) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("\n  - pod %s is %s%s", objectName(pod.Namespace, pod.Name), pod.Status.Phase, podProblems(pod)))
+		lines = append(lines, recentEvents(client, pod.Namespace, "Pod", pod.Name)...)
+	}
+	return strings.Join(lines, "")
+}
+
+// recentEvents returns the most recent events of an object, oldest first
+func recentEvents(client clientset.Interface, namespace, kind, name string) []string {
+	events, err := client.CoreV1().Events(namespace).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		return nil
+	}
+	items := []v1.Event{}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind == kind && event.InvolvedObject.Name == name {
+			items = append(items, event)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(&items[i]).Before(eventTime(&items[j]))
+	})
+	if len(items) > rolloutEventCount {
+		items = items[len(items)-rolloutEventCount:]
+	}
+	lines := []string{}
+	for _, event := range items {
+		lines = append(lines, fmt.Sprintf("\n      %s %s: %s", event.Type, event.Reason, strings.TrimSpace(event.Message)))
+	}
+	return lines
+}
+
+func eventTime(event *v1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// podProblems describes why the containers of the pod are not running
+func podProblems(pod *v1.Pod) string {
+	problems := []string{}
+	for _, containerStatus := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		switch {
+		case containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason != "":
+			problems = append(problems, fmt.Sprintf("%s: %s", containerStatus.Name, containerStatus.State.Waiting.Reason))
+		case containerStatus.State.Terminated != nil && containerStatus.State.Terminated.ExitCode != 0:
+			problems = append(problems, fmt.Sprintf("%s: %s (exit code %d)", containerStatus.Name, containerStatus.State.Terminated.Reason, containerStatus.State.Terminated.ExitCode))
+		}
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(problems, ", "))
+}
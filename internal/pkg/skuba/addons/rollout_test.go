@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitForRollout(t *testing.T) {
+	defer func(originalInterval time.Duration) {
+		rolloutInterval = originalInterval
+	}(rolloutInterval)
+	rolloutInterval = time.Millisecond
+
+	manifestObjects := []*unstructured.Unstructured{
+		newTestObject("v1", "ServiceAccount", "kube-system", "dex", nil),
+		newTestObject("apps/v1", "Deployment", "kube-system", "dex", nil),
+	}
+
+	tests := []struct {
+		name               string
+		updatedReplicas    int32
+		expectedErrContent []string
+		unexpectedContent  []string
+	}{
+		{
+			name:            "rolled out",
+			updatedReplicas: 2,
+		},
+		{
+			name:            "not rolled out",
+			updatedReplicas: 1,
+			expectedErrContent: []string{
+				"Deployment kube-system/dex not rolled out after 20ms: Waiting for deployment \"dex\" rollout to finish: 1 out of 2 new replicas have been updated...",
+				"pod kube-system/dex-2 is Pending (dex: ImagePullBackOff)",
+				"Warning BackOff: Back-off pulling image \"dex:2.23.0\"",
+				"Warning ProgressDeadlineExceeded: ReplicaSet \"dex-2\" has timed out progressing.",
+			},
+			// only the 5 most recent events of the pod are reported
+			unexpectedContent: []string{"dex-1", "Scheduled", "Failed to pull image"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{
+				newTestDeployment("dex", tt.updatedReplicas),
+				&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "dex-1", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"app": "dex"}},
+					Status: v1.PodStatus{
+						Phase:      v1.PodRunning,
+						Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+					},
+				},
+				&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "dex-2", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"app": "dex"}},
+					Status: v1.PodStatus{
+						Phase: v1.PodPending,
+						ContainerStatuses: []v1.ContainerStatus{{
+							Name:  "dex",
+							State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+						}},
+					},
+				},
+				newTestEvent("dex-2.1", "Pod", "dex-2", "Scheduled", "Successfully assigned", 1),
+				newTestEvent("dex-2.2", "Pod", "dex-2", "Failed", "Failed to pull image \"dex:2.23.0\"", 2),
+				newTestEvent("dex-1.1", "Pod", "dex-1", "Scheduled", "Successfully assigned", 1),
+				newTestEvent("dex.1", "Deployment", "dex", "ProgressDeadlineExceeded", "ReplicaSet \"dex-2\" has timed out progressing.", 3),
+			}
+			for minutes := 3; minutes < 8; minutes++ {
+				objects = append(objects, newTestEvent(fmt.Sprintf("dex-2.%d", minutes), "Pod", "dex-2", "BackOff", "Back-off pulling image \"dex:2.23.0\"", minutes))
+			}
+			client := fake.NewSimpleClientset(objects...)
+
+			err := waitForRollout(client, manifestObjects, 20*time.Millisecond)
+			if len(tt.expectedErrContent) == 0 {
+				if err != nil {
+					t.Errorf("error not expected, but an error was reported (%v)", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("error expected, but no error reported")
+			}
+			for _, content := range tt.expectedErrContent {
+				if !strings.Contains(err.Error(), content) {
+					t.Errorf("error expected to contain %q, got %q", content, err.Error())
+				}
+			}
+			for _, content := range tt.unexpectedContent {
+				if strings.Contains(err.Error(), content) {
+					t.Errorf("error not expected to contain %q, got %q", content, err.Error())
+				}
+			}
+		})
+	}
+}
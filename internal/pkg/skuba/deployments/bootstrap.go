@@ -19,4 +19,6 @@ package deployments
 
 type BootstrapConfiguration struct {
 	KubeadmExtraArgs map[string]string
+	// Registries is the registries configuration rendered on the node
+	Registries RegistriesConfiguration
 }
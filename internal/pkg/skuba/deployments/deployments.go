@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog"
 )
 
@@ -42,6 +43,12 @@ type Target struct {
 	Nodename string
 	Role     *Role
 	Cache    TargetCache
+	// DryRun is set when the states are only printed instead of applied, so
+	// actions must not change the node nor the cluster
+	DryRun bool
+	// Journal, when set, records the applied states and skips the ones
+	// already applied by a previous run
+	Journal *Journal
 }
 
 func (t *Target) Apply(data interface{}, states ...string) error {
@@ -53,7 +60,53 @@ func (t *Target) Apply(data interface{}, states ...string) error {
 		filteredStates = append(filteredStates, s)
 	}
 
-	return t.Actionable.Apply(data, filteredStates...)
+	if t.Journal == nil {
+		return t.Actionable.Apply(data, filteredStates...)
+	}
+
+	planErrs := []error{}
+	for _, s := range filteredStates {
+		applied, err := t.Journal.IsApplied(s, data)
+		if err != nil {
+			return err
+		}
+		if applied {
+			fmt.Printf("[journal] skipping state %s on %q, already applied\n", s, t.Target)
+			continue
+		}
+		if err := t.Actionable.Apply(data, s); err != nil {
+			if t.DryRun {
+				// keep planning the remaining states, as without a journal
+				planErrs = append(planErrs, err)
+				continue
+			}
+			return err
+		}
+		if t.DryRun {
+			continue
+		}
+		if err := t.Journal.Record(s, data); err != nil {
+			return err
+		}
+	}
+	return utilerrors.NewAggregate(planErrs)
+}
+
+// SetJournalTargetVersion ties the states recorded in the journal of the
+// target, if any, to the version the operation brings the node to
+func (t *Target) SetJournalTargetVersion(version string) {
+	if t.Journal != nil {
+		t.Journal.SetTargetVersion(version)
+	}
+}
+
+// FinishJournal discards the journal of the target once the whole operation
+// has been successfully applied
+func (t *Target) FinishJournal() error {
+	if t.Journal == nil || t.DryRun {
+		return nil
+	}
+	return t.Journal.Finish()
 }
 
 func (t *Target) UploadFile(sourcePath, targetPath string, perm os.FileMode) error {
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+const (
+	// EtcdStaticPodManifest is the location of the etcd static pod manifest on
+	// control plane nodes
+	EtcdStaticPodManifest = "/etc/kubernetes/manifests/etcd.yaml"
+	// EtcdRestoreStashDir is the location where the etcd and apiserver static
+	// pod manifests are kept while a snapshot is being restored
+	EtcdRestoreStashDir = "/etc/kubernetes/skuba-etcd-restore"
+)
+
+// EtcdRestoreConfiguration holds the information needed to restore an etcd
+// snapshot on a control plane node
+type EtcdRestoreConfiguration struct {
+	// SnapshotPath is the local path of the snapshot, streamed to the node
+	SnapshotPath        string
+	MemberName          string
+	PeerURL             string
+	InitialCluster      string
+	InitialClusterToken string
+	Image               string
+	DataDir             string
+	// BackupSuffix is appended to the name of the data directory being
+	// replaced, which is kept on the node
+	BackupSuffix string
+}
@@ -20,6 +20,7 @@ package deployments
 import (
 	"strings"
 
+	"github.com/pkg/errors"
 	"k8s.io/klog"
 )
 
@@ -30,19 +31,30 @@ const (
 	WorkerRole Role = iota
 )
 
-func MustGetRoleFromString(s string) (role Role) {
+func GetRoleFromString(s string) (Role, error) {
 	switch strings.ToLower(s) {
 	case "master":
-		role = MasterRole
+		return MasterRole, nil
 	case "worker":
-		role = WorkerRole
-	default:
-		klog.Fatalf("[join] invalid role provided: %q, 'master' or 'worker' are the only accepted roles", s)
+		return WorkerRole, nil
 	}
-	return
+	return WorkerRole, errors.Errorf("invalid role provided: %q, 'master' or 'worker' are the only accepted roles", s)
+}
+
+func MustGetRoleFromString(s string) Role {
+	role, err := GetRoleFromString(s)
+	if err != nil {
+		klog.Fatalf("[join] %s", err)
+	}
+	return role
 }
 
 type JoinConfiguration struct {
 	Role             Role
 	KubeadmExtraArgs map[string]string
+	// ImageRepository is the custom image registry of the cluster the pause
+	// image comes from, the images of skuba are used when it is empty
+	ImageRepository string
+	// Registries is the registries configuration rendered on the node
+	Registries RegistriesConfiguration
 }
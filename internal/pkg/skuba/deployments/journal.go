@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Operations whose states are recorded in a node journal
+const (
+	BootstrapOperation = "bootstrap"
+	JoinOperation      = "join"
+	UpgradeOperation   = "upgrade"
+)
+
+// JournalEntry is a state that has been successfully applied on a node
+type JournalEntry struct {
+	State             string    `json:"state"`
+	ConfigurationHash string    `json:"configurationHash"`
+	AppliedAt         time.Time `json:"appliedAt"`
+}
+
+// Journal records the states successfully applied on a node, so an
+// interrupted bootstrap, join or upgrade can be resumed without applying
+// again the states that already succeeded
+type Journal struct {
+	path      string
+	operation string
+	// targetVersion is the version the operation brings the node to, so
+	// resuming towards another version applies every state again
+	targetVersion string
+	Entries       []JournalEntry `json:"entries"`
+}
+
+// NewJournal returns the journal of the operation stored in path. When
+// resuming, the states recorded by a previous run are kept; otherwise the
+// journal left by a previous run is removed and the journal starts empty.
+func NewJournal(path, operation string, resume bool) (*Journal, error) {
+	journal := &Journal{path: path, operation: operation}
+	if !resume {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "could not remove journal %s", path)
+		}
+		return journal, nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return journal, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read journal %s", path)
+	}
+	if err := yaml.Unmarshal(contents, journal); err != nil {
+		return nil, errors.Wrapf(err, "could not parse journal %s", path)
+	}
+	return journal, nil
+}
+
+// SetTargetVersion ties the states recorded and looked up from now on to the
+// version the operation brings the node to
+func (j *Journal) SetTargetVersion(version string) {
+	j.targetVersion = version
+}
+
+// IsApplied returns whether the state has already been applied with the
+// same configuration
+func (j *Journal) IsApplied(state string, data interface{}) (bool, error) {
+	hash, err := j.configurationHash(state, data)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range j.Entries {
+		if entry.State == state && entry.ConfigurationHash == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Record stores the state as applied with the given configuration
+func (j *Journal) Record(state string, data interface{}) error {
+	hash, err := j.configurationHash(state, data)
+	if err != nil {
+		return err
+	}
+	j.Entries = append(j.Entries, JournalEntry{
+		State:             state,
+		ConfigurationHash: hash,
+		AppliedAt:         time.Now().UTC(),
+	})
+	contents, err := yaml.Marshal(j)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal journal")
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return errors.Wrapf(err, "could not create journal directory for %s", j.path)
+	}
+	if err := ioutil.WriteFile(j.path, contents, 0600); err != nil {
+		return errors.Wrapf(err, "could not write journal %s", j.path)
+	}
+	return nil
+}
+
+// Finish removes the journal once the whole operation succeeded, so a later
+// operation on the same node never skips any of its states
+func (j *Journal) Finish() error {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove journal %s", j.path)
+	}
+	j.Entries = nil
+	return nil
+}
+
+// configurationHash identifies the state applied with the data by the
+// operation towards the target version, as most states are applied without
+// any data
+func (j *Journal) configurationHash(state string, data interface{}) (string, error) {
+	contents, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not compute configuration hash for state %s", state)
+	}
+	prefix := fmt.Sprintf("%s\n%s\n%s\n", j.operation, j.targetVersion, state)
+	return fmt.Sprintf("%x", sha256.Sum256(append([]byte(prefix), contents...))), nil
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package deployments
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fakeActionable struct {
+	applied []string
+	failOn  string
+}
+
+func (f *fakeActionable) Apply(data interface{}, states ...string) error {
+	for _, state := range states {
+		if state == f.failOn {
+			return errors.New("state failed")
+		}
+		f.applied = append(f.applied, state)
+	}
+	return nil
+}
+
+func (f *fakeActionable) UploadFileContents(targetPath, contents string, perm os.FileMode) error {
+	return nil
+}
+
+func (f *fakeActionable) DownloadFileContents(sourcePath string) (string, error) {
+	return "", nil
+}
+
+func (f *fakeActionable) IsServiceEnabled(serviceName string) (bool, error) {
+	return true, nil
+}
+
+func TestJournalResume(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-journal")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	journalPath := filepath.Join(tmpDir, "journal", "my-node.yaml")
+	configuration := JoinConfiguration{Role: WorkerRole}
+	states := []string{"kubeadm.reset", "kubernetes.install-fresh-pkgs", "", "kubeadm.join"}
+
+	// first run fails on the last state
+	journal, err := NewJournal(journalPath, JoinOperation, false)
+	if err != nil {
+		t.Fatalf("unexpected error creating journal: %v", err)
+	}
+	actionable := &fakeActionable{failOn: "kubeadm.join"}
+	target := Target{Actionable: actionable, Target: "my-node", Journal: journal}
+	if err := target.Apply(configuration, states...); err == nil {
+		t.Fatal("expected apply to fail")
+	}
+	if expected := []string{"kubeadm.reset", "kubernetes.install-fresh-pkgs"}; !reflect.DeepEqual(actionable.applied, expected) {
+		t.Errorf("expected applied states %v, got %v", expected, actionable.applied)
+	}
+
+	// resuming only applies the failed state
+	journal, err = NewJournal(journalPath, JoinOperation, true)
+	if err != nil {
+		t.Fatalf("unexpected error loading journal: %v", err)
+	}
+	actionable = &fakeActionable{}
+	target = Target{Actionable: actionable, Target: "my-node", Journal: journal}
+	if err := target.Apply(configuration, states...); err != nil {
+		t.Fatalf("unexpected error resuming: %v", err)
+	}
+	if expected := []string{"kubeadm.join"}; !reflect.DeepEqual(actionable.applied, expected) {
+		t.Errorf("expected applied states %v, got %v", expected, actionable.applied)
+	}
+
+	// a different configuration applies every state again
+	actionable = &fakeActionable{}
+	target.Actionable = actionable
+	if err := target.Apply(JoinConfiguration{Role: MasterRole}, states...); err != nil {
+		t.Fatalf("unexpected error applying: %v", err)
+	}
+	if expected := []string{"kubeadm.reset", "kubernetes.install-fresh-pkgs", "kubeadm.join"}; !reflect.DeepEqual(actionable.applied, expected) {
+		t.Errorf("expected applied states %v, got %v", expected, actionable.applied)
+	}
+
+	// finishing the journal removes it
+	if err := target.FinishJournal(); err != nil {
+		t.Fatalf("unexpected error finishing journal: %v", err)
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("expected journal to be removed, got %v", err)
+	}
+}
+
+func TestJournalWithoutResume(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-journal")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	journalPath := filepath.Join(tmpDir, "my-node.yaml")
+
+	journal, err := NewJournal(journalPath, JoinOperation, false)
+	if err != nil {
+		t.Fatalf("unexpected error creating journal: %v", err)
+	}
+	if err := journal.Record("kubeadm.reset", nil); err != nil {
+		t.Fatalf("unexpected error recording state: %v", err)
+	}
+
+	journal, err = NewJournal(journalPath, JoinOperation, false)
+	if err != nil {
+		t.Fatalf("unexpected error creating journal: %v", err)
+	}
+	applied, err := journal.IsApplied("kubeadm.reset", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if applied {
+		t.Error("expected a new journal to not skip any state")
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("expected the previous journal to be removed, got %v", err)
+	}
+}
+
+func TestJournalScope(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-journal")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	journalPath := filepath.Join(tmpDir, "my-node-upgrade.yaml")
+
+	journal, err := NewJournal(journalPath, UpgradeOperation, false)
+	if err != nil {
+		t.Fatalf("unexpected error creating journal: %v", err)
+	}
+	journal.SetTargetVersion("1.18.6")
+	if err := journal.Record("kubeadm.upgrade.node", nil); err != nil {
+		t.Fatalf("unexpected error recording state: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		operation       string
+		targetVersion   string
+		expectedApplied bool
+	}{
+		{
+			name:            "same operation and target version",
+			operation:       UpgradeOperation,
+			targetVersion:   "1.18.6",
+			expectedApplied: true,
+		},
+		{
+			name:          "other target version",
+			operation:     UpgradeOperation,
+			targetVersion: "1.19.2",
+		},
+		{
+			name:          "other operation",
+			operation:     JoinOperation,
+			targetVersion: "1.18.6",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			journal, err := NewJournal(journalPath, tt.operation, true)
+			if err != nil {
+				t.Fatalf("unexpected error loading journal: %v", err)
+			}
+			journal.SetTargetVersion(tt.targetVersion)
+			applied, err := journal.IsApplied("kubeadm.upgrade.node", nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if applied != tt.expectedApplied {
+				t.Errorf("expected applied %t, got %t", tt.expectedApplied, applied)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+)
+
+// Target applies the states on the machine skuba is running on, running
+// their commands with os/exec instead of SSH
+type Target struct {
+	target *deployments.Target
+	states *ssh.Target
+}
+
+// GetDeployment returns the deployment of the machine skuba is running on,
+// identified by its hostname
+func GetDeployment(nodename string, role *deployments.Role) *deployments.Target {
+	res := deployments.Target{
+		Target:   targetName(),
+		Nodename: nodename,
+		Role:     role,
+	}
+	t := &Target{target: &res}
+	t.states = ssh.NewExecutorTarget(&res, t)
+	res.Actionable = t
+	return &res
+}
+
+// targetName returns the name used to identify the machine skuba is running
+// on
+func targetName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "localhost"
+	}
+	return hostname
+}
+
+// Apply applies the states in order, running their commands locally
+func (t *Target) Apply(data interface{}, states ...string) error {
+	return t.states.Apply(data, states...)
+}
+
+// UploadFileContents writes the contents to the local path
+func (t *Target) UploadFileContents(targetPath, contents string, perm os.FileMode) error {
+	if t.target.DryRun {
+		fmt.Printf("[dry-run] %s: upload %s (mode %04o, sha256 %x)\n", t.target.Target, targetPath, perm, sha256.Sum256([]byte(contents)))
+		return nil
+	}
+	klog.V(1).Infof("writing local file %q with contents", targetPath)
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return errors.Wrapf(err, "could not create directory for %s", targetPath)
+	}
+	if err := ioutil.WriteFile(targetPath, []byte(contents), perm); err != nil {
+		return errors.Wrapf(err, "could not write %s", targetPath)
+	}
+	// the permissions of an already existing file are not changed by WriteFile
+	return os.Chmod(targetPath, perm)
+}
+
+// DownloadFileContents reads the contents of the local path
+func (t *Target) DownloadFileContents(sourcePath string) (string, error) {
+	klog.V(1).Infof("reading local file %q contents", sourcePath)
+	contents, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+// IsServiceEnabled returns if a local service is enabled
+func (t *Target) IsServiceEnabled(serviceName string) (bool, error) {
+	klog.V(2).Infof("checking if %s is enabled", serviceName)
+	err := exec.Command("systemctl", "is-enabled", serviceName).Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Execute runs the command of a state on the machine skuba is running on,
+// with the same shell semantics a command has through SSH
+func (t *Target) Execute(silent bool, stdin io.Reader, command string) (stdout string, stderr string, error error) {
+	cmd := exec.Command("sh", "-c", command)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	stdoutReader, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", "", err
+	}
+	stderrReader, err := cmd.StderrPipe()
+	if err != nil {
+		return "", "", err
+	}
+	if !silent {
+		klog.V(2).Infof("running local command: %q", command)
+	}
+	if err := cmd.Start(); err != nil {
+		return "", "", err
+	}
+	stdoutChan := make(chan string)
+	stderrChan := make(chan string)
+	go readerStreamer(stdoutReader, stdoutChan, !silent)
+	go readerStreamer(stderrReader, stderrChan, true)
+	// pipes are closed by Wait, so they have to be fully read first
+	stdout = <-stdoutChan
+	stderr = <-stderrChan
+	if err := cmd.Wait(); err != nil {
+		return "", "", err
+	}
+	return stdout, stderr, nil
+}
+
+// readerStreamer joins the lines of the output the same way the SSH target
+// does, logging them when verbose
+func readerStreamer(reader io.Reader, outputChan chan<- string, verbose bool) {
+	result := bytes.Buffer{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		result.Write([]byte(scanner.Text()))
+		if verbose {
+			klog.V(2).Infof("%s", scanner.Text())
+		}
+	}
+	outputChan <- result.String()
+}
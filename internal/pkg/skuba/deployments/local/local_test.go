@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func newTestDeployment(dryRun bool) *deployments.Target {
+	role := deployments.WorkerRole
+	d := GetDeployment("my-node", &role)
+	d.DryRun = dryRun
+	return d
+}
+
+func TestLocalFileContents(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-local-target")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	d := newTestDeployment(false)
+	targetPath := filepath.Join(tmpDir, "some", "dir", "file.conf")
+	contents := "some contents\nwith several lines\n"
+	if err := d.UploadFileContents(targetPath, contents, 0600); err != nil {
+		t.Fatalf("unexpected error uploading file: %v", err)
+	}
+	info, err := os.Stat(targetPath)
+	if err != nil {
+		t.Fatalf("uploaded file not found: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+	}
+	gotContents, err := d.DownloadFileContents(targetPath)
+	if err != nil {
+		t.Fatalf("unexpected error downloading file: %v", err)
+	}
+	if gotContents != contents {
+		t.Errorf("expected contents %q, got %q", contents, gotContents)
+	}
+}
+
+func TestLocalExecute(t *testing.T) {
+	target := &Target{}
+	stdout, _, err := target.Execute(false, strings.NewReader("hello"), "cat; echo ' world'")
+	if err != nil {
+		t.Fatalf("unexpected error running command: %v", err)
+	}
+	if stdout != "hello world" {
+		t.Errorf("expected output %q, got %q", "hello world", stdout)
+	}
+	_, _, err = target.Execute(false, nil, "exit 3")
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit status 3, got %v", err)
+	}
+}
+
+func TestLocalDryRun(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-local-target")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	existingPath := filepath.Join(tmpDir, "existing.conf")
+	if err := ioutil.WriteFile(existingPath, []byte("existing"), 0600); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	d := newTestDeployment(true)
+	uploadedPath := filepath.Join(tmpDir, "uploaded.conf")
+	if err := d.UploadFileContents(uploadedPath, "contents", 0644); err != nil {
+		t.Fatalf("unexpected error uploading file: %v", err)
+	}
+	if _, err := os.Stat(uploadedPath); !os.IsNotExist(err) {
+		t.Errorf("expected %s to not be created in dry run mode", uploadedPath)
+	}
+
+	// files are still read
+	contents, err := d.DownloadFileContents(existingPath)
+	if err != nil {
+		t.Fatalf("unexpected error downloading file: %v", err)
+	}
+	if contents != "existing" {
+		t.Errorf("expected contents %q, got %q", "existing", contents)
+	}
+}
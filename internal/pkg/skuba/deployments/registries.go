@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+package deployments
+
+// RegistriesConfiguration is the registries configuration of the cluster
+// definition folder, rendered for the nodes. The zero value removes the
+// registries configuration from the node.
+type RegistriesConfiguration struct {
+	// RegistriesConf is the contents of the containers registries
+	// configuration
+	RegistriesConf string
+	// AuthFile is the contents of the credentials file CRI-O pulls the
+	// images with
+	AuthFile string
+}
@@ -23,6 +23,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/registries"
 	"github.com/SUSE/skuba/pkg/skuba"
 	"github.com/pkg/errors"
 )
@@ -31,6 +33,8 @@ func init() {
 	stateMap["cri.configure"] = criConfigure
 	stateMap["cri.sysconfig"] = criSysconfig
 	stateMap["cri.start"] = criStart
+	stateMap["cri.registries"] = criRegistries
+	stateMap["cri.reload"] = criReload
 }
 
 func criConfigure(t *Target, data interface{}) error {
@@ -95,3 +99,68 @@ func criStart(t *Target, data interface{}) error {
 	_, _, err := t.ssh("systemctl", "enable", "--now", "crio")
 	return err
 }
+
+// criRegistries renders the registries configuration of the cluster
+// definition folder into the registry mirrors and credentials of the node,
+// or removes them when the cluster definition folder has none.
+func criRegistries(t *Target, data interface{}) error {
+	var configuration deployments.RegistriesConfiguration
+	switch stateData := data.(type) {
+	case deployments.RegistriesConfiguration:
+		configuration = stateData
+	case deployments.BootstrapConfiguration:
+		configuration = stateData.Registries
+	case deployments.JoinConfiguration:
+		configuration = stateData.Registries
+	default:
+		return errors.New("couldn't access registries configuration")
+	}
+
+	// crio only reads the path of the credentials file when it starts
+	_, _, err := t.silentQuerySsh("test", "-f", registries.CriConfFile)
+	criConfPresent := err == nil
+
+	if configuration.RegistriesConf == "" {
+		return criRemoveRegistries(t, criConfPresent)
+	}
+
+	if err := t.target.UploadFileContents(registries.RegistriesConfFile, configuration.RegistriesConf, 0644); err != nil {
+		return err
+	}
+	if err := t.target.UploadFileContents(registries.AuthFile, configuration.AuthFile, 0600); err != nil {
+		return err
+	}
+	if err := t.target.UploadFileContents(registries.CriConfFile, registries.CriConf, 0644); err != nil {
+		return err
+	}
+	if criConfPresent {
+		return nil
+	}
+	_, _, err = t.ssh("systemctl", "try-restart", "crio")
+	return err
+}
+
+// criRemoveRegistries removes the registries configuration rendered by
+// criRegistries from the node. The containers registries configuration is
+// only removed when it was rendered by skuba.
+func criRemoveRegistries(t *Target, criConfPresent bool) error {
+	files := []string{registries.AuthFile, registries.CriConfFile}
+	if _, _, err := t.silentQuerySsh("grep", "-qs", fmt.Sprintf("%q", registries.RegistriesConfMarker), registries.RegistriesConfFile); err == nil {
+		files = append(files, registries.RegistriesConfFile)
+	}
+	if _, _, err := t.ssh("rm", append([]string{"-f"}, files...)...); err != nil {
+		return err
+	}
+	if !criConfPresent {
+		return nil
+	}
+	// crio keeps pulling with the removed credentials file until restarted
+	_, _, err := t.ssh("systemctl", "try-restart", "crio")
+	return err
+}
+
+// criReload makes a running crio read its registries configuration again.
+func criReload(t *Target, data interface{}) error {
+	_, _, err := t.ssh("systemctl", "try-reload-or-restart", "crio")
+	return err
+}
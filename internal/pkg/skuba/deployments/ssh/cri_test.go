@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestCriRegistries(t *testing.T) {
+	tests := []struct {
+		name             string
+		data             interface{}
+		expectedCommands []string
+		expectedStdins   []string
+		expectedErr      string
+	}{
+		{
+			name: "configuration rendered on the node",
+			data: deployments.RegistriesConfiguration{RegistriesConf: "registries", AuthFile: "auths"},
+			expectedCommands: []string{
+				"test -f /etc/crio/crio.conf.d/10-registries.conf",
+				"mkdir -p /etc/containers/",
+				"install -m 0644 /dev/null /etc/containers/registries.conf",
+				"base64 -d -w0 > /etc/containers/registries.conf",
+				"mkdir -p /etc/crio/",
+				"install -m 0600 /dev/null /etc/crio/registries-auth.json",
+				"base64 -d -w0 > /etc/crio/registries-auth.json",
+				"mkdir -p /etc/crio/crio.conf.d/",
+				"install -m 0644 /dev/null /etc/crio/crio.conf.d/10-registries.conf",
+				"base64 -d -w0 > /etc/crio/crio.conf.d/10-registries.conf",
+			},
+			expectedStdins: []string{"registries", "auths", `global_auth_file = "/etc/crio/registries-auth.json"`},
+		},
+		{
+			name: "configuration removed from the node",
+			data: deployments.JoinConfiguration{},
+			expectedCommands: []string{
+				"test -f /etc/crio/crio.conf.d/10-registries.conf",
+				`grep -qs "This file is managed by CaaSP skuba, from the registries configuration" /etc/containers/registries.conf`,
+				"rm -f /etc/crio/registries-auth.json /etc/crio/crio.conf.d/10-registries.conf /etc/containers/registries.conf",
+				"systemctl try-restart crio",
+			},
+		},
+		{
+			name:        "unexpected state data",
+			data:        nil,
+			expectedErr: "couldn't access registries configuration",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &recordingExecutor{}
+			d := newExecutorTestDeployment(executor, false)
+			err := criRegistries(d.Actionable.(*Target), tt.data)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(executor.commands, "\n") != strings.Join(tt.expectedCommands, "\n") {
+				t.Errorf("expected commands %v, got %v", tt.expectedCommands, executor.commands)
+			}
+			if len(executor.stdins) != len(tt.expectedStdins) {
+				t.Fatalf("expected %d uploaded files, got %d", len(tt.expectedStdins), len(executor.stdins))
+			}
+			for i, expected := range tt.expectedStdins {
+				contents, err := base64.StdEncoding.DecodeString(executor.stdins[i])
+				if err != nil {
+					t.Fatalf("unexpected error decoding uploaded file: %v", err)
+				}
+				if !strings.Contains(string(contents), expected) {
+					t.Errorf("expected uploaded file %d to contain %q, got %q", i, expected, contents)
+				}
+			}
+		})
+	}
+}
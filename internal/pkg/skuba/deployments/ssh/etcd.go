@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+const (
+	etcdManifestsDir      = "/etc/kubernetes/manifests"
+	etcdRestoreDir        = "/var/lib/skuba-etcd-restore"
+	etcdRestoreManifest   = etcdManifestsDir + "/skuba-etcd-restore.yaml"
+	etcdRestoreTimeout    = 300
+	etcdControlPlaneWait  = 120
+	etcdRestoreDoneMarker = etcdRestoreDir + "/done"
+)
+
+var etcdRestoreStoppedManifests = []string{"etcd.yaml", "kube-apiserver.yaml"}
+
+func init() {
+	stateMap["etcd.restore.stop-control-plane"] = etcdRestoreStopControlPlane
+	stateMap["etcd.restore.snapshot"] = etcdRestoreSnapshot
+	stateMap["etcd.restore.start-control-plane"] = etcdRestoreStartControlPlane
+}
+
+// etcdRestoreStopControlPlane moves the etcd and apiserver static pod
+// manifests away, so the kubelet stops them
+func etcdRestoreStopControlPlane(t *Target, data interface{}) error {
+	if _, _, err := t.ssh("mkdir", "-p", deployments.EtcdRestoreStashDir); err != nil {
+		return err
+	}
+	for _, manifest := range etcdRestoreStoppedManifests {
+		source := filepath.Join(etcdManifestsDir, manifest)
+		if _, _, err := t.ssh(fmt.Sprintf("if [ -f %s ]; then mv %s %s/; fi", source, source, deployments.EtcdRestoreStashDir)); err != nil {
+			return err
+		}
+	}
+	_, _, err := t.ssh(fmt.Sprintf("timeout %d sh -c \"while crictl ps -q --name ^etcd$ | grep -q . || crictl ps -q --name ^kube-apiserver$ | grep -q .; do sleep 2; done\"", etcdControlPlaneWait))
+	if err != nil {
+		return errors.Wrap(err, "etcd and kube-apiserver containers did not stop")
+	}
+	return nil
+}
+
+// etcdRestoreSnapshot restores the snapshot in a new data directory with a
+// static pod using the etcd image, and replaces the etcd data directory with it
+func etcdRestoreSnapshot(t *Target, data interface{}) error {
+	restoreConfiguration, ok := data.(deployments.EtcdRestoreConfiguration)
+	if !ok {
+		return errors.New("couldn't access etcd restore configuration")
+	}
+
+	restoredDataDir := filepath.Join(etcdRestoreDir, "data")
+	snapshotPath := filepath.Join(etcdRestoreDir, "snapshot.db")
+	if _, _, err := t.ssh("rm", "-rf", etcdRestoreDir); err != nil {
+		return err
+	}
+	if err := t.streamLocalFile(restoreConfiguration.SnapshotPath, snapshotPath, 0600); err != nil {
+		return err
+	}
+	manifest, err := etcdRestorePodManifest(restoreConfiguration, snapshotPath, restoredDataDir)
+	if err != nil {
+		return err
+	}
+	if err := t.target.UploadFileContents(etcdRestoreManifest, manifest, 0600); err != nil {
+		return err
+	}
+	_, _, waitErr := t.ssh(fmt.Sprintf("timeout %d sh -c \"until [ -f %s ]; do sleep 2; done\"", etcdRestoreTimeout, etcdRestoreDoneMarker))
+	if _, _, err := t.ssh("rm", "-f", etcdRestoreManifest); err != nil {
+		return err
+	}
+	if waitErr != nil {
+		return errors.Wrap(waitErr, "etcd snapshot restore did not complete, check the skuba-etcd-restore container logs with crictl")
+	}
+
+	dataDirBackup := fmt.Sprintf("%s.%s", restoreConfiguration.DataDir, restoreConfiguration.BackupSuffix)
+	if _, _, err := t.ssh(fmt.Sprintf("if [ -d %s ]; then mv %s %s; fi", restoreConfiguration.DataDir, restoreConfiguration.DataDir, dataDirBackup)); err != nil {
+		return err
+	}
+	if _, _, err := t.ssh("mv", restoredDataDir, restoreConfiguration.DataDir); err != nil {
+		return err
+	}
+	_, _, err = t.ssh("rm", "-rf", etcdRestoreDir)
+	return err
+}
+
+func etcdRestorePodManifest(restoreConfiguration deployments.EtcdRestoreConfiguration, snapshotPath, restoredDataDir string) (string, error) {
+	hostPathType := corev1.HostPathDirectoryOrCreate
+	pod := corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "skuba-etcd-restore",
+			Namespace: metav1.NamespaceSystem,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "skuba-etcd-restore",
+					Image: restoreConfiguration.Image,
+					Command: []string{
+						"/bin/sh", "-c",
+						fmt.Sprintf("etcdctl snapshot restore %s --name %s --initial-cluster %s --initial-cluster-token %s --initial-advertise-peer-urls %s --data-dir %s && touch %s",
+							snapshotPath,
+							restoreConfiguration.MemberName,
+							restoreConfiguration.InitialCluster,
+							restoreConfiguration.InitialClusterToken,
+							restoreConfiguration.PeerURL,
+							restoredDataDir,
+							etcdRestoreDoneMarker),
+					},
+					Env: []corev1.EnvVar{
+						{
+							Name:  "ETCDCTL_API",
+							Value: "3",
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "skuba-etcd-restore",
+							MountPath: etcdRestoreDir,
+						},
+					},
+				},
+			},
+			HostNetwork:   true,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Volumes: []corev1.Volume{
+				{
+					Name: "skuba-etcd-restore",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{
+							Path: etcdRestoreDir,
+							Type: &hostPathType,
+						},
+					},
+				},
+			},
+		},
+	}
+	manifest, err := yaml.Marshal(pod)
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal etcd restore pod manifest")
+	}
+	return string(manifest), nil
+}
+
+// etcdRestoreStartControlPlane moves back the etcd and apiserver static pod
+// manifests, so the kubelet starts them again
+func etcdRestoreStartControlPlane(t *Target, data interface{}) error {
+	for _, manifest := range etcdRestoreStoppedManifests {
+		source := filepath.Join(deployments.EtcdRestoreStashDir, manifest)
+		if _, _, err := t.ssh(fmt.Sprintf("if [ -f %s ]; then mv %s %s/; fi", source, source, etcdManifestsDir)); err != nil {
+			return err
+		}
+	}
+	_, _, err := t.ssh("rm", "-rf", deployments.EtcdRestoreStashDir)
+	return err
+}
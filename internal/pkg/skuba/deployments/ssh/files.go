@@ -18,17 +18,23 @@
 package ssh
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"os"
 	"path"
 
+	"github.com/pkg/errors"
 	"k8s.io/klog"
 )
 
 // UploadFileContents creates a file with the content sent
 // into a target system's specific path.
 func (t *Target) UploadFileContents(targetPath, contents string, perm os.FileMode) error {
+	if t.target.DryRun {
+		fmt.Printf("[dry-run] %s: upload %s (mode %04o, sha256 %x)\n", t.target.Target, targetPath, perm, sha256.Sum256([]byte(contents)))
+		return nil
+	}
 	klog.V(1).Infof("uploading to remote file %q with contents", targetPath)
 	dir, _ := path.Split(targetPath)
 	encodedContents := base64.StdEncoding.EncodeToString([]byte(contents))
@@ -42,10 +48,48 @@ func (t *Target) UploadFileContents(targetPath, contents string, perm os.FileMod
 	return err
 }
 
+// streamLocalFile uploads a local file through the standard input of the
+// command writing it on the target, without reading it in memory
+func (t *Target) streamLocalFile(sourcePath, targetPath string, perm os.FileMode) error {
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", sourcePath)
+	}
+	defer f.Close()
+	if t.target.DryRun {
+		fmt.Printf("[dry-run] %s: upload %s (mode %04o) from %s\n", t.target.Target, targetPath, perm, sourcePath)
+		return nil
+	}
+	klog.V(1).Infof("streaming local file %q to remote file %q", sourcePath, targetPath)
+	dir, _ := path.Split(targetPath)
+	if _, _, err := t.silentSsh("mkdir", "-p", dir); err != nil {
+		return err
+	}
+	if _, _, err := t.silentSsh("install", "-m", fmt.Sprintf("%04o", perm), "/dev/null", targetPath); err != nil {
+		return err
+	}
+	_, _, err = t.silentSshWithStdinReader(f, "cat", fmt.Sprintf("> %s", targetPath))
+	return err
+}
+
+// uploadGeneratedFile uploads a local file that skuba generates while
+// applying the states. In dry run mode, such a file may not have been
+// generated, so its upload is only reported.
+func (t *Target) uploadGeneratedFile(sourcePath, targetPath string) error {
+	f, err := os.Stat(sourcePath)
+	if os.IsNotExist(err) && t.target.DryRun {
+		fmt.Printf("[dry-run] %s: upload %s, generated as %s when applying the states\n", t.target.Target, targetPath, sourcePath)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return t.target.UploadFile(sourcePath, targetPath, f.Mode())
+}
+
 // DownloadFileContents gets the content of a file in a target system
 func (t *Target) DownloadFileContents(sourcePath string) (string, error) {
 	klog.V(1).Infof("downloading remote file %q contents", sourcePath)
-	stdout, _, err := t.silentSsh("base64", "-w0", sourcePath)
+	stdout, _, err := t.silentQuerySsh("base64", "-w0", sourcePath)
 	if err != nil {
 		return "", err
 	}
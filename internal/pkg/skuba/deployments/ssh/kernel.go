@@ -87,7 +87,7 @@ func kernelConfigureParameters(t *Target, data interface{}) error {
 }
 
 func infoModule(t *Target, module string) error {
-	if _, _, err := t.ssh(fmt.Sprintf("modinfo %s", module)); err != nil {
+	if _, _, err := t.querySsh(fmt.Sprintf("modinfo %s", module)); err != nil {
 		return err
 	}
 	return nil
@@ -48,13 +48,8 @@ func kubeadmInit(t *Target, data interface{}) error {
 	if err != nil {
 		return err
 	}
-	f, err := os.Stat(skubaconstants.KubeadmInitConfFile())
-	if err != nil {
-		return err
-	}
 	remoteKubeadmInitConfFile := filepath.Join(tempDir, skubaconstants.KubeadmInitConfFile())
-	err = t.target.UploadFile(skubaconstants.KubeadmInitConfFile(), remoteKubeadmInitConfFile, f.Mode())
-	if err != nil {
+	if err := t.uploadGeneratedFile(skubaconstants.KubeadmInitConfFile(), remoteKubeadmInitConfFile); err != nil {
 		return err
 	}
 	defer func() {
@@ -76,18 +71,27 @@ func kubeadmInit(t *Target, data interface{}) error {
 }
 
 func kubeadmJoin(t *Target, data interface{}) error {
-	api, err := kubernetes.GetAdminClientSet()
-	if err != nil {
-		return errors.Wrap(err, "could not retrieve the clientset from kubernetes")
-	}
 	joinConfiguration, ok := data.(deployments.JoinConfiguration)
 	if !ok {
 		return errors.New("couldn't access join configuration")
 	}
 
-	configPath, err := join.ConfigPath(api, joinConfiguration.Role, t.target)
-	if err != nil {
-		return errors.Wrap(err, "unable to configure path")
+	var configPath string
+	if t.target.DryRun {
+		// neither create a bootstrap token nor render the machine configuration
+		configPath = skubaconstants.MachineConfFile(t.target.Target)
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			configPath = skubaconstants.TemplatePathForRole(joinConfiguration.Role)
+		}
+	} else {
+		api, err := kubernetes.GetAdminClientSet()
+		if err != nil {
+			return errors.Wrap(err, "could not retrieve the clientset from kubernetes")
+		}
+		configPath, err = join.ConfigPath(api, joinConfiguration, t.target)
+		if err != nil {
+			return errors.Wrap(err, "unable to configure path")
+		}
 	}
 
 	tempDir, _, err := t.ssh("mktemp", "-d")
@@ -19,6 +19,7 @@ package ssh
 
 import (
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
@@ -47,22 +48,14 @@ func init() {
 func kubeletUploadRootCert(t *Target, data interface{}) error {
 	// Upload root ca cert
 	caCertPath := filepath.Join(skuba.PkiDir(), kubernetes.KubeletCACertName)
-	f, err := os.Stat(caCertPath)
-	if err != nil {
-		return err
-	}
-	if err := t.target.UploadFile(caCertPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletCACertName), f.Mode()); err != nil {
+	if err := t.uploadGeneratedFile(caCertPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletCACertName)); err != nil {
 		return err
 	}
 
 	// Upload root ca key on control plane node only
 	if *t.target.Role == deployments.MasterRole {
 		caKeyPath := filepath.Join(skuba.PkiDir(), kubernetes.KubeletCAKeyName)
-		f, err = os.Stat(caKeyPath)
-		if err != nil {
-			return err
-		}
-		if err := t.target.UploadFile(caKeyPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletCAKeyName), f.Mode()); err != nil {
+		if err := t.uploadGeneratedFile(caKeyPath, filepath.Join(kubernetes.KubeletCertAndKeyDir, kubernetes.KubeletCAKeyName)); err != nil {
 			return err
 		}
 	}
@@ -71,6 +64,14 @@ func kubeletUploadRootCert(t *Target, data interface{}) error {
 }
 
 func kubeletCreateAndUploadServerCert(t *Target, data interface{}) error {
+	if t.target.DryRun {
+		// neither generate the certificate nor save it locally
+		for _, name := range []string{kubernetes.KubeletServerCertName, kubernetes.KubeletServerKeyName} {
+			fmt.Printf("[dry-run] %s: upload %s, generated for node %s\n", t.target.Target, filepath.Join(kubernetes.KubeletCertAndKeyDir, name), t.target.Nodename)
+		}
+		return nil
+	}
+
 	// Read kubelet root ca certificate and key
 	caCert, caKey, err := pkiutil.TryLoadCertAndKeyFromDisk(skuba.PkiDir(), kubernetes.KubeletCACertAndKeyBaseName)
 	if err != nil {
@@ -86,7 +87,7 @@ func kubeletCreateAndUploadServerCert(t *Target, data interface{}) error {
 	}
 
 	// Create AltNames with defaults DNSNames/IPs
-	stdout, _, err := t.silentSsh("hostname", "-I")
+	stdout, _, err := t.silentQuerySsh("hostname", "-I")
 	if err != nil {
 		return err
 	}
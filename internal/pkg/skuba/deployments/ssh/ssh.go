@@ -25,12 +25,12 @@ import (
 	"io"
 	"net"
 	"os"
+	"os/exec"
 	"path"
 	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
-	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
@@ -95,26 +95,72 @@ type Target struct {
 	bastionUser  string
 	bastionPort  int
 	verboseLevel string
+	executor     Executor
 	client       *ssh.Client
 }
 
+// Executor runs the commands of the states on a node by other means than SSH
+type Executor interface {
+	Execute(silent bool, stdin io.Reader, command string) (stdout string, stderr string, error error)
+}
+
+// NewExecutorTarget returns a target applying the states on the node of the
+// deployment, running their commands with the executor instead of SSH
+func NewExecutorTarget(target *deployments.Target, executor Executor) *Target {
+	return &Target{
+		target:   target,
+		executor: executor,
+	}
+}
+
 // GetFlags adds init flags bound to the config to the specified flagset
 func (t *Target) GetFlags() *flag.FlagSet {
+	flagSet := t.GetInventoryFlags()
+	flagSet.StringVarP(&t.targetName, "target", "t", "", "IP or FQDN of the node to connect to using SSH (required unless --inventory)")
+	return flagSet
+}
+
+// GetInventoryFlags adds the flags bound to the config that apply to every
+// node of an inventory, leaving out the target node
+func (t *Target) GetInventoryFlags() *flag.FlagSet {
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	flagSet.StringVarP(&t.bastionUser, "bastion-user", "", "", "User identity used to connect to the bastion using SSH (default to target user)")
 	flagSet.IntVarP(&t.bastionPort, "bastion-port", "", defSSHPort, "Port to connect to the bastion using SSH")
 	flagSet.StringVarP(&t.bastion, "bastion", "", "", "IP or FQDN of the bastion to connect to the other nodes using SSH")
-	flagSet.StringVarP(&t.user, "user", "u", "", "User identity used to connect to target using SSH")
+	flagSet.StringVarP(&t.user, "user", "u", "", "User identity used to connect to target using SSH (required unless set by the inventory)")
 	flagSet.BoolVarP(&t.sudo, "sudo", "s", false, "Run remote command via sudo")
 	flagSet.IntVarP(&t.port, "port", "p", defSSHPort, "Port to connect to using SSH")
-	flagSet.StringVarP(&t.targetName, "target", "t", "", "IP or FQDN of the node to connect to using SSH (required)")
-
-	_ = cobra.MarkFlagRequired(flagSet, "target")
-	_ = cobra.MarkFlagRequired(flagSet, "user")
 
 	return flagSet
 }
 
+// Validate checks that the flags required to reach the target have been set
+func (t *Target) Validate() error {
+	missingFlagNames := []string{}
+	if t.targetName == "" {
+		missingFlagNames = append(missingFlagNames, "target")
+	}
+	if t.user == "" {
+		missingFlagNames = append(missingFlagNames, "user")
+	}
+	if len(missingFlagNames) > 0 {
+		return errors.Errorf(`required flag(s) "%s" not set`, strings.Join(missingFlagNames, `", "`))
+	}
+	return nil
+}
+
+// ForNode returns a copy of the target settings pointing to another node; the
+// user is only overridden when one is given
+func (t *Target) ForNode(targetName, user string) *Target {
+	target := *t
+	target.targetName = targetName
+	if user != "" {
+		target.user = user
+	}
+	target.client = nil
+	return &target
+}
+
 func (t Target) String() string {
 	return fmt.Sprintf("%s@%s:%d", t.user, t.target.Target, t.port)
 }
@@ -139,22 +185,58 @@ func (t *Target) GetDeployment(nodename string, role *deployments.Role, verboseL
 }
 
 func (t *Target) silentSsh(command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(true, "", command, args...)
+	return t.internalSshWithStdin(true, false, nil, command, args...)
 }
 
 func (t *Target) ssh(command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(false, "", command, args...)
+	return t.internalSshWithStdin(false, false, nil, command, args...)
 }
 
 func (t *Target) silentSshWithStdin(stdin string, command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(true, stdin, command, args...)
+	return t.internalSshWithStdin(true, false, stdinReader(stdin), command, args...)
 }
 
 func (t *Target) sshWithStdin(stdin string, command string, args ...string) (stdout string, stderr string, error error) {
-	return t.internalSshWithStdin(false, stdin, command, args...)
+	return t.internalSshWithStdin(false, false, stdinReader(stdin), command, args...)
 }
 
-func (t *Target) internalSshWithStdin(silent bool, stdin string, command string, args ...string) (stdout string, stderr string, error error) {
+// silentSshWithStdinReader streams stdin to the command, so large contents
+// are never held in memory
+func (t *Target) silentSshWithStdinReader(stdin io.Reader, command string, args ...string) (stdout string, stderr string, error error) {
+	return t.internalSshWithStdin(true, false, stdin, command, args...)
+}
+
+// silentQuerySsh runs a command that does not change the target, so it is
+// also run in dry run mode
+func (t *Target) silentQuerySsh(command string, args ...string) (stdout string, stderr string, error error) {
+	return t.internalSshWithStdin(true, true, nil, command, args...)
+}
+
+// querySsh runs a command that does not change the target, so it is also run
+// in dry run mode
+func (t *Target) querySsh(command string, args ...string) (stdout string, stderr string, error error) {
+	return t.internalSshWithStdin(false, true, nil, command, args...)
+}
+
+func stdinReader(stdin string) io.Reader {
+	if len(stdin) == 0 {
+		return nil
+	}
+	return strings.NewReader(stdin)
+}
+
+func (t *Target) internalSshWithStdin(silent bool, readOnly bool, stdin io.Reader, command string, args ...string) (stdout string, stderr string, error error) {
+	finalCommand := strings.Join(append([]string{command}, args...), " ")
+	if t.sudo {
+		finalCommand = fmt.Sprintf("sudo sh -c '%s'", finalCommand)
+	}
+	if t.target.DryRun && !readOnly {
+		fmt.Printf("[dry-run] %s: run %s\n", t.target.Target, finalCommand)
+		return "", "", nil
+	}
+	if t.executor != nil {
+		return t.executor.Execute(silent, stdin, finalCommand)
+	}
 	if t.client == nil {
 		if err := t.initClient(); err != nil {
 			return "", "", errors.Wrap(err, "failed to initialize client")
@@ -164,8 +246,8 @@ func (t *Target) internalSshWithStdin(silent bool, stdin string, command string,
 	if err != nil {
 		return "", "", err
 	}
-	if len(stdin) > 0 {
-		session.Stdin = bytes.NewBufferString(stdin)
+	if stdin != nil {
+		session.Stdin = stdin
 	}
 	stdoutReader, err := session.StdoutPipe()
 	if err != nil {
@@ -175,10 +257,6 @@ func (t *Target) internalSshWithStdin(silent bool, stdin string, command string,
 	if err != nil {
 		return "", "", err
 	}
-	finalCommand := strings.Join(append([]string{command}, args...), " ")
-	if t.sudo {
-		finalCommand = fmt.Sprintf("sudo sh -c '%s'", finalCommand)
-	}
 	if !silent {
 		klog.V(2).Infof("running command: %q", finalCommand)
 	}
@@ -209,6 +287,18 @@ func readerStreamer(reader io.Reader, outputChan chan<- string, description stri
 	outputChan <- result.String()
 }
 
+// exitStatus returns the exit status of a command that ran to completion,
+// either through SSH or through an executor using os/exec
+func exitStatus(err error) (int, bool) {
+	switch exitErr := err.(type) {
+	case *ssh.ExitError:
+		return exitErr.ExitStatus(), true
+	case *exec.ExitError:
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
 // initClient initializes the ssh client to the target
 func (t *Target) initClient() error {
 	socket := os.Getenv("SSH_AUTH_SOCK")
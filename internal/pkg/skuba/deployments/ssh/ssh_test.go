@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// recordingExecutor records the commands instead of running them
+type recordingExecutor struct {
+	commands []string
+	stdins   []string
+	outputs  map[string]string
+}
+
+func (e *recordingExecutor) Execute(silent bool, stdin io.Reader, command string) (string, string, error) {
+	e.commands = append(e.commands, command)
+	if stdin != nil {
+		contents, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return "", "", err
+		}
+		e.stdins = append(e.stdins, string(contents))
+	}
+	return e.outputs[command], "", nil
+}
+
+func newExecutorTestDeployment(executor Executor, dryRun bool) *deployments.Target {
+	role := deployments.MasterRole
+	d := &deployments.Target{
+		Target:   "my-node",
+		Nodename: "my-node",
+		Role:     &role,
+		DryRun:   dryRun,
+	}
+	d.Actionable = NewExecutorTarget(d, executor)
+	return d
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      Target
+		expectedErr string
+	}{
+		{
+			name:   "remote target",
+			target: Target{targetName: "10.0.0.1", user: "sles"},
+		},
+		{
+			name:        "remote target without user",
+			target:      Target{targetName: "10.0.0.1"},
+			expectedErr: `required flag(s) "user" not set`,
+		},
+		{
+			name:        "remote target without target and user",
+			target:      Target{},
+			expectedErr: `required flag(s) "target", "user" not set`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.target.Validate()
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("expected error %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestExecutorApply(t *testing.T) {
+	stateMap["test.executor-echo"] = func(t *Target, data interface{}) error {
+		_, _, err := t.ssh("echo", data.(string))
+		return err
+	}
+	defer delete(stateMap, "test.executor-echo")
+
+	executor := &recordingExecutor{}
+	d := newExecutorTestDeployment(executor, false)
+	if err := d.Apply("hello", "test.executor-echo"); err != nil {
+		t.Fatalf("unexpected error applying state: %v", err)
+	}
+	if len(executor.commands) != 1 || executor.commands[0] != "echo hello" {
+		t.Errorf("expected the state command to be run by the executor, got %v", executor.commands)
+	}
+}
+
+func TestDryRunApplyErrors(t *testing.T) {
+	applied := []string{}
+	stateMap["test.dry-run-fail"] = func(t *Target, data interface{}) error {
+		applied = append(applied, "test.dry-run-fail")
+		return errors.New("kubeadm-init.conf not found")
+	}
+	stateMap["test.dry-run-ok"] = func(t *Target, data interface{}) error {
+		applied = append(applied, "test.dry-run-ok")
+		return nil
+	}
+	defer delete(stateMap, "test.dry-run-fail")
+	defer delete(stateMap, "test.dry-run-ok")
+
+	d := newExecutorTestDeployment(&recordingExecutor{}, true)
+	err := d.Apply(nil, "test.dry-run-fail", "test.dry-run-ok", "test.dry-run-fail")
+	if err == nil {
+		t.Fatal("expected error planning failing states, got none")
+	}
+	if !strings.Contains(err.Error(), "failed to plan state test.dry-run-fail: kubeadm-init.conf not found") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(applied) != 3 {
+		t.Errorf("expected every state to be planned, got %v", applied)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	stateMap["test.dry-run"] = func(t *Target, data interface{}) error {
+		if err := t.UploadFileContents("/etc/some.conf", "contents", 0644); err != nil {
+			return err
+		}
+		if _, _, err := t.ZypperInstall("-kubernetes-1.18-kubeadm", "+kubernetes-1.19-kubeadm"); err != nil {
+			return err
+		}
+		if _, _, err := t.ssh("touch", "/some/file"); err != nil {
+			return err
+		}
+		_, err := t.DownloadFileContents("/etc/existing.conf")
+		return err
+	}
+	defer delete(stateMap, "test.dry-run")
+
+	executor := &recordingExecutor{}
+	d := newExecutorTestDeployment(executor, true)
+	if err := d.Apply(nil, "test.dry-run"); err != nil {
+		t.Fatalf("unexpected error applying state: %v", err)
+	}
+	// only the read only commands are run
+	expectedCommands := []string{"base64 -w0 /etc/existing.conf"}
+	if strings.Join(executor.commands, "\n") != strings.Join(expectedCommands, "\n") {
+		t.Errorf("expected commands %v, got %v", expectedCommands, executor.commands)
+	}
+}
+
+func TestStreamLocalFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-ssh")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	sourcePath := filepath.Join(tmpDir, "snapshot.db")
+	if err := ioutil.WriteFile(sourcePath, []byte("snapshot"), 0600); err != nil {
+		t.Fatalf("could not write source file: %v", err)
+	}
+
+	executor := &recordingExecutor{}
+	d := newExecutorTestDeployment(executor, false)
+	if err := d.Actionable.(*Target).streamLocalFile(sourcePath, "/var/lib/restore/snapshot.db", 0600); err != nil {
+		t.Fatalf("unexpected error streaming file: %v", err)
+	}
+	expectedCommands := []string{
+		"mkdir -p /var/lib/restore/",
+		"install -m 0600 /dev/null /var/lib/restore/snapshot.db",
+		"cat > /var/lib/restore/snapshot.db",
+	}
+	if strings.Join(executor.commands, "\n") != strings.Join(expectedCommands, "\n") {
+		t.Errorf("expected commands %v, got %v", expectedCommands, executor.commands)
+	}
+	if len(executor.stdins) != 1 || executor.stdins[0] != "snapshot" {
+		t.Errorf("expected the file to be streamed through stdin, got %v", executor.stdins)
+	}
+}
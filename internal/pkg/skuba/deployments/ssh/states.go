@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog"
 )
 
@@ -30,11 +31,24 @@ var (
 
 type Runner func(t *Target, data interface{}) error
 
+// Apply applies the states in order, stopping at the first failure. In dry
+// run mode, every state is planned and the states that could not be planned
+// are reported together at the end.
 func (t *Target) Apply(data interface{}, states ...string) error {
+	planErrs := []error{}
 	for _, stateName := range states {
 		klog.V(2).Infof("=== applying state %s ===", stateName)
+		if t.target.DryRun {
+			fmt.Printf("[dry-run] %s: state %s\n", t.target.Target, stateName)
+		}
 		if state, stateExists := stateMap[stateName]; stateExists {
 			if err := state(t, data); err != nil {
+				if t.target.DryRun {
+					// keep planning the remaining states
+					fmt.Printf("[dry-run] %s: state %s could not be planned: %s\n", t.target.Target, stateName, err)
+					planErrs = append(planErrs, errors.Wrapf(err, "failed to plan state %s", stateName))
+					continue
+				}
 				return errors.Wrapf(err, "failed to apply state %s", stateName)
 			}
 			klog.V(2).Infof("=== state %s applied successfully ===", stateName)
@@ -42,5 +56,5 @@ func (t *Target) Apply(data interface{}, states ...string) error {
 			return errors.New(fmt.Sprintf("state does not exist: %s", stateName))
 		}
 	}
-	return nil
+	return utilerrors.NewAggregate(planErrs)
 }
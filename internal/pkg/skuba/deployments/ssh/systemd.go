@@ -18,7 +18,6 @@
 package ssh
 
 import (
-	"golang.org/x/crypto/ssh"
 	"k8s.io/klog"
 )
 
@@ -26,10 +25,10 @@ import (
 func (t *Target) IsServiceEnabled(serviceName string) (bool, error) {
 	klog.V(2).Infof("checking if %s is enabled", serviceName)
 	isEnabled := true
-	_, _, err := t.silentSsh("systemctl", "is-enabled", serviceName)
+	_, _, err := t.silentQuerySsh("systemctl", "is-enabled", serviceName)
 	if err != nil {
-		t, isExitError := err.(*ssh.ExitError)
-		if isExitError && t.ExitStatus() == 1 {
+		status, isExitError := exitStatus(err)
+		if isExitError && status == 1 {
 			isEnabled = false
 			// the error is sane
 			err = nil
@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+// upgradeBackupPackagePatterns match the packages replaced by an upgrade
+var upgradeBackupPackagePatterns = []string{
+	"kubernetes*",
+	"cri-o*",
+	"cri-tools*",
+	"patterns-caasp-Node*",
+	"caasp-config",
+}
+
+func init() {
+	stateMap["upgrade.backup.save"] = upgradeBackupSave
+	stateMap["upgrade.backup.kubeadm-applied"] = upgradeBackupKubeadmApplied
+	stateMap["upgrade.rollback"] = upgradeRollback
+}
+
+func upgradeBackupSave(t *Target, data interface{}) error {
+	backup, ok := data.(deployments.UpgradeBackup)
+	if !ok {
+		return errors.New("couldn't access upgrade backup")
+	}
+
+	// a previous attempt of the same upgrade already recorded the state
+	// before any package was changed, which must not be overwritten
+	if previous, err := t.downloadUpgradeBackup(); err == nil && previous.FromVersion == backup.FromVersion && previous.ToVersion == backup.ToVersion {
+		fmt.Printf("Keeping the state recorded on %s before a previous attempt of this upgrade\n", t.target.Target)
+		return nil
+	}
+
+	packages, err := t.installedUpgradePackages()
+	if err != nil {
+		return err
+	}
+	backup.Packages = packages
+	backup.KubeletFiles = map[string]string{}
+	for _, file := range deployments.UpgradeBackupKubeletFiles {
+		contents, err := t.DownloadFileContents(file)
+		if err != nil {
+			// not every file exists on every node
+			continue
+		}
+		backup.KubeletFiles[file] = contents
+	}
+	backup.RecordedAt = time.Now().UTC()
+	return t.uploadUpgradeBackup(backup)
+}
+
+func upgradeBackupKubeadmApplied(t *Target, data interface{}) error {
+	if t.target.DryRun {
+		fmt.Printf("[dry-run] %s: record in %s that kubeadm upgrade apply was run\n", t.target.Target, deployments.UpgradeBackupFile)
+		return nil
+	}
+	backup, err := t.downloadUpgradeBackup()
+	if err != nil {
+		return err
+	}
+	backup.KubeadmUpgradeApplied = true
+	return t.uploadUpgradeBackup(*backup)
+}
+
+func upgradeRollback(t *Target, data interface{}) error {
+	backup, ok := data.(deployments.UpgradeBackup)
+	if !ok {
+		return errors.New("couldn't access upgrade backup")
+	}
+
+	installed, err := t.installedUpgradePackages()
+	if err != nil {
+		return err
+	}
+	pkgs := rollbackPackages(installed, backup.Packages)
+	if len(pkgs) > 0 {
+		if _, _, err := t.ZypperInstallOldPackages(pkgs...); err != nil {
+			return err
+		}
+	}
+
+	files := []string{}
+	for file := range backup.KubeletFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := t.UploadFileContents(file, backup.KubeletFiles[file], 0644); err != nil {
+			return errors.Wrapf(err, "could not restore %s", file)
+		}
+	}
+	return nil
+}
+
+// rollbackPackages returns the zypper arguments replacing the installed
+// packages by the recorded ones: the packages that were not installed are
+// removed, and the recorded packages are installed back with the version
+// they had
+func rollbackPackages(installed, recorded []string) []string {
+	installedByName := map[string]string{}
+	for _, pkg := range installed {
+		installedByName[packageName(pkg)] = pkg
+	}
+	recordedByName := map[string]string{}
+	for _, pkg := range recorded {
+		recordedByName[packageName(pkg)] = pkg
+	}
+	var pkgs []string
+	for _, pkg := range installed {
+		if _, found := recordedByName[packageName(pkg)]; !found {
+			pkgs = append(pkgs, fmt.Sprintf("-%s", packageName(pkg)))
+		}
+	}
+	for _, pkg := range recorded {
+		if installedByName[packageName(pkg)] != pkg {
+			pkgs = append(pkgs, fmt.Sprintf("+%s", pkg))
+		}
+	}
+	return pkgs
+}
+
+// packageName returns the name of a name-version-release package
+func packageName(nvr string) string {
+	name := nvr
+	for i := 0; i < 2; i++ {
+		if sep := strings.LastIndex(name, "-"); sep > 0 {
+			name = name[:sep]
+		}
+	}
+	return name
+}
+
+func (t *Target) installedUpgradePackages() ([]string, error) {
+	args := []string{"-qa", "--qf", `"%{NAME}-%{VERSION}-%{RELEASE}\n"`}
+	for _, pattern := range upgradeBackupPackagePatterns {
+		args = append(args, fmt.Sprintf("%q", pattern))
+	}
+	stdout, _, err := t.silentQuerySsh("rpm", args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list the installed packages")
+	}
+	packages := []string{}
+	for _, pkg := range strings.Split(stdout, "\n") {
+		if pkg = strings.TrimSpace(pkg); pkg != "" {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
+
+func (t *Target) downloadUpgradeBackup() (*deployments.UpgradeBackup, error) {
+	contents, err := t.DownloadFileContents(deployments.UpgradeBackupFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not download the upgrade backup")
+	}
+	backup := &deployments.UpgradeBackup{}
+	if err := json.Unmarshal([]byte(contents), backup); err != nil {
+		return nil, errors.Wrap(err, "could not parse the upgrade backup")
+	}
+	return backup, nil
+}
+
+func (t *Target) uploadUpgradeBackup(backup deployments.UpgradeBackup) error {
+	contents, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not marshal the upgrade backup")
+	}
+	return t.UploadFileContents(deployments.UpgradeBackupFile, string(contents), 0600)
+}
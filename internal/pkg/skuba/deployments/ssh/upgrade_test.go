@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package ssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRollbackPackages(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		recorded  []string
+		expected  []string
+	}{
+		{
+			name:      "stage two failed after replacing kubeadm",
+			installed: []string{"cri-o-1.17.3-4.1", "kubernetes-1.17-kubelet-1.17.13-1.1", "kubernetes-1.18-kubeadm-1.18.10-1.1", "kubernetes1.17-client-1.17.13-1.1"},
+			recorded:  []string{"cri-o-1.17.3-4.1", "kubernetes-1.17-kubeadm-1.17.13-1.1", "kubernetes-1.17-kubelet-1.17.13-1.1", "kubernetes1.17-client-1.17.13-1.1"},
+			expected:  []string{"-kubernetes-1.18-kubeadm", "+kubernetes-1.17-kubeadm-1.17.13-1.1"},
+		},
+		{
+			name:      "all packages replaced",
+			installed: []string{"cri-o-1.18.4-1.1", "kubernetes-1.18-kubeadm-1.18.10-1.1"},
+			recorded:  []string{"cri-o-1.17.3-4.1", "kubernetes-1.17-kubeadm-1.17.13-1.1"},
+			expected:  []string{"-kubernetes-1.18-kubeadm", "+cri-o-1.17.3-4.1", "+kubernetes-1.17-kubeadm-1.17.13-1.1"},
+		},
+		{
+			name:      "patch upgrade updated the packages in place",
+			installed: []string{"cri-o-1.18.4-1.1", "kubernetes-1.18-kubeadm-1.18.10-1.1"},
+			recorded:  []string{"cri-o-1.18.4-1.1", "kubernetes-1.18-kubeadm-1.18.6-2.1"},
+			expected:  []string{"+kubernetes-1.18-kubeadm-1.18.6-2.1"},
+		},
+		{
+			name:      "nothing changed",
+			installed: []string{"cri-o-1.17.3-4.1", "kubernetes-1.17-kubeadm-1.17.13-1.1"},
+			recorded:  []string{"cri-o-1.17.3-4.1", "kubernetes-1.17-kubeadm-1.17.13-1.1"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			got := rollbackPackages(tt.installed, tt.recorded)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
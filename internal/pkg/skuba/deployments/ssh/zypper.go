@@ -17,11 +17,53 @@
 
 package ssh
 
+import (
+	"fmt"
+	"strings"
+)
+
 // ZypperInstall runs a zypper command to install an arbitrary list of packages,
 // wrapped with the right userdata and parameters
 func (t *Target) ZypperInstall(packages ...string) (stdout string, stderr string, error error) {
+	return t.zypperInstall(nil, packages...)
+}
+
+// ZypperInstallOldPackages runs a zypper install allowed to downgrade the
+// packages to the given versions
+func (t *Target) ZypperInstallOldPackages(packages ...string) (stdout string, stderr string, error error) {
+	return t.zypperInstall([]string{"--oldpackage"}, packages...)
+}
+
+func (t *Target) zypperInstall(options []string, packages ...string) (stdout string, stderr string, error error) {
+	if t.target.DryRun {
+		printZypperPlan(t.target.Target, packages)
+	}
 	var cliArgs []string
-	cliArgs = append(cliArgs, "--userdata", "skuba", "-i", "--non-interactive", "install", "--auto-agree-with-licenses", "--")
+	cliArgs = append(cliArgs, "--userdata", "skuba", "-i", "--non-interactive", "install", "--auto-agree-with-licenses")
+	cliArgs = append(cliArgs, options...)
+	cliArgs = append(cliArgs, "--")
 	cliArgs = append(cliArgs, packages...)
 	return t.ssh("zypper", cliArgs...)
 }
+
+// printZypperPlan prints the packages a zypper install would install and
+// remove, following the zypper `+package` and `-package` syntax
+func printZypperPlan(target string, packages []string) {
+	var install, remove []string
+	for _, pkg := range packages {
+		switch {
+		case strings.HasPrefix(pkg, "-"):
+			remove = append(remove, strings.TrimPrefix(pkg, "-"))
+		case strings.HasPrefix(pkg, "+"):
+			install = append(install, strings.TrimPrefix(pkg, "+"))
+		default:
+			install = append(install, pkg)
+		}
+	}
+	if len(install) > 0 {
+		fmt.Printf("[dry-run] %s: install packages %s\n", target, strings.Join(install, " "))
+	}
+	if len(remove) > 0 {
+		fmt.Printf("[dry-run] %s: remove packages %s\n", target, strings.Join(remove, " "))
+	}
+}
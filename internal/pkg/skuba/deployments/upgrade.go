@@ -17,7 +17,42 @@
 
 package deployments
 
+import "time"
+
 // UpgradeConfiguration holds information passed to kubeadm during upgrade
 type UpgradeConfiguration struct {
 	KubeadmConfigContents string
 }
+
+const (
+	// UpgradeBackupFile is the location on a node of the state recorded
+	// before its last upgrade, used to roll back a failed upgrade
+	UpgradeBackupFile = "/var/lib/skuba/upgrade-backup.json"
+)
+
+// UpgradeBackupKubeletFiles are the kubelet configuration files recorded
+// before a node upgrade, and restored when rolling it back
+var UpgradeBackupKubeletFiles = []string{
+	"/var/lib/kubelet/config.yaml",
+	"/var/lib/kubelet/kubeadm-flags.env",
+	"/etc/sysconfig/kubelet",
+}
+
+// UpgradeBackup is the state of a node recorded before its upgrade
+type UpgradeBackup struct {
+	FromVersion string `json:"fromVersion"`
+	ToVersion   string `json:"toVersion"`
+	// FirstControlPlane is set when the node runs `kubeadm upgrade apply`
+	FirstControlPlane bool `json:"firstControlPlane"`
+	// KubeadmUpgradeApplied is set once `kubeadm upgrade apply` has been
+	// run on the node, after which it can no longer be rolled back
+	KubeadmUpgradeApplied bool `json:"kubeadmUpgradeApplied"`
+	// SkubaUpdateTimerEnabled is whether skuba-update.timer was enabled
+	SkubaUpdateTimerEnabled bool `json:"skubaUpdateTimerEnabled"`
+	// Packages are the kubernetes and cri-o packages installed, as
+	// name-version-release
+	Packages []string `json:"packages"`
+	// KubeletFiles are the contents of the kubelet configuration files
+	KubeletFiles map[string]string `json:"kubeletFiles"`
+	RecordedAt   time.Time         `json:"recordedAt"`
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"crypto/sha1"
+	"fmt"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+const (
+	etcdPkiDir = "/etc/kubernetes/pki/etcd"
+	etcdctlCmd = "etcdctl --endpoints=https://[127.0.0.1]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key"
+)
+
+// runEtcdctlJob runs the job and returns its output; tests replace it, as
+// jobs never complete with a fake clientset
+var runEtcdctlJob = func(client clientset.Interface, name string, spec batchv1.JobSpec) (string, error) {
+	return kubernetes.CreateAndWaitForJobOutput(client, name, spec, kubernetes.TimeoutWaitForJob)
+}
+
+// etcdctl returns the etcdctl command line talking to the local etcd member
+// of a control plane node with the given arguments
+func etcdctl(args string) string {
+	return fmt.Sprintf("%s %s", etcdctlCmd, args)
+}
+
+// etcdctlJobName returns a job name for an etcdctl operation run on the
+// executor node
+func etcdctlJobName(operation string, executorNode *v1.Node) string {
+	executorNodeName := fmt.Sprintf("%x", sha1.Sum([]byte(executorNode.ObjectMeta.Name)))
+	return fmt.Sprintf("caasp-etcd-%s-%.10s", operation, executorNodeName)
+}
+
+// etcdImage returns the etcd image of the cluster version, in the custom
+// image registry of the cluster when it has one
+func etcdImage(client clientset.Interface, clusterVersion *version.Version) (string, error) {
+	imageRepository, err := skuba.GetImageRepository(client)
+	if err != nil {
+		return "", err
+	}
+	return kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Etcd, clusterVersion, imageRepository), nil
+}
+
+// etcdctlJobSpec returns the spec of a job running script with the etcd image
+// on the executor node, with the etcd PKI mounted
+func etcdctlJobSpec(name string, executorNode *v1.Node, image, script string) batchv1.JobSpec {
+	return batchv1.JobSpec{
+		Template: v1.PodTemplateSpec{
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:  name,
+						Image: image,
+						Command: []string{
+							"/bin/sh", "-c",
+							script,
+						},
+						Env: []v1.EnvVar{
+							{
+								Name:  "ETCDCTL_API",
+								Value: "3",
+							},
+						},
+						VolumeMounts: []v1.VolumeMount{
+							kubernetes.VolumeMount("etc-kubernetes-pki-etcd", etcdPkiDir, kubernetes.VolumeMountReadOnly),
+						},
+					},
+				},
+				HostNetwork:   true,
+				RestartPolicy: v1.RestartPolicyNever,
+				Volumes: []v1.Volume{
+					kubernetes.HostMount("etc-kubernetes-pki-etcd", etcdPkiDir),
+				},
+				NodeSelector: map[string]string{
+					"kubernetes.io/hostname": executorNode.ObjectMeta.Name,
+				},
+				Tolerations: []v1.Toleration{
+					{
+						Operator: v1.TolerationOpExists,
+					},
+				},
+			},
+		},
+	}
+}
+
+// ExecutorNodes returns the control plane nodes that are ready, and thus can
+// run etcdctl jobs against their local etcd member
+func ExecutorNodes(client clientset.Interface) ([]v1.Node, error) {
+	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the list of control plane nodes")
+	}
+	executorNodes := []v1.Node{}
+	for _, node := range controlPlaneNodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
+				executorNodes = append(executorNodes, node)
+			}
+		}
+	}
+	if len(executorNodes) == 0 {
+		return nil, errors.New("there are no ready control plane nodes to run etcdctl on")
+	}
+	return executorNodes, nil
+}
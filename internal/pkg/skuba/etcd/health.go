@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const healthStatusSeparator = "=== endpoint status ==="
+
+// EndpointHealth is the health of an etcd member as reported by
+// `etcdctl endpoint health`
+type EndpointHealth struct {
+	Endpoint string `json:"endpoint"`
+	Health   bool   `json:"health"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EndpointStatus is the status of an etcd member as reported by
+// `etcdctl endpoint status`
+type EndpointStatus struct {
+	Endpoint string
+	MemberID uint64
+	Version  string
+	DBSize   int64
+	IsLeader bool
+}
+
+// ClusterHealth is the health and status of all the etcd members, as seen
+// from the executor node
+type ClusterHealth struct {
+	Executor  string
+	Endpoints []EndpointHealth
+	Members   []EndpointStatus
+}
+
+// Leader returns the status of the member that is the current leader
+func (health *ClusterHealth) Leader() (EndpointStatus, bool) {
+	for _, member := range health.Members {
+		if member.IsLeader {
+			return member, true
+		}
+	}
+	return EndpointStatus{}, false
+}
+
+// Unhealthy returns the reasons why the etcd cluster is not healthy, if any
+func (health *ClusterHealth) Unhealthy() []string {
+	reasons := []string{}
+	for _, endpoint := range health.Endpoints {
+		if !endpoint.Health {
+			reasons = append(reasons, fmt.Sprintf("member %s is unhealthy: %s", endpoint.Endpoint, endpoint.Error))
+		}
+	}
+	if _, found := health.Leader(); !found {
+		reasons = append(reasons, "no member reports to be the leader")
+	}
+	return reasons
+}
+
+type rawEndpointStatus struct {
+	Endpoint string `json:"Endpoint"`
+	Status   struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+		} `json:"header"`
+		Version string `json:"version"`
+		DBSize  int64  `json:"dbSize"`
+		Leader  uint64 `json:"leader"`
+	} `json:"Status"`
+}
+
+// GetClusterHealth queries the health and status of every etcd member. The
+// query runs on the first ready control plane node able to answer it.
+func GetClusterHealth(client clientset.Interface, clusterVersion *version.Version) (*ClusterHealth, error) {
+	var health *ClusterHealth
+	// etcdctl exits with an error when a member is unhealthy; the job has to
+	// succeed anyway so the partial results can be reported
+	script := fmt.Sprintf("%s 2>/dev/null; echo; echo '%s'; %s 2>/dev/null; true",
+		etcdctl("endpoint health --cluster -w json"),
+		healthStatusSeparator,
+		etcdctl("endpoint status --cluster -w json"))
+	executor, err := queryFromAnyExecutor(client, clusterVersion, "health", script, func(output string) error {
+		var err error
+		health, err = parseClusterHealth(output)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not query etcd health")
+	}
+	health.Executor = executor
+	return health, nil
+}
+
+// queryFromAnyExecutor runs the etcdctl script on the ready control plane
+// nodes, one after the other, until the output of one of them is parsed. It
+// returns the name of that node.
+func queryFromAnyExecutor(client clientset.Interface, clusterVersion *version.Version, operation, script string, parse func(output string) error) (string, error) {
+	executorNodes, err := ExecutorNodes(client)
+	if err != nil {
+		return "", err
+	}
+	image, err := etcdImage(client, clusterVersion)
+	if err != nil {
+		return "", err
+	}
+	var lastErr error
+	for i := range executorNodes {
+		executorNode := &executorNodes[i]
+		name := etcdctlJobName(operation, executorNode)
+		output, err := runEtcdctlJob(client, name, etcdctlJobSpec(name, executorNode, image, script))
+		if err == nil {
+			err = parse(output)
+		}
+		if err == nil {
+			return executorNode.ObjectMeta.Name, nil
+		}
+		klog.V(1).Infof("could not run etcdctl %s on control plane node %s: %v", operation, executorNode.ObjectMeta.Name, err)
+		lastErr = err
+	}
+	return "", errors.Wrap(lastErr, "no control plane node could run etcdctl")
+}
+
+func parseEndpointStatuses(output string) ([]EndpointStatus, error) {
+	rawStatuses := []rawEndpointStatus{}
+	if strings.TrimSpace(output) != "" {
+		if err := json.Unmarshal([]byte(output), &rawStatuses); err != nil {
+			return nil, errors.Wrap(err, "could not parse etcd endpoint status")
+		}
+	}
+	statuses := []EndpointStatus{}
+	for _, rawStatus := range rawStatuses {
+		statuses = append(statuses, EndpointStatus{
+			Endpoint: rawStatus.Endpoint,
+			MemberID: rawStatus.Status.Header.MemberID,
+			Version:  rawStatus.Status.Version,
+			DBSize:   rawStatus.Status.DBSize,
+			IsLeader: rawStatus.Status.Header.MemberID == rawStatus.Status.Leader,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Endpoint < statuses[j].Endpoint
+	})
+	return statuses, nil
+}
+
+func parseClusterHealth(output string) (*ClusterHealth, error) {
+	parts := strings.SplitN(output, healthStatusSeparator, 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("unexpected etcdctl output: %q", output)
+	}
+	health := &ClusterHealth{
+		Endpoints: []EndpointHealth{},
+	}
+	if strings.TrimSpace(parts[0]) != "" {
+		if err := json.Unmarshal([]byte(parts[0]), &health.Endpoints); err != nil {
+			return nil, errors.Wrap(err, "could not parse etcd endpoint health")
+		}
+	}
+	statuses, err := parseEndpointStatuses(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(health.Endpoints) == 0 && len(statuses) == 0 {
+		return nil, errors.New("etcdctl did not report any etcd member")
+	}
+	health.Members = statuses
+	sort.Slice(health.Endpoints, func(i, j int) bool {
+		return health.Endpoints[i].Endpoint < health.Endpoints[j].Endpoint
+	})
+	return health, nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"errors"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const fakeHealthOutput = `[{"endpoint":"https://10.0.0.2:2379","health":false,"took":"","error":"context deadline exceeded"},{"endpoint":"https://10.0.0.1:2379","health":true,"took":"9.2ms"}]
+=== endpoint status ===
+[{"Endpoint":"https://10.0.0.1:2379","Status":{"header":{"cluster_id":17237436991929493444,"member_id":9372538179322589801,"revision":1234,"raft_term":3},"version":"3.4.3","dbSize":2473984,"leader":9372538179322589801,"raftIndex":4321,"raftTerm":3}}]
+`
+
+func controlPlaneNode(name string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"node-role.kubernetes.io/master": ""},
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
+
+func TestParseClusterHealth(t *testing.T) {
+	health, err := parseClusterHealth(fakeHealthOutput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(health.Endpoints) != 2 || health.Endpoints[0].Endpoint != "https://10.0.0.1:2379" || !health.Endpoints[0].Health {
+		t.Errorf("unexpected endpoints: %+v", health.Endpoints)
+	}
+	if health.Endpoints[1].Health || health.Endpoints[1].Error != "context deadline exceeded" {
+		t.Errorf("expected second endpoint to be unhealthy: %+v", health.Endpoints[1])
+	}
+	leader, found := health.Leader()
+	if !found || leader.MemberID != 9372538179322589801 || leader.DBSize != 2473984 {
+		t.Errorf("unexpected leader: %+v (found: %v)", leader, found)
+	}
+
+	if _, err := parseClusterHealth("Error: context deadline exceeded"); err == nil {
+		t.Error("expected error on unexpected output")
+	}
+	if _, err := parseClusterHealth("\n=== endpoint status ===\n"); err == nil {
+		t.Error("expected error when no members are reported")
+	}
+}
+
+func TestGetClusterHealth(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		controlPlaneNode("master-0", false),
+		controlPlaneNode("master-1", true),
+		controlPlaneNode("master-2", true),
+	)
+	executors := []string{}
+	defer func(original func(clientset.Interface, string, batchv1.JobSpec) (string, error)) {
+		runEtcdctlJob = original
+	}(runEtcdctlJob)
+	runEtcdctlJob = func(client clientset.Interface, name string, spec batchv1.JobSpec) (string, error) {
+		executor := spec.Template.Spec.NodeSelector["kubernetes.io/hostname"]
+		executors = append(executors, executor)
+		if executor == "master-1" {
+			return "", errors.New("failed waiting for job")
+		}
+		return fakeHealthOutput, nil
+	}
+
+	health, err := GetClusterHealth(client, version.MustParseSemantic("v1.18.10"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if health.Executor != "master-2" {
+		t.Errorf("expected health to be queried from master-2, got %s", health.Executor)
+	}
+	if len(executors) != 2 || executors[0] != "master-1" {
+		t.Errorf("unexpected executors: %v", executors)
+	}
+}
@@ -18,94 +18,71 @@
 package etcd
 
 import (
-	"crypto/sha1"
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
-	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/version"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
-
-	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 )
 
+// ErrMemberNotFound is returned by RemoveMember when the node does not run
+// any etcd member
+var ErrMemberNotFound = errors.New("no etcd member found")
+
+// RemoveMember removes the etcd member running on node from the etcd
+// cluster, and verifies that the member is gone afterwards. ErrMemberNotFound
+// is returned when the node does not run any etcd member.
 func RemoveMember(client clientset.Interface, node *v1.Node, clusterVersion *version.Version) error {
-	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	members, err := ListMembers(client, clusterVersion)
 	if err != nil {
-		return errors.Wrap(err, "could not get the list of control plane nodes, aborting")
+		return err
+	}
+	member, found := memberForNode(node, members)
+	if !found {
+		return errors.Wrapf(ErrMemberNotFound, "node %s", node.ObjectMeta.Name)
 	}
 
-	// Remove etcd member if target is a control plane
-	klog.V(1).Info("removing etcd member from the etcd cluster")
-	for _, controlPlaneNode := range controlPlaneNodes.Items {
-		klog.V(1).Infof("trying to remove etcd member from control plane node %s", controlPlaneNode.ObjectMeta.Name)
-		if err := RemoveMemberFrom(client, node, &controlPlaneNode, clusterVersion); err == nil {
-			klog.V(1).Infof("etcd member for node %s removed from control plane node %s", node.ObjectMeta.Name, controlPlaneNode.ObjectMeta.Name)
-			break
-		} else {
-			klog.V(1).Infof("could not remove etcd member from control plane node %s", controlPlaneNode.ObjectMeta.Name)
-		}
+	klog.V(1).Infof("removing etcd member %x of node %s from the etcd cluster", member.ID, node.ObjectMeta.Name)
+	if err := RemoveMemberByID(client, member.ID, clusterVersion); err != nil {
+		return err
 	}
 
+	members, err = ListMembers(client, clusterVersion)
+	if err != nil {
+		return errors.Wrap(err, "could not verify the etcd member removal")
+	}
+	for _, remaining := range members {
+		if remaining.ID == member.ID {
+			return errors.Errorf("etcd member %x of node %s is still part of the etcd cluster", member.ID, node.ObjectMeta.Name)
+		}
+	}
 	return nil
 }
 
-func RemoveMemberFrom(client clientset.Interface, node, executorNode *v1.Node, clusterVersion *version.Version) error {
-	return kubernetes.CreateAndWaitForJob(
-		client,
-		removeMemberFromJobName(node, executorNode),
-		removeMemberFromJobSpec(node, executorNode, clusterVersion),
-		kubernetes.TimeoutWaitForJob,
-	)
-}
-
-func removeMemberFromJobName(node, executorNode *v1.Node) string {
-	nodeName := fmt.Sprintf("%x", sha1.Sum([]byte(node.ObjectMeta.Name)))
-	executorNodeName := fmt.Sprintf("%x", sha1.Sum([]byte(executorNode.ObjectMeta.Name)))
-
-	return fmt.Sprintf("caasp-remove-etcd-member-%.10s-from-%.10s", nodeName, executorNodeName)
+// RemoveMemberByID removes the etcd member with the given ID from the etcd
+// cluster. The removal runs on the first ready control plane node able to
+// perform it.
+func RemoveMemberByID(client clientset.Interface, id uint64, clusterVersion *version.Version) error {
+	var output string
+	_, err := queryFromAnyExecutor(client, clusterVersion, "remove-member", etcdctl(fmt.Sprintf("member remove %x", id)), func(jobOutput string) error {
+		output = jobOutput
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not remove etcd member %x", id)
+	}
+	klog.V(1).Info(strings.TrimSpace(output))
+	return nil
 }
 
-func removeMemberFromJobSpec(node, executorNode *v1.Node, clusterVersion *version.Version) batchv1.JobSpec {
-	return batchv1.JobSpec{
-		Template: v1.PodTemplateSpec{
-			Spec: v1.PodSpec{
-				Containers: []v1.Container{
-					{
-						Name: removeMemberFromJobName(node, executorNode),
-						// FIXME: check that etcd member is part of the member list already
-						Image: kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Etcd, clusterVersion),
-						Command: []string{
-							"/bin/sh", "-c",
-							fmt.Sprintf("etcdctl --endpoints=https://[127.0.0.1]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key member remove $(etcdctl --endpoints=https://[127.0.0.1]:2379 --cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/healthcheck-client.crt --key=/etc/kubernetes/pki/etcd/healthcheck-client.key member list | grep ', %s,' | cut -d',' -f1)", node.ObjectMeta.Name),
-						},
-						Env: []v1.EnvVar{
-							{
-								Name:  "ETCDCTL_API",
-								Value: "3",
-							},
-						},
-						VolumeMounts: []v1.VolumeMount{
-							kubernetes.VolumeMount("etc-kubernetes-pki-etcd", "/etc/kubernetes/pki/etcd", kubernetes.VolumeMountReadOnly),
-						},
-					},
-				},
-				HostNetwork:   true,
-				RestartPolicy: v1.RestartPolicyNever,
-				Volumes: []v1.Volume{
-					kubernetes.HostMount("etc-kubernetes-pki-etcd", "/etc/kubernetes/pki/etcd"),
-				},
-				NodeSelector: map[string]string{
-					"kubernetes.io/hostname": executorNode.ObjectMeta.Name,
-				},
-				Tolerations: []v1.Toleration{
-					{
-						Operator: v1.TolerationOpExists,
-					},
-				},
-			},
-		},
+func memberForNode(node *v1.Node, members []Member) (Member, bool) {
+	for _, member := range members {
+		if NodeForMember(member, []v1.Node{*node}) != nil {
+			return member, true
+		}
 	}
+	return Member{}, false
 }
@@ -15,154 +15,82 @@
  *
  */
 
-package etcd_test
+package etcd
 
 import (
-	"context"
-	"crypto/sha1"
-	"fmt"
+	"strings"
 	"testing"
 
-	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
-	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
-	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
-
-	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
-
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
-func Test_RemoveMember(t *testing.T) {
-	fakeCfgFiles := map[string][]byte{
-		"KubeletComponentConfig": []byte(`
-apiVersion: kubelet.config.k8s.io/v1beta1
-kind: KubeletConfiguration
-`),
-		"ClusterConfiguration": []byte(`
-apiVersion: kubeadm.k8s.io/v1beta1
-kind: ClusterConfiguration
-kubernetesVersion: v1.13.0
-apiServer:
-  extraArgs:
-    advertiseAddress: 1.2.3.4
-`),
-	}
-
-	fakeConfigMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      kubeadmconstants.KubeadmConfigConfigMap,
-			Namespace: metav1.NamespaceSystem,
-		},
-		Data: map[string]string{
-			kubeadmconstants.ClusterConfigurationConfigMapKey:     string(fakeCfgFiles["ClusterConfiguration"]),
-			kubeadmconstants.KubeletBaseConfigurationConfigMapKey: string(fakeCfgFiles["KubeletComponentConfig"]),
-		},
-	}
-
-	fakeDaemonSet := &appsv1.DaemonSet{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "DaemonSet",
-			APIVersion: "app/v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: metav1.NamespaceSystem,
-			Name:      "cilium",
-		},
-		Spec: appsv1.DaemonSetSpec{
-			Template: corev1.PodTemplateSpec{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "fake",
-							Image: "fake",
-						},
-					},
-				},
-			},
-		},
-	}
-
-	fakeJobSpec := batchv1.JobSpec{
-		Template: corev1.PodTemplateSpec{
-			Spec: corev1.PodSpec{
-				Containers: []corev1.Container{
-					{
-						Name:    "fake",
-						Image:   "fake",
-						Command: []string{"/bin/bash"},
-					},
-				},
-				RestartPolicy: corev1.RestartPolicyNever,
-				Volumes:       []corev1.Volume{},
-				NodeSelector: map[string]string{
-					"kubernetes.io/hostname": "fake",
-				},
-				Tolerations: []corev1.Toleration{
-					{
-						Operator: corev1.TolerationOpExists,
-					},
-				},
-			},
-		},
-	}
-
-	fakeWorker := corev1.Node{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "worker",
-		},
-	}
-
-	fakeMaster := corev1.Node{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   "master",
-			Labels: map[string]string{"node-role.kubernetes.io/master": ""},
-		},
-	}
+const fakeMembersAfterRemovalOutput = `{"members":[{"ID":9372538179322589801,"name":"master-1","peerURLs":["https://10.0.0.1:2380"],"clientURLs":["https://10.0.0.1:2379"]}]}
+=== endpoint status ===
+[]
+`
 
+func Test_RemoveMember(t *testing.T) {
 	tests := []struct {
-		errExpected bool
-		errMessage  string
-		name        string
+		name             string
+		node             string
+		removalSucceeds  bool
+		expectedRemovals []string
+		errExpected      bool
+		errMessage       string
 	}{
 		{
-			name:        "should remove etcd member from etcd cluster",
-			errExpected: false,
+			name:             "should remove etcd member from etcd cluster",
+			node:             "etcd-2",
+			removalSucceeds:  true,
+			expectedRemovals: []string{"member remove 91bc3c398fb3c146"},
+		},
+		{
+			name:        "should not remove anything when the node does not run etcd",
+			node:        "worker",
+			errExpected: true,
+			errMessage:  "node worker: no etcd member found",
+		},
+		{
+			name:             "should fail when the etcd member is still present",
+			node:             "etcd-2",
+			expectedRemovals: []string{"member remove 91bc3c398fb3c146"},
+			errExpected:      true,
+			errMessage:       "etcd member 91bc3c398fb3c146 of node etcd-2 is still part of the etcd cluster",
 		},
 	}
 
 	for _, tt := range tests {
 		tt := tt // Parallel testing
 		t.Run(tt.name, func(t *testing.T) {
-			clientset := fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{fakeMaster, fakeWorker}})
-			//nolint:errcheck
-			clientset.CoreV1().ConfigMaps(metav1.NamespaceSystem).Create(context.TODO(), fakeConfigMap, metav1.CreateOptions{})
-			//nolint:errcheck
-			clientset.AppsV1().DaemonSets(metav1.NamespaceSystem).Create(context.TODO(), fakeDaemonSet, metav1.CreateOptions{})
-
-			controlPlaneNodes, _ := kubernetes.GetControlPlaneNodes(clientset)
-			hashTarget := fmt.Sprintf("%x", sha1.Sum([]byte(fakeWorker.ObjectMeta.Name)))
-			hashExecutor := fmt.Sprintf("%x", sha1.Sum([]byte(controlPlaneNodes.Items[0].ObjectMeta.Name)))
-			job := fmt.Sprintf("caasp-remove-etcd-member-%.10s-from-%.10s", hashTarget, hashExecutor)
-			//nolint:errcheck
-			clientset.BatchV1().Jobs(metav1.NamespaceSystem).Create(
-				context.TODO(),
-				&batchv1.Job{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      job,
-						Namespace: metav1.NamespaceSystem,
-					},
-					Spec: fakeJobSpec,
-				},
-				metav1.CreateOptions{},
+			client := fake.NewSimpleClientset(
+				controlPlaneNode("master-1", true),
+				controlPlaneNode("etcd-2", true),
 			)
+			removals := []string{}
+			removed := false
+			defer func(original func(clientset.Interface, string, batchv1.JobSpec) (string, error)) {
+				runEtcdctlJob = original
+			}(runEtcdctlJob)
+			runEtcdctlJob = func(client clientset.Interface, name string, spec batchv1.JobSpec) (string, error) {
+				script := spec.Template.Spec.Containers[0].Command[2]
+				if i := strings.Index(script, "member remove"); i >= 0 {
+					removals = append(removals, script[i:])
+					removed = tt.removalSucceeds
+					return "Member 91bc3c398fb3c146 removed from cluster", nil
+				}
+				if removed {
+					return fakeMembersAfterRemovalOutput, nil
+				}
+				return fakeMembersOutput, nil
+			}
 
-			controlPlaneComponentsVersion, _ := kubeadm.GetCurrentClusterVersion(clientset)
-
-			err := etcd.RemoveMember(clientset, &fakeWorker, controlPlaneComponentsVersion)
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: tt.node}}
+			err := RemoveMember(client, node, version.MustParseSemantic("v1.18.10"))
 			if tt.errExpected {
 				if err == nil {
 					t.Errorf("error expected on %s, but no error reported", tt.name)
@@ -172,11 +100,12 @@ apiServer:
 					t.Errorf("returned error (%v) does not match the expected one (%v)", err.Error(), tt.errMessage)
 					return
 				}
-			} else if !tt.errExpected {
-				if err != nil {
-					t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err.Error())
-					return
-				}
+			} else if err != nil {
+				t.Errorf("error not expected on %s, but an error was reported (%v)", tt.name, err.Error())
+				return
+			}
+			if strings.Join(removals, ";") != strings.Join(tt.expectedRemovals, ";") {
+				t.Errorf("expected removals %v, got %v", tt.expectedRemovals, removals)
 			}
 		})
 	}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const membersStatusSeparator = "=== endpoint status ==="
+
+// Member is an etcd member as reported by `etcdctl member list`, along with
+// its status when the member answered `etcdctl endpoint status`
+type Member struct {
+	ID         uint64   `json:"ID"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+	// DBSize is the size of the member database in bytes, or -1 when the
+	// member did not report its status
+	DBSize   int64 `json:"-"`
+	IsLeader bool  `json:"-"`
+}
+
+// ListMembers returns the members of the etcd cluster, sorted by name
+func ListMembers(client clientset.Interface, clusterVersion *version.Version) ([]Member, error) {
+	var members []Member
+	script := fmt.Sprintf("%s; echo; echo '%s'; %s 2>/dev/null; true",
+		etcdctl("member list -w json"),
+		membersStatusSeparator,
+		etcdctl("endpoint status --cluster -w json"))
+	_, err := queryFromAnyExecutor(client, clusterVersion, "members", script, func(output string) error {
+		var err error
+		members, err = parseMembers(output)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list etcd members")
+	}
+	return members, nil
+}
+
+func parseMembers(output string) ([]Member, error) {
+	parts := strings.SplitN(output, membersStatusSeparator, 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("unexpected etcdctl output: %q", output)
+	}
+	memberList := struct {
+		Members []Member `json:"members"`
+	}{}
+	if err := json.Unmarshal([]byte(parts[0]), &memberList); err != nil {
+		return nil, errors.Wrap(err, "could not parse etcd member list")
+	}
+	if len(memberList.Members) == 0 {
+		return nil, errors.New("etcdctl did not report any etcd member")
+	}
+	statuses, err := parseEndpointStatuses(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	members := memberList.Members
+	for i := range members {
+		members[i].DBSize = -1
+		for _, status := range statuses {
+			if status.MemberID == members[i].ID {
+				members[i].DBSize = status.DBSize
+				members[i].IsLeader = status.IsLeader
+			}
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
+
+// NodeForMember returns the node running the etcd member. kubeadm names the
+// members after their node; members with another name are matched by the
+// address of their peer URLs.
+func NodeForMember(member Member, nodes []v1.Node) *v1.Node {
+	for i := range nodes {
+		if nodes[i].ObjectMeta.Name == member.Name {
+			return &nodes[i]
+		}
+	}
+	for _, peerURL := range member.PeerURLs {
+		parsedURL, err := url.Parse(peerURL)
+		if err != nil {
+			continue
+		}
+		host := parsedURL.Hostname()
+		for i := range nodes {
+			for _, address := range nodes[i].Status.Addresses {
+				if sameAddress(address.Address, host) {
+					return &nodes[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Defragment defragments the etcd member running on the node. The other
+// members are not affected.
+func Defragment(client clientset.Interface, node *v1.Node, clusterVersion *version.Version) error {
+	name := etcdctlJobName("defrag", node)
+	image, err := etcdImage(client, clusterVersion)
+	if err != nil {
+		return err
+	}
+	// defragmenting a big database takes longer than the default timeout
+	output, err := runEtcdctlJob(client, name, etcdctlJobSpec(name, node, image, etcdctl("defrag --command-timeout=120s")))
+	if err != nil {
+		return errors.Wrapf(err, "could not defragment the etcd member on node %s: %s", node.ObjectMeta.Name, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+func sameAddress(a, b string) bool {
+	if ipA, ipB := net.ParseIP(a), net.ParseIP(b); ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return a == b
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fakeMembersOutput = `{"header":{"cluster_id":17237436991929493444,"member_id":9372538179322589801,"raft_term":3},"members":[{"ID":9372538179322589801,"name":"master-1","peerURLs":["https://10.0.0.1:2380"],"clientURLs":["https://10.0.0.1:2379"]},{"ID":10501334649042878790,"name":"etcd-2","peerURLs":["https://10.0.0.2:2380"],"clientURLs":["https://10.0.0.2:2379"]},{"ID":18249187646912138824,"peerURLs":["https://10.0.0.3:2380"]}]}
+=== endpoint status ===
+[{"Endpoint":"https://10.0.0.1:2379","Status":{"header":{"member_id":9372538179322589801},"version":"3.4.3","dbSize":2473984,"leader":9372538179322589801}},{"Endpoint":"https://10.0.0.2:2379","Status":{"header":{"member_id":10501334649042878790},"version":"3.4.3","dbSize":2482176,"leader":9372538179322589801}}]
+`
+
+func TestParseMembers(t *testing.T) {
+	members, err := parseMembers(fakeMembersOutput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("expected 3 members, got %d", len(members))
+	}
+	// members are sorted by name, the unstarted member has no name yet
+	if members[0].Name != "" || members[0].DBSize != -1 || members[0].IsLeader {
+		t.Errorf("unexpected unstarted member: %+v", members[0])
+	}
+	if members[1].Name != "etcd-2" || members[1].DBSize != 2482176 || members[1].IsLeader {
+		t.Errorf("unexpected follower member: %+v", members[1])
+	}
+	if members[2].Name != "master-1" || !members[2].IsLeader || members[2].ClientURLs[0] != "https://10.0.0.1:2379" {
+		t.Errorf("unexpected leader member: %+v", members[2])
+	}
+
+	if _, err := parseMembers(`{"members":[]}` + "\n=== endpoint status ===\n"); err == nil {
+		t.Error("expected error when no members are reported")
+	}
+}
+
+func TestNodeForMember(t *testing.T) {
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "master-1"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "master-2"},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.2"}},
+			},
+		},
+	}
+	members, err := parseMembers(fakeMembersOutput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"", "master-2", "master-1"}
+	for i, member := range members {
+		nodeName := ""
+		if node := NodeForMember(member, nodes); node != nil {
+			nodeName = node.ObjectMeta.Name
+		}
+		if nodeName != expected[i] {
+			t.Errorf("expected member %x to run on node %q, got %q", member.ID, expected[i], nodeName)
+		}
+	}
+}
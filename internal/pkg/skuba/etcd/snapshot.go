@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package etcd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+const (
+	snapshotDir  = "/var/lib/skuba-snapshot"
+	snapshotFile = snapshotDir + "/etcd.db"
+	// snapshotKeepAlive is the time in seconds the snapshot job waits for the
+	// snapshot to be fetched before finishing
+	snapshotKeepAlive = 3600
+)
+
+// ExecutorNode returns the ready control plane node with the given name, or
+// the first ready control plane node when no name is given
+func ExecutorNode(client clientset.Interface, nodeName string) (*v1.Node, error) {
+	executorNodes, err := ExecutorNodes(client)
+	if err != nil {
+		return nil, err
+	}
+	if nodeName == "" {
+		return &executorNodes[0], nil
+	}
+	for i := range executorNodes {
+		if executorNodes[i].ObjectMeta.Name == nodeName {
+			return &executorNodes[i], nil
+		}
+	}
+	return nil, errors.Errorf("%s is not a ready control plane node", nodeName)
+}
+
+// Snapshot saves a snapshot of etcd with a job running on the executor node
+// and streams it to out
+func Snapshot(client clientset.Interface, config *rest.Config, executorNode *v1.Node, clusterVersion *version.Version, out io.Writer) error {
+	name := etcdctlJobName("snapshot", executorNode)
+	// the snapshot is kept in the pod until it has been streamed, the job is
+	// deleted afterwards
+	script := fmt.Sprintf("%s && touch %s/ready && sleep %d", etcdctl(fmt.Sprintf("snapshot save %s", snapshotFile)), snapshotDir, snapshotKeepAlive)
+	image, err := etcdImage(client, clusterVersion)
+	if err != nil {
+		return err
+	}
+	spec := etcdctlJobSpec(name, executorNode, image, script)
+	backoffLimit := int32(0)
+	spec.BackoffLimit = &backoffLimit
+	spec.Template.Spec.Containers[0].VolumeMounts = append(spec.Template.Spec.Containers[0].VolumeMounts,
+		kubernetes.VolumeMount("snapshot", snapshotDir, kubernetes.VolumeMountReadWrite))
+	spec.Template.Spec.Volumes = append(spec.Template.Spec.Volumes, v1.Volume{
+		Name:         "snapshot",
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+	})
+
+	if _, err := kubernetes.CreateJob(client, name, spec); err != nil {
+		return errors.Wrapf(err, "could not create job %s", name)
+	}
+	defer func() {
+		if err := kubernetes.DeleteJob(client, name); err != nil {
+			fmt.Printf("error deleting job %s\n", name)
+		}
+	}()
+
+	pod, err := kubernetes.WaitForJobPod(client, name, kubernetes.TimeoutWaitForJob)
+	if err != nil {
+		return err
+	}
+	stderr := bytes.Buffer{}
+	streamSnapshot := fmt.Sprintf("while [ ! -f %s/ready ]; do sleep 1; done; cat %s", snapshotDir, snapshotFile)
+	if err := kubernetes.ExecInPod(client, config, pod, []string{"/bin/sh", "-c", streamSnapshot}, out, &stderr); err != nil {
+		output, _ := kubernetes.JobOutput(client, name)
+		return errors.Wrapf(err, "could not stream the etcd snapshot from node %s: %s", executorNode.ObjectMeta.Name, strings.TrimSpace(output+stderr.String()))
+	}
+	return nil
+}
@@ -93,6 +93,19 @@ func GetAPIEndpointsFromConfigMap(client clientset.Interface) ([]string, error)
 	return apiEndpoints, nil
 }
 
+// GetAPIEndpointsByNodeFromConfigMap returns the api endpoints held in the config map, by node name
+func GetAPIEndpointsByNodeFromConfigMap(client clientset.Interface) (map[string]kubeadmapi.APIEndpoint, error) {
+	kubeadmConfig, err := client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(context.TODO(), kubeadmconstants.KubeadmConfigConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve the kubeadm-config configmap to get apiEndpoints")
+	}
+	clusterStatus, err := configutil.UnmarshalClusterStatus(kubeadmConfig.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal cluster status from kubeadm-config configmap")
+	}
+	return clusterStatus.APIEndpoints, nil
+}
+
 // RemoveAPIEndpointFromConfigMap removes api endpoints from the config map
 func RemoveAPIEndpointFromConfigMap(client clientset.Interface, node *corev1.Node) error {
 	kubeadmConfig, err := client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(context.TODO(), kubeadmconstants.KubeadmConfigConfigMap, metav1.GetOptions{})
@@ -130,10 +143,11 @@ func RemoveAPIEndpointFromConfigMap(client clientset.Interface, node *corev1.Nod
 
 // UpdateClusterConfigurationWithClusterVersion allows us to set certain configurations during init, but also during upgrades.
 // The configuration that we put here will be consistently set to newly created configurations, and when we upgrade a cluster.
-func UpdateClusterConfigurationWithClusterVersion(initCfg *kubeadmapi.InitConfiguration, clusterVersion *version.Version) {
+// The control plane images come from the custom image registry of the cluster when it is not empty.
+func UpdateClusterConfigurationWithClusterVersion(initCfg *kubeadmapi.InitConfiguration, clusterVersion *version.Version, imageRepository string) {
 	// apiserver
 	setApiserverAdmissionPlugins(initCfg, clusterVersion)
-	setContainerImagesWithClusterVersion(initCfg, clusterVersion)
+	setContainerImagesWithClusterVersion(initCfg, clusterVersion, imageRepository)
 	setApiserverArgs(initCfg)
 }
 
@@ -371,7 +371,7 @@ func TestUpdateClusterConfigurationWithClusterVersion(t *testing.T) {
 	for _, tt := range scenarios {
 		tt := tt // Parallel testing
 		t.Run(tt.name, func(t *testing.T) {
-			imageRepository := skuba.ImageRepository(tt.clusterVersion)
+			imageRepository := skuba.ImageRepository(tt.clusterVersion, "")
 
 			expectedAdmissionPlugins := strings.Join(tt.expectedAdmissionPlugins, ",")
 			initCfg := kubeadmapi.InitConfiguration{}
@@ -382,7 +382,7 @@ func TestUpdateClusterConfigurationWithClusterVersion(t *testing.T) {
 				}
 				initCfg.APIServer.ControlPlaneComponent.ExtraArgs["enable-admission-plugins"] = currentAdmissionPlugins
 			}
-			UpdateClusterConfigurationWithClusterVersion(&initCfg, tt.clusterVersion)
+			UpdateClusterConfigurationWithClusterVersion(&initCfg, tt.clusterVersion, "")
 			// Check admission plugins
 			gotAdmissionPlugins := initCfg.APIServer.ControlPlaneComponent.ExtraArgs["enable-admission-plugins"]
 			if gotAdmissionPlugins != expectedAdmissionPlugins {
@@ -25,8 +25,8 @@ import (
 	"github.com/SUSE/skuba/pkg/skuba"
 )
 
-func setContainerImagesWithClusterVersion(initConfiguration *kubeadmapi.InitConfiguration, clusterVersion *version.Version) {
-	imageRepository := skuba.ImageRepository(clusterVersion)
+func setContainerImagesWithClusterVersion(initConfiguration *kubeadmapi.InitConfiguration, clusterVersion *version.Version, customImageRepository string) {
+	imageRepository := skuba.ImageRepository(clusterVersion, customImageRepository)
 
 	initConfiguration.ImageRepository = imageRepository
 	initConfiguration.KubernetesVersion = kubernetes.ComponentVersionForClusterVersion(kubernetes.APIServer, clusterVersion)
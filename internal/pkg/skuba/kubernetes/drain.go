@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	kubectldrain "k8s.io/kubectl/pkg/drain"
+)
+
+// drainRetryInterval is the time to wait before retrying the evictions
+// refused by a PodDisruptionBudget
+var drainRetryInterval = 5 * time.Second
+
+// DrainOptions configure how a node is drained
+type DrainOptions struct {
+	// Timeout is the time to wait for the node to drain; zero waits
+	// indefinitely
+	Timeout time.Duration
+	// GracePeriodSeconds is the termination grace period given to every
+	// pod; a negative value uses the grace period of the pod
+	GracePeriodSeconds int
+	// DeleteEmptyDirData allows evicting pods using emptyDir volumes, whose
+	// data is lost
+	DeleteEmptyDirData bool
+	// Force allows deleting pods not managed by a controller, which are not
+	// recreated anywhere else
+	Force bool
+	// SkipPodSelector is a label selector of the pods left on the node
+	SkipPodSelector string
+}
+
+// DefaultDrainOptions returns the options used to drain nodes unless
+// configured otherwise
+func DefaultDrainOptions(timeout time.Duration) DrainOptions {
+	return DrainOptions{
+		Timeout:            timeout,
+		GracePeriodSeconds: -1,
+		DeleteEmptyDirData: true,
+		Force:              true,
+	}
+}
+
+// DrainNode cordons, drains and evict given node. Evictions refused by a
+// PodDisruptionBudget are retried until the drain timeout; the returned error
+// reports the pods and the budgets that blocked the drain.
+func DrainNode(client clientset.Interface, node *corev1.Node, options DrainOptions) error {
+	skipSelector, err := labels.Parse(options.SkipPodSelector)
+	if err != nil {
+		return errors.Wrapf(err, "invalid pod selector %q", options.SkipPodSelector)
+	}
+
+	policyGroupVersion, err := kubectldrain.CheckEvictionSupport(client)
+	if err != nil {
+		return errors.Wrap(err, "could not get policy group version")
+	}
+
+	newCordon := kubectldrain.NewCordonHelper(node)
+	newCordon.UpdateIfRequired(true)
+	err, patchErr := newCordon.PatchOrReplace(client, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to update node status")
+	}
+	if patchErr != nil {
+		return errors.Wrap(patchErr, "failed to patch node status")
+	}
+
+	// the force and emptyDir policies are enforced below, so the pods left
+	// on the node by the selector never prevent the drain
+	drainer := &kubectldrain.Helper{
+		Client:              client,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteLocalData:     true,
+		GracePeriodSeconds:  options.GracePeriodSeconds,
+		Timeout:             options.Timeout,
+	}
+	del, errs := drainer.GetPodsForDeletion(node.ObjectMeta.Name)
+	if errs != nil {
+		return fmt.Errorf("could not get pods for deletion %s", errs)
+	}
+
+	pods := []corev1.Pod{}
+	unmanaged, withEmptyDir := []string{}, []string{}
+	for _, pod := range del.Pods() {
+		if !skipSelector.Empty() && skipSelector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			klog.V(1).Infof("leaving pod %s/%s on node %s, it matches the skip selector", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, node.ObjectMeta.Name)
+			continue
+		}
+		if !options.Force && metav1.GetControllerOf(&pod) == nil {
+			unmanaged = append(unmanaged, podKey(&pod))
+		}
+		if !options.DeleteEmptyDirData && hasEmptyDir(&pod) {
+			withEmptyDir = append(withEmptyDir, podKey(&pod))
+		}
+		pods = append(pods, pod)
+	}
+	if len(unmanaged) > 0 || len(withEmptyDir) > 0 {
+		reasons := []string{}
+		if len(unmanaged) > 0 {
+			reasons = append(reasons, fmt.Sprintf("pods not managed by a controller would be deleted: %s", strings.Join(unmanaged, ", ")))
+		}
+		if len(withEmptyDir) > 0 {
+			reasons = append(reasons, fmt.Sprintf("the emptyDir data of pods would be lost: %s", strings.Join(withEmptyDir, ", ")))
+		}
+		return errors.Errorf("cannot drain node %s: %s", node.ObjectMeta.Name, strings.Join(reasons, "; "))
+	}
+
+	start := time.Now()
+	retrying := false
+	for {
+		blocked := []corev1.Pod{}
+		for _, pod := range pods {
+			if len(policyGroupVersion) > 0 {
+				err := drainer.EvictPod(pod, policyGroupVersion)
+				if apierrors.IsTooManyRequests(err) {
+					blocked = append(blocked, pod)
+				} else if err != nil && !(retrying && apierrors.IsNotFound(err)) {
+					return errors.Wrapf(err, "failed to evict pod: %v", pod.Name)
+				}
+			} else if err := drainer.DeletePod(pod); err != nil && !(retrying && apierrors.IsNotFound(err)) {
+				return errors.Wrapf(err, "failed to delete pod: %v", pod.Name)
+			}
+		}
+		if len(blocked) == 0 {
+			break
+		}
+		if options.Timeout > 0 && time.Since(start)+drainRetryInterval > options.Timeout {
+			return blockedDrainError(client, node, blocked, options.Timeout)
+		}
+		klog.V(1).Infof("%d pods on node %s cannot be evicted yet because of pod disruption budgets, retrying in %s", len(blocked), node.ObjectMeta.Name, drainRetryInterval)
+		time.Sleep(drainRetryInterval)
+		// the pods whose eviction was refused may have been deleted or
+		// rescheduled in the meantime, they are already gone from the node
+		pods = blocked
+		retrying = true
+	}
+
+	klog.V(1).Infof("node %s correctly drained", node.ObjectMeta.Name)
+
+	return nil
+}
+
+// blockedDrainError reports the pods whose eviction was refused, along with
+// the pod disruption budgets covering them
+func blockedDrainError(client clientset.Interface, node *corev1.Node, blocked []corev1.Pod, timeout time.Duration) error {
+	lines := []string{}
+	for _, pod := range blocked {
+		budgets, err := podDisruptionBudgetsForPod(client, &pod)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("  - pod %s: could not get pod disruption budgets: %v", podKey(&pod), err))
+			continue
+		}
+		if len(budgets) == 0 {
+			budgets = []string{"no matching pod disruption budget"}
+		}
+		lines = append(lines, fmt.Sprintf("  - pod %s: %s", podKey(&pod), strings.Join(budgets, ", ")))
+	}
+	return errors.Errorf("could not drain node %s within %s, evictions refused because of pod disruption budgets:\n%s", node.ObjectMeta.Name, timeout, strings.Join(lines, "\n"))
+}
+
+func podDisruptionBudgetsForPod(client clientset.Interface, pod *corev1.Pod) ([]string, error) {
+	pdbs, err := client.PolicyV1beta1().PodDisruptionBudgets(pod.ObjectMeta.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	budgets := []string{}
+	for _, pdb := range pdbs.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			continue
+		}
+		budgets = append(budgets, fmt.Sprintf("pdb %s/%s (%d disruptions allowed, %d/%d healthy pods)",
+			pdb.ObjectMeta.Namespace, pdb.ObjectMeta.Name, pdb.Status.DisruptionsAllowed, pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy))
+	}
+	sort.Strings(budgets)
+	return budgets, nil
+}
+
+func hasEmptyDir(pod *corev1.Pod) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func podKey(pod *corev1.Pod) string {
+	return fmt.Sprintf("%s/%s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
+}
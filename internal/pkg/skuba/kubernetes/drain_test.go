@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2019 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktest "k8s.io/client-go/testing"
+)
+
+func drainTestPod(name string, labels map[string]string, managed bool, emptyDir bool) corev1.Pod {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: metav1.NamespaceDefault,
+			Labels:    labels,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "worker1",
+		},
+	}
+	if managed {
+		controller := true
+		pod.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name + "-rs", Controller: &controller},
+		}
+	}
+	if emptyDir {
+		pod.Spec.Volumes = []corev1.Volume{
+			{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		}
+	}
+	return pod
+}
+
+func TestDrainNodeOptions(t *testing.T) {
+	defer func(interval time.Duration) { drainRetryInterval = interval }(drainRetryInterval)
+	drainRetryInterval = time.Millisecond
+
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-pdb",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+		Status: policyv1beta1.PodDisruptionBudgetStatus{
+			DisruptionsAllowed: 0,
+			CurrentHealthy:     2,
+			DesiredHealthy:     2,
+		},
+	}
+
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		// refusals is the number of times the eviction of the web pods
+		// is refused; a negative value refuses it forever
+		refusals int
+		// webGone makes the web pods disappear once their eviction is no
+		// longer refused
+		webGone      bool
+		options      DrainOptions
+		expectEvict  []string
+		expectErrMsg []string
+	}{
+		{
+			name:        "evictions blocked by a pdb are retried",
+			pods:        []corev1.Pod{drainTestPod("web", map[string]string{"app": "web"}, true, false)},
+			refusals:    2,
+			options:     DefaultDrainOptions(time.Minute),
+			expectEvict: []string{"web", "web", "web"},
+		},
+		{
+			name:        "pods deleted while their eviction is retried are evicted",
+			pods:        []corev1.Pod{drainTestPod("web", map[string]string{"app": "web"}, true, false)},
+			refusals:    1,
+			webGone:     true,
+			options:     DefaultDrainOptions(time.Minute),
+			expectEvict: []string{"web", "web"},
+		},
+		{
+			name:     "evictions blocked until the timeout report the pdb",
+			pods:     []corev1.Pod{drainTestPod("web", map[string]string{"app": "web"}, true, false), drainTestPod("other", nil, true, false)},
+			refusals: -1,
+			options:  DefaultDrainOptions(20 * time.Millisecond),
+			expectErrMsg: []string{
+				"could not drain node worker1 within 20ms",
+				"pod default/web: pdb default/web-pdb (0 disruptions allowed, 2/2 healthy pods)",
+			},
+		},
+		{
+			name: "pods matching the skip selector are left on the node",
+			pods: []corev1.Pod{drainTestPod("web", map[string]string{"app": "web"}, true, false), drainTestPod("other", nil, false, true)},
+			options: DrainOptions{
+				GracePeriodSeconds: -1,
+				SkipPodSelector:    "app!=web",
+			},
+			expectEvict: []string{"web"},
+		},
+		{
+			name:         "unmanaged pods are not deleted without force",
+			pods:         []corev1.Pod{drainTestPod("web", nil, true, false), drainTestPod("bare", nil, false, false)},
+			options:      DrainOptions{GracePeriodSeconds: -1, DeleteEmptyDirData: true},
+			expectErrMsg: []string{"cannot drain node worker1: pods not managed by a controller would be deleted: default/bare"},
+		},
+		{
+			name:         "emptyDir data is not deleted unless allowed",
+			pods:         []corev1.Pod{drainTestPod("web", nil, true, true)},
+			options:      DrainOptions{GracePeriodSeconds: -1, Force: true},
+			expectErrMsg: []string{"cannot drain node worker1: the emptyDir data of pods would be lost: default/web"},
+		},
+		{
+			name:         "invalid skip selector",
+			options:      DrainOptions{SkipPodSelector: "app in web"},
+			expectErrMsg: []string{`invalid pod selector "app in web"`},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			node := createNode("worker1", false, "w1")
+			fakeClientset := fake.NewSimpleClientset(&node, pdb)
+			addEvictionSupport(fakeClientset)
+			fakeClientset.PrependReactor("list", "pods", func(action ktest.Action) (bool, runtime.Object, error) {
+				return true, &corev1.PodList{Items: tt.pods}, nil
+			})
+			evicted := []string{}
+			refusals := tt.refusals
+			fakeClientset.PrependReactor("create", "pods", func(action ktest.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+				name := action.(ktest.CreateAction).GetObject().(*policyv1beta1.Eviction).ObjectMeta.Name
+				evicted = append(evicted, name)
+				if name == "web" && refusals != 0 {
+					refusals--
+					return true, nil, apierrors.NewTooManyRequests("cannot evict pod as it would violate the pod's disruption budget", 0)
+				}
+				if name == "web" && tt.webGone {
+					return true, nil, apierrors.NewNotFound(corev1.Resource("pods"), name)
+				}
+				return true, nil, nil
+			})
+
+			err := DrainNode(fakeClientset, &node, tt.options)
+
+			if len(tt.expectErrMsg) > 0 {
+				if err == nil {
+					t.Fatalf("error expected on %s, but no error reported", tt.name)
+				}
+				for _, msg := range tt.expectErrMsg {
+					if !strings.Contains(err.Error(), msg) {
+						t.Errorf("returned error (%v) does not contain %q", err, msg)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected on %s, but an error was reported (%v)", tt.name, err)
+			}
+			if strings.Join(evicted, ",") != strings.Join(tt.expectEvict, ",") {
+				t.Errorf("evicted pods %v, expected %v", evicted, tt.expectEvict)
+			}
+		})
+	}
+}
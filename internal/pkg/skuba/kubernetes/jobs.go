@@ -24,6 +24,7 @@ import (
 
 	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
@@ -48,9 +49,10 @@ func CreateJob(client clientset.Interface, name string, spec batchv1.JobSpec) (*
 		metav1.CreateOptions{})
 }
 
-// DeleteJob deletes job with given name. Returns error
+// DeleteJob deletes job with given name, along with its pods. Returns error
 func DeleteJob(client clientset.Interface, name string) error {
-	return client.BatchV1().Jobs(metav1.NamespaceSystem).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	propagation := metav1.DeletePropagationBackground
+	return client.BatchV1().Jobs(metav1.NamespaceSystem).Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &propagation})
 }
 
 // CreateAndWaitForJob creates job and wait until discover job status active, succeeded or timeout
@@ -59,12 +61,84 @@ func CreateAndWaitForJob(client clientset.Interface, name string, spec batchv1.J
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := DeleteJob(client, name); err != nil {
-			// TODO: check if we need to fail or is just enough reporting the error
-			fmt.Printf("error deleting job %s\n", name)
+	defer deleteJobReportingError(client, name)
+	return waitForJob(client, name, timeout)
+}
+
+// CreateAndWaitForJobOutput creates job, waits for it like CreateAndWaitForJob
+// and returns the logs of the pod that ran it. The logs are also returned when
+// the job fails, so the caller can report them.
+func CreateAndWaitForJobOutput(client clientset.Interface, name string, spec batchv1.JobSpec, timeout int) (string, error) {
+	_, err := CreateJob(client, name, spec)
+	if err != nil {
+		return "", err
+	}
+	defer deleteJobReportingError(client, name)
+	waitErr := waitForJob(client, name, timeout)
+	output, err := JobOutput(client, name)
+	if waitErr != nil {
+		return output, waitErr
+	}
+	return output, err
+}
+
+// JobOutput returns the logs of the most recent pod created by the job
+func JobOutput(client clientset.Interface, name string) (string, error) {
+	pods, err := client.CoreV1().Pods(metav1.NamespaceSystem).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", name),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "could not list pods of job %s", name)
+	}
+	if len(pods.Items) == 0 {
+		return "", errors.Errorf("no pods found for job %s", name)
+	}
+	pod := pods.Items[0]
+	for _, candidate := range pods.Items[1:] {
+		if candidate.CreationTimestamp.After(pod.CreationTimestamp.Time) {
+			pod = candidate
 		}
-	}()
+	}
+	logs, err := client.CoreV1().Pods(metav1.NamespaceSystem).GetLogs(pod.Name, &v1.PodLogOptions{}).DoRaw(context.TODO())
+	if err != nil {
+		return "", errors.Wrapf(err, "could not retrieve logs of pod %s", pod.Name)
+	}
+	return string(logs), nil
+}
+
+// WaitForJobPod waits until the pod created by the job is running, and
+// returns it
+func WaitForJobPod(client clientset.Interface, name string, timeout int) (*v1.Pod, error) {
+	for i := 0; i < timeout; i++ {
+		pods, err := client.CoreV1().Pods(metav1.NamespaceSystem).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("job-name=%s", name),
+		})
+		if err != nil {
+			klog.V(1).Infof("failed to get pods for job %s, continuing...", name)
+		} else {
+			for _, pod := range pods.Items {
+				switch pod.Status.Phase {
+				case v1.PodRunning:
+					return &pod, nil
+				case v1.PodFailed, v1.PodSucceeded:
+					output, _ := JobOutput(client, name)
+					return nil, errors.Errorf("pod %s of job %s finished before it could be used: %s", pod.Name, name, output)
+				}
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return nil, errors.New(fmt.Sprintf("failed waiting for a running pod of job %s", name))
+}
+
+func deleteJobReportingError(client clientset.Interface, name string) {
+	if err := DeleteJob(client, name); err != nil {
+		// TODO: check if we need to fail or is just enough reporting the error
+		fmt.Printf("error deleting job %s\n", name)
+	}
+}
+
+func waitForJob(client clientset.Interface, name string, timeout int) error {
 	for i := 0; i < timeout; i++ {
 		job, err := client.BatchV1().Jobs(metav1.NamespaceSystem).Get(context.TODO(), name, metav1.GetOptions{})
 
@@ -82,11 +82,13 @@ func GenerateKubeletRootCert() error {
 	return nil
 }
 
-func DisarmKubelet(client clientset.Interface, node *v1.Node, clusterVersion *version.Version) error {
+// DisarmKubelet runs a job removing the kubelet configuration of the node,
+// with the tooling image of the custom image registry when it is not empty
+func DisarmKubelet(client clientset.Interface, node *v1.Node, clusterVersion *version.Version, imageRepository string) error {
 	return CreateAndWaitForJob(
 		client,
 		disarmKubeletJobName(node),
-		disarmKubeletJobSpec(node, clusterVersion),
+		disarmKubeletJobSpec(node, clusterVersion, imageRepository),
 		TimeoutWaitForJob,
 	)
 }
@@ -96,7 +98,7 @@ func disarmKubeletJobName(node *v1.Node) string {
 		sha1.Sum([]byte(node.ObjectMeta.Name)))
 }
 
-func disarmKubeletJobSpec(node *v1.Node, clusterVersion *version.Version) batchv1.JobSpec {
+func disarmKubeletJobSpec(node *v1.Node, clusterVersion *version.Version, imageRepository string) batchv1.JobSpec {
 	privilegedJob := true
 	return batchv1.JobSpec{
 		Template: v1.PodTemplateSpec{
@@ -105,7 +107,7 @@ func disarmKubeletJobSpec(node *v1.Node, clusterVersion *version.Version) batchv
 				Containers: []v1.Container{
 					{
 						Name:  disarmKubeletJobName(node),
-						Image: ComponentContainerImageForClusterVersion(Tooling, clusterVersion),
+						Image: ComponentContainerImageForClusterVersion(Tooling, clusterVersion, imageRepository),
 						Command: []string{
 							"/bin/bash", "-c",
 							strings.Join(
@@ -71,7 +71,7 @@ func TestDisarmKubelet(t *testing.T) {
 	}
 
 	t.Run("disarm kubelet", func(t *testing.T) {
-		if err := DisarmKubelet(fakeClientset, &fakeNode, LatestVersion()); err != nil {
+		if err := DisarmKubelet(fakeClientset, &fakeNode, LatestVersion(), ""); err != nil {
 			t.Errorf("error not expected, but error reported generating pki: %v", err)
 		}
 	})
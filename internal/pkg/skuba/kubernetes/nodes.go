@@ -20,13 +20,11 @@ package kubernetes
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
 	kubectldrain "k8s.io/kubectl/pkg/drain"
 	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 )
@@ -66,6 +64,16 @@ func IsControlPlane(node *corev1.Node) bool {
 	return isControlPlane
 }
 
+// IsPodReady returns whether the pod has the Ready condition
+func IsPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // UncordonNode makes a node scheduleable again
 func UncordonNode(client clientset.Interface, node *corev1.Node) error {
 	cordonHelper := kubectldrain.NewCordonHelper(node)
@@ -85,51 +93,3 @@ func UncordonNode(client clientset.Interface, node *corev1.Node) error {
 
 	return nil
 }
-
-// DrainNode cordons, drains and evict given node.
-func DrainNode(client clientset.Interface, node *corev1.Node, drainTimeout time.Duration) error {
-	policyGroupVersion, err := kubectldrain.CheckEvictionSupport(client)
-	if err != nil {
-		return errors.Wrap(err, "could not get policy group version")
-	}
-
-	newCordon := kubectldrain.NewCordonHelper(node)
-	newCordon.UpdateIfRequired(true)
-	err, patchErr := newCordon.PatchOrReplace(client, false)
-	if err != nil {
-		return errors.Wrap(err, "failed to update node status")
-	}
-	if patchErr != nil {
-		return errors.Wrap(patchErr, "failed to patch node status")
-	}
-
-	drainer := &kubectldrain.Helper{
-		Client:              client,
-		Force:               true,
-		IgnoreAllDaemonSets: true,
-		DeleteLocalData:     true,
-		Timeout:             drainTimeout,
-	}
-	del, errs := drainer.GetPodsForDeletion(node.ObjectMeta.Name)
-	if errs != nil {
-		return fmt.Errorf("could not get pods for deletion %s", errs)
-	}
-
-	if len(policyGroupVersion) > 0 {
-		for _, pod := range del.Pods() {
-			if err = drainer.EvictPod(pod, policyGroupVersion); err != nil {
-				return errors.Wrapf(err, "failed to evict pod: %v", pod.Name)
-			}
-		}
-	} else {
-		for _, pod := range del.Pods() {
-			if err = drainer.DeletePod(pod); err != nil {
-				return errors.Wrapf(err, "failed to delete pod: %v", pod.Name)
-			}
-		}
-	}
-
-	klog.V(1).Infof("node %s correctly drained", node.ObjectMeta.Name)
-
-	return nil
-}
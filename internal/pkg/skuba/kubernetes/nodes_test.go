@@ -359,7 +359,7 @@ func TestDrainNode(t *testing.T) {
 					Name: tt.node,
 				},
 			}
-			err := DrainNode(fakeClientset, &node, 10)
+			err := DrainNode(fakeClientset, &node, DefaultDrainOptions(10))
 
 			if tt.expectErrMsg != "" {
 				if err == nil {
@@ -429,3 +429,29 @@ func TestUncordonNode(t *testing.T) {
 		}
 	}
 }
+
+func TestIsPodReady(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		conditions []corev1.PodCondition
+		expected   bool
+	}{
+		{
+			name:       "ready",
+			conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}, {Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			expected:   true,
+		},
+		{
+			name:       "not ready",
+			conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}, {Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+		},
+		{
+			name: "no ready condition",
+		},
+	} {
+		pod := &corev1.Pod{Status: corev1.PodStatus{Conditions: tc.conditions}}
+		if ready := IsPodReady(pod); ready != tc.expected {
+			t.Errorf("%s: expected ready to be %t, got %t", tc.name, tc.expected, ready)
+		}
+	}
+}
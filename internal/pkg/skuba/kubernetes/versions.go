@@ -82,6 +82,27 @@ type KubernetesVersion struct {
 
 type KubernetesVersions map[string]KubernetesVersion
 
+// ExternalAddonVersion is the version of an addon defined outside of skuba,
+// supported by the cluster versions between MinClusterVersion and
+// MaxClusterVersion, when set
+type ExternalAddonVersion struct {
+	AddonVersion
+	MinClusterVersion *version.Version
+	MaxClusterVersion *version.Version
+}
+
+// SupportsClusterVersion returns whether the addon can be deployed in a
+// cluster of the given version
+func (v ExternalAddonVersion) SupportsClusterVersion(clusterVersion *version.Version) bool {
+	if v.MinClusterVersion != nil && clusterVersion.LessThan(v.MinClusterVersion) {
+		return false
+	}
+	if v.MaxClusterVersion != nil && v.MaxClusterVersion.LessThan(clusterVersion) {
+		return false
+	}
+	return true
+}
+
 type ClusterAddonsKnownVersions = func(clusterVersion *version.Version) AddonsVersion
 
 var (
@@ -233,8 +254,19 @@ var (
 			},
 		},
 	}
+
+	// externalAddonsVersion are the versions of the addons defined outside
+	// of skuba, registered with RegisterExternalAddonVersion
+	externalAddonsVersion = map[Addon]ExternalAddonVersion{}
 )
 
+// RegisterExternalAddonVersion registers the version of an addon defined
+// outside of skuba, so it is known along with the addons of every supported
+// Kubernetes version
+func RegisterExternalAddonVersion(addon Addon, addonVersion ExternalAddonVersion) {
+	externalAddonsVersion[addon] = addonVersion
+}
+
 func ComponentVersionWithAvailableVersions(component Component, clusterVersion *version.Version, availableVersions KubernetesVersions) string {
 	currentKubernetesVersion := availableVersions[clusterVersion.String()]
 	switch component {
@@ -265,10 +297,12 @@ func AllComponentContainerImagesForClusterVersion(clusterVersion *version.Versio
 	return components
 }
 
-func ComponentContainerImageForClusterVersion(component Component, clusterVersion *version.Version) string {
+// ComponentContainerImageForClusterVersion returns the image of the component,
+// in the custom image registry of the cluster when it is not empty
+func ComponentContainerImageForClusterVersion(component Component, clusterVersion *version.Version, imageRepository string) string {
 	currentKubernetesVersion := supportedVersions[clusterVersion.String()]
 	if componentDetails, found := currentKubernetesVersion.ComponentContainerVersion[component]; found {
-		return images.GetGenericImage(skuba.ImageRepository(clusterVersion), componentDetails.Name, componentDetails.Tag)
+		return images.GetGenericImage(skuba.ImageRepository(clusterVersion, imageRepository), componentDetails.Name, componentDetails.Tag)
 	}
 	klog.Errorf("unknown component %q container image", component)
 	return ""
@@ -279,11 +313,26 @@ func AddonVersionForClusterVersion(addon Addon, clusterVersion *version.Version)
 	if addonVersion, found := currentKubernetesVersion.AddonsVersion[addon]; found {
 		return addonVersion
 	}
+	if addonVersion, found := externalAddonsVersion[addon]; found && addonVersion.SupportsClusterVersion(clusterVersion) {
+		return &AddonVersion{Version: addonVersion.Version, ManifestVersion: addonVersion.ManifestVersion}
+	}
 	return nil
 }
 
 func AllAddonVersionsForClusterVersion(clusterVersion *version.Version) AddonsVersion {
-	return supportedVersions[clusterVersion.String()].AddonsVersion
+	if len(externalAddonsVersion) == 0 {
+		return supportedVersions[clusterVersion.String()].AddonsVersion
+	}
+	addonsVersion := AddonsVersion{}
+	for addon, addonVersion := range supportedVersions[clusterVersion.String()].AddonsVersion {
+		addonsVersion[addon] = addonVersion
+	}
+	for addon := range externalAddonsVersion {
+		if addonVersion := AddonVersionForClusterVersion(addon, clusterVersion); addonVersion != nil {
+			addonsVersion[addon] = addonVersion
+		}
+	}
+	return addonsVersion
 }
 
 func AvailableVersionsForMap(versions KubernetesVersions) []*version.Version {
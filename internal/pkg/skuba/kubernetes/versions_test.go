@@ -187,14 +187,14 @@ func TestComponentContainerImageForClusterVersion(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt // Parallel testing
 		t.Run(tt.name, func(t *testing.T) {
-			actual := ComponentContainerImageForClusterVersion(tt.component, tt.clusterVersion)
+			actual := ComponentContainerImageForClusterVersion(tt.component, tt.clusterVersion, "")
 			if tt.expectErr {
 				if actual != "" {
 					t.Errorf("image not expected. but a result was returned (%s)", actual)
 					return
 				}
 			} else {
-				expect := images.GetGenericImage(skuba.ImageRepository(tt.clusterVersion), tt.imageName, tt.expectVersion)
+				expect := images.GetGenericImage(skuba.ImageRepository(tt.clusterVersion, ""), tt.imageName, tt.expectVersion)
 				if actual != expect {
 					t.Errorf("returned image (%s) does not match the expected one (%s)", actual, expect)
 					return
@@ -385,3 +385,52 @@ func TestEnsureHyperKubeIsRemovedForSupportedVersions(t *testing.T) {
 	}
 	t.Errorf("please, remove HyperKube booleans (usehyperkube, needshyperkube) from code and remove this test.")
 }
+
+func TestExternalAddonVersion(t *testing.T) {
+	defer delete(externalAddonsVersion, "test-operator")
+	RegisterExternalAddonVersion("test-operator", ExternalAddonVersion{
+		AddonVersion:      AddonVersion{Version: "1.2.0", ManifestVersion: 3},
+		MinClusterVersion: version.MustParseGeneric("1.18.8"),
+		MaxClusterVersion: version.MustParseGeneric("1.18.10"),
+	})
+
+	tests := []struct {
+		name           string
+		clusterVersion *version.Version
+		expectVersion  *AddonVersion
+	}{
+		{
+			name:           "cluster version lower than the minimum",
+			clusterVersion: version.MustParseSemantic("1.18.6"),
+		},
+		{
+			name:           "cluster version between the minimum and the maximum",
+			clusterVersion: version.MustParseSemantic("1.18.10"),
+			expectVersion:  &AddonVersion{Version: "1.2.0", ManifestVersion: 3},
+		},
+		{
+			name:           "cluster version greater than the maximum",
+			clusterVersion: version.MustParseSemantic("1.19.0"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := AddonVersionForClusterVersion("test-operator", tt.clusterVersion); !reflect.DeepEqual(actual, tt.expectVersion) {
+				t.Errorf("returned addon version (%v) does not match the expected one (%v)", actual, tt.expectVersion)
+			}
+			allAddonVersions := AllAddonVersionsForClusterVersion(tt.clusterVersion)
+			if actual := allAddonVersions["test-operator"]; !reflect.DeepEqual(actual, tt.expectVersion) {
+				t.Errorf("returned addon version (%v) does not match the expected one (%v)", actual, tt.expectVersion)
+			}
+			for addon, addonVersion := range supportedVersions[tt.clusterVersion.String()].AddonsVersion {
+				if allAddonVersions[addon] != addonVersion {
+					t.Errorf("expected addon %s version %v, got %v", addon, addonVersion, allAddonVersions[addon])
+				}
+			}
+		})
+	}
+	if _, found := supportedVersions["1.18.10"].AddonsVersion["test-operator"]; found {
+		t.Error("external addon not expected in the supported versions")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clientset "k8s.io/client-go/kubernetes"
@@ -34,8 +35,14 @@ const (
 	kuredLockAnnotationJson = `{"metadata":{"annotations":{"weave.works/kured-node-lock":"{\"nodeID\":\"manual\"}"}}}`
 )
 
+// LockExists returns whether the kured daemonset is locked. When kured is
+// not deployed, e.g. disabled with `skuba addon disable`, there is nothing
+// to lock and it is reported as not locked.
 func LockExists(client clientset.Interface) (bool, error) {
 	kuredDaemonSet, err := client.AppsV1().DaemonSets(metav1.NamespaceSystem).Get(context.TODO(), "kured", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
 		return false, errors.Wrap(err, "unable to get kured daemonset")
 	}
@@ -43,6 +50,8 @@ func LockExists(client clientset.Interface) (bool, error) {
 	return ok, nil
 }
 
+// Lock prevents kured from rebooting nodes, it does nothing when kured is not
+// deployed
 func Lock(client clientset.Interface) error {
 	_, err := client.AppsV1().DaemonSets(metav1.NamespaceSystem).Patch(
 		context.TODO(),
@@ -50,6 +59,10 @@ func Lock(client clientset.Interface) error {
 		types.StrategicMergePatchType,
 		[]byte(kuredLockAnnotationJson),
 		metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(2).Info("kured daemonset not found, nothing to lock")
+		return nil
+	}
 	if err != nil {
 		return errors.Wrap(err, "unable to patch daemonset with kured locking annotation")
 	}
@@ -57,6 +70,8 @@ func Lock(client clientset.Interface) error {
 	return nil
 }
 
+// Unlock lets kured reboot nodes again, it does nothing when kured is not
+// deployed
 func Unlock(client clientset.Interface) error {
 	// jsonpatch expects a ~1 escape sequence for a forward slash '/'
 	// the annotation we want to remove is 'weave.works/kured-node-lock'
@@ -67,6 +82,10 @@ func Unlock(client clientset.Interface) error {
 		types.JSONPatchType,
 		payload,
 		metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(2).Info("kured daemonset not found, nothing to unlock")
+		return nil
+	}
 	if err != nil {
 		return errors.Wrap(err, "unable to patch daemonset with kured unlocking annotation")
 	}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package kured
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		expected bool
+	}{
+		{
+			name:     "kured deployed",
+			objects:  []runtime.Object{&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: kuredDSName, Namespace: metav1.NamespaceSystem}}},
+			expected: true,
+		},
+		{
+			name: "kured disabled",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.objects...)
+			if err := Lock(client); err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			locked, err := LockExists(client)
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if locked != tt.expected {
+				t.Errorf("expected lock to exist to be %t, got %t", tt.expected, locked)
+			}
+			if err := Unlock(client); err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if locked, err := LockExists(client); err != nil || locked {
+				t.Errorf("lock not expected to exist after unlock, got %t (%v)", locked, err)
+			}
+		})
+	}
+}
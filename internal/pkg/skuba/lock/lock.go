@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// LeaseName is the name of the lease held in kube-system while a
+	// mutating skuba command runs against the cluster
+	LeaseName = "skuba-lock"
+
+	commandAnnotation = "caasp.suse.com/skuba-lock-command"
+)
+
+// Holder describes who holds the cluster lock
+type Holder struct {
+	Identity string
+	Command  string
+	Since    time.Time
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s running %q since %s", h.Identity, h.Command, h.Since.Format(time.RFC3339))
+}
+
+// Lock is a cluster lock held by this skuba process
+type Lock struct {
+	client clientset.Interface
+	uid    types.UID
+}
+
+// Acquire takes the cluster lock on behalf of the given command, failing fast
+// when another skuba command already holds it
+func Acquire(client clientset.Interface, command string) (*Lock, error) {
+	now := metav1.NewMicroTime(time.Now())
+	identity := holderIdentity()
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        LeaseName,
+			Namespace:   metav1.NamespaceSystem,
+			Annotations: map[string]string{commandAnnotation: command},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity: &identity,
+			AcquireTime:    &now,
+			RenewTime:      &now,
+		},
+	}
+	created, err := client.CoordinationV1().Leases(metav1.NamespaceSystem).Create(context.TODO(), lease, metav1.CreateOptions{})
+	if err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, errors.Wrap(err, "unable to acquire the cluster lock")
+		}
+		holder, err := CurrentHolder(client)
+		if err != nil {
+			return nil, err
+		}
+		if holder == nil {
+			return nil, errors.New("unable to acquire the cluster lock, it was released meanwhile; please retry")
+		}
+		return nil, errors.Errorf("the cluster is locked by %s; if that command is no longer running, remove the lock with `skuba cluster unlock --force`", holder)
+	}
+	klog.V(1).Infof("cluster lock acquired by %s for %q", identity, command)
+	return &Lock{client: client, uid: created.ObjectMeta.UID}, nil
+}
+
+// Release removes the cluster lock, unless it was forcibly removed and taken
+// by someone else meanwhile
+func (l *Lock) Release() error {
+	options := metav1.DeleteOptions{}
+	if l.uid != "" {
+		options.Preconditions = metav1.NewUIDPreconditions(string(l.uid))
+	}
+	err := l.client.CoordinationV1().Leases(metav1.NamespaceSystem).Delete(context.TODO(), LeaseName, options)
+	if err != nil && !apierrors.IsNotFound(err) {
+		if apierrors.IsConflict(err) {
+			return errors.New("the cluster lock was forcibly removed and is now held by another command")
+		}
+		return errors.Wrap(err, "unable to release the cluster lock")
+	}
+	klog.V(1).Info("cluster lock released")
+	return nil
+}
+
+// Run runs fn holding the cluster lock for the given command
+func Run(client clientset.Interface, command string, fn func() error) error {
+	l, err := Acquire(client, command)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if releaseErr := l.Release(); releaseErr != nil {
+		if err != nil {
+			klog.Errorf("%v", releaseErr)
+			return err
+		}
+		return releaseErr
+	}
+	return err
+}
+
+// CurrentHolder returns who holds the cluster lock, or nil when the cluster
+// is not locked
+func CurrentHolder(client clientset.Interface) (*Holder, error) {
+	lease, err := client.CoordinationV1().Leases(metav1.NamespaceSystem).Get(context.TODO(), LeaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get the cluster lock")
+	}
+	holder := &Holder{
+		Identity: "unknown",
+		Command:  lease.ObjectMeta.Annotations[commandAnnotation],
+		Since:    lease.ObjectMeta.CreationTimestamp.Time,
+	}
+	if lease.Spec.HolderIdentity != nil {
+		holder.Identity = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.AcquireTime != nil {
+		holder.Since = lease.Spec.AcquireTime.Time
+	}
+	return holder, nil
+}
+
+// ForceUnlock removes the cluster lock whoever holds it, returning the
+// removed holder or nil when the cluster was not locked
+func ForceUnlock(client clientset.Interface) (*Holder, error) {
+	holder, err := CurrentHolder(client)
+	if err != nil || holder == nil {
+		return nil, err
+	}
+	err = client.CoordinationV1().Leases(metav1.NamespaceSystem).Delete(context.TODO(), LeaseName, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to remove the cluster lock")
+	}
+	return holder, nil
+}
+
+// holderIdentity identifies this skuba process as user@host
+func holderIdentity() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s@%s (pid %d)", username, hostname, os.Getpid())
+}
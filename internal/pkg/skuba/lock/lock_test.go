@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package lock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAcquire(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	l, err := Acquire(client, "skuba node upgrade apply")
+	if err != nil {
+		t.Fatalf("error not expected acquiring a free lock (%v)", err)
+	}
+
+	_, err = Acquire(client, "skuba addon upgrade apply")
+	if err == nil {
+		t.Fatal("error expected acquiring a held lock, but no error reported")
+	}
+	expected := `running "skuba node upgrade apply" since`
+	if !strings.Contains(err.Error(), expected) || !strings.Contains(err.Error(), holderIdentity()) {
+		t.Errorf("returned error (%v) does not report the holder (%s %s)", err, holderIdentity(), expected)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("error not expected releasing the lock (%v)", err)
+	}
+	holder, err := CurrentHolder(client)
+	if err != nil || holder != nil {
+		t.Fatalf("lock expected to be free after its release, got holder %v (%v)", holder, err)
+	}
+	if _, err := Acquire(client, "skuba addon upgrade apply"); err != nil {
+		t.Errorf("error not expected acquiring a released lock (%v)", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	ran := false
+	err := Run(client, "skuba node remove", func() error {
+		ran = true
+		holder, err := CurrentHolder(client)
+		if err != nil || holder == nil || holder.Command != "skuba node remove" {
+			t.Errorf("lock expected to be held by %q while running, got %v (%v)", "skuba node remove", holder, err)
+		}
+		return errors.New("node not found")
+	})
+	if !ran {
+		t.Error("function expected to run holding the lock")
+	}
+	if err == nil || err.Error() != "node not found" {
+		t.Errorf("returned error (%v) does not match the expected one (node not found)", err)
+	}
+	if holder, _ := CurrentHolder(client); holder != nil {
+		t.Errorf("lock expected to be released after a failure, still held by %s", holder)
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	holder, err := ForceUnlock(client)
+	if err != nil || holder != nil {
+		t.Fatalf("nothing expected to be unlocked, got holder %v (%v)", holder, err)
+	}
+
+	if _, err := Acquire(client, "skuba cluster upgrade apply"); err != nil {
+		t.Fatalf("error not expected acquiring a free lock (%v)", err)
+	}
+	holder, err = ForceUnlock(client)
+	if err != nil {
+		t.Fatalf("error not expected forcing the unlock (%v)", err)
+	}
+	if holder == nil || holder.Command != "skuba cluster upgrade apply" || holder.Identity != holderIdentity() {
+		t.Errorf("unexpected removed holder %v", holder)
+	}
+	if _, err := Acquire(client, "skuba addon upgrade apply"); err != nil {
+		t.Errorf("error not expected acquiring a forcibly released lock (%v)", err)
+	}
+}
@@ -26,14 +26,17 @@ import (
 	"github.com/SUSE/skuba/pkg/skuba"
 )
 
-func AddTargetInformationToInitConfigurationWithClusterVersion(target *deployments.Target, initConfiguration *kubeadmapi.InitConfiguration, clusterVersion *version.Version) error {
+// AddTargetInformationToInitConfigurationWithClusterVersion sets the node
+// registration of the target, with the pause image of the custom image
+// registry of the cluster when it is not empty
+func AddTargetInformationToInitConfigurationWithClusterVersion(target *deployments.Target, initConfiguration *kubeadmapi.InitConfiguration, clusterVersion *version.Version, imageRepository string) error {
 	if initConfiguration.NodeRegistration.KubeletExtraArgs == nil {
 		initConfiguration.NodeRegistration.KubeletExtraArgs = map[string]string{}
 	}
 	initConfiguration.NodeRegistration.Name = target.Nodename
 	initConfiguration.NodeRegistration.CRISocket = skuba.CRISocket
 	initConfiguration.NodeRegistration.KubeletExtraArgs["hostname-override"] = target.Nodename
-	initConfiguration.NodeRegistration.KubeletExtraArgs["pod-infra-container-image"] = kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, clusterVersion)
+	initConfiguration.NodeRegistration.KubeletExtraArgs["pod-infra-container-image"] = kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, clusterVersion, imageRepository)
 	isSUSE, err := target.IsSUSEOS()
 	if err != nil {
 		return err
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package registries
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+const (
+	// RegistriesConfFile is the containers registries configuration of the
+	// nodes
+	RegistriesConfFile = "/etc/containers/registries.conf"
+	// AuthFile holds the registry credentials CRI-O pulls the images with
+	AuthFile = "/etc/crio/registries-auth.json"
+	// CriConfFile is the CRI-O configuration pointing CRI-O to AuthFile
+	CriConfFile = "/etc/crio/crio.conf.d/10-registries.conf"
+	// RegistriesConfMarker identifies a RegistriesConfFile rendered by skuba,
+	// which is removed along with the registries configuration
+	RegistriesConfMarker = "This file is managed by CaaSP skuba, from the registries configuration"
+)
+
+// Mirror is a registry the images of another registry are pulled from first
+type Mirror struct {
+	Location string `json:"location"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// Registry configures how the images of a registry are pulled
+type Registry struct {
+	// Location is the registry, optionally followed by a repository prefix
+	Location string `json:"location"`
+	// Insecure allows pulling over plain HTTP or with an unverified TLS
+	// certificate
+	Insecure bool `json:"insecure,omitempty"`
+	// Blocked forbids pulling from the registry
+	Blocked bool `json:"blocked,omitempty"`
+	// Mirrors are tried in order before the registry itself
+	Mirrors []Mirror `json:"mirrors,omitempty"`
+}
+
+// Auth is the credential of a registry
+type Auth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Configuration is the registries configuration of the cluster definition
+// folder, rendered into the containers and CRI-O configuration of every node
+type Configuration struct {
+	Registries []Registry `json:"registries,omitempty"`
+	// Auths are the credentials of the registries and mirrors, by location
+	Auths map[string]Auth `json:"auths,omitempty"`
+}
+
+var registriesConfTemplate = template.Must(template.New("registries.conf").Funcs(template.FuncMap{
+	"quote": func(s string) string { return `"` + s + `"` },
+}).Parse(`# PLEASE DON'T EDIT THIS FILE!
+# This file is managed by CaaSP skuba, from the registries configuration of
+# the cluster definition folder.
+unqualified-search-registries = ["docker.io"]
+{{range .Registries}}
+[[registry]]
+location = {{quote .Location}}
+insecure = {{.Insecure}}
+blocked = {{.Blocked}}
+{{range .Mirrors}}
+[[registry.mirror]]
+location = {{quote .Location}}
+insecure = {{.Insecure}}
+{{end}}{{end}}`))
+
+// CriConf points CRI-O to AuthFile
+const CriConf = `# PLEASE DON'T EDIT THIS FILE!
+# This file is managed by CaaSP skuba.
+[crio.image]
+
+global_auth_file = "` + AuthFile + `"
+`
+
+// Exists returns whether the cluster definition folder has a registries
+// configuration
+func Exists() bool {
+	_, err := os.Stat(skuba.RegistriesConfFile())
+	return err == nil
+}
+
+// Load reads and validates the registries configuration of the cluster
+// definition folder
+func Load() (*Configuration, error) {
+	contents, err := ioutil.ReadFile(skuba.RegistriesConfFile())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read registries configuration %s", skuba.RegistriesConfFile())
+	}
+	configuration := &Configuration{}
+	if err := yaml.UnmarshalStrict(contents, configuration); err != nil {
+		return nil, errors.Wrapf(err, "could not parse registries configuration %s", skuba.RegistriesConfFile())
+	}
+	if err := configuration.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid registries configuration %s", skuba.RegistriesConfFile())
+	}
+	return configuration, nil
+}
+
+// Render renders the registries configuration of the cluster definition
+// folder for the nodes. An empty configuration is returned when the cluster
+// definition folder has none, removing it from the nodes.
+func Render() (deployments.RegistriesConfiguration, error) {
+	if !Exists() {
+		return deployments.RegistriesConfiguration{}, nil
+	}
+	configuration, err := Load()
+	if err != nil {
+		return deployments.RegistriesConfiguration{}, err
+	}
+	registriesConf, err := configuration.RegistriesConf()
+	if err != nil {
+		return deployments.RegistriesConfiguration{}, err
+	}
+	authFile, err := configuration.AuthFileContents()
+	if err != nil {
+		return deployments.RegistriesConfiguration{}, err
+	}
+	return deployments.RegistriesConfiguration{
+		RegistriesConf: registriesConf,
+		AuthFile:       authFile,
+	}, nil
+}
+
+// Validate checks that every registry, mirror and credential has a valid
+// location, and that every registry is configured once
+func (c *Configuration) Validate() error {
+	locations := map[string]bool{}
+	for _, registry := range c.Registries {
+		if err := validateLocation(registry.Location); err != nil {
+			return err
+		}
+		if locations[registry.Location] {
+			return errors.Errorf("registry %q is configured more than once", registry.Location)
+		}
+		locations[registry.Location] = true
+		for _, mirror := range registry.Mirrors {
+			if err := validateLocation(mirror.Location); err != nil {
+				return errors.Wrapf(err, "registry %q", registry.Location)
+			}
+		}
+	}
+	for location, auth := range c.Auths {
+		if err := validateLocation(location); err != nil {
+			return err
+		}
+		if auth.Username == "" {
+			return errors.Errorf("auth of %q: username is required", location)
+		}
+	}
+	return nil
+}
+
+func validateLocation(location string) error {
+	if location == "" {
+		return errors.New("location is required")
+	}
+	if strings.ContainsAny(location, "\"\\ \t\r\n") || strings.Contains(location, "://") {
+		return errors.Errorf("invalid location %q, expected a registry optionally followed by a repository prefix", location)
+	}
+	return nil
+}
+
+// RegistriesConf renders the registries configuration of the nodes, found
+// at RegistriesConfFile
+func (c *Configuration) RegistriesConf() (string, error) {
+	var buf bytes.Buffer
+	if err := registriesConfTemplate.Execute(&buf, c); err != nil {
+		return "", errors.Wrap(err, "could not render registries configuration")
+	}
+	return buf.String(), nil
+}
+
+// AuthFileContents renders the registry credentials of the nodes, found at
+// AuthFile, in the format of the Docker config.json file
+func (c *Configuration) AuthFileContents() (string, error) {
+	type authEntry struct {
+		Auth string `json:"auth"`
+	}
+	auths := map[string]authEntry{}
+	for location, auth := range c.Auths {
+		auths[location] = authEntry{
+			Auth: base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password)),
+		}
+	}
+	contents, err := json.MarshalIndent(map[string]interface{}{"auths": auths}, "", "    ")
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal registry credentials")
+	}
+	return string(contents) + "\n", nil
+}
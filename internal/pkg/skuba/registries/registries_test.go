@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package registries
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration Configuration
+		expectErrMsg  string
+	}{
+		{
+			name: "valid configuration",
+			configuration: Configuration{
+				Registries: []Registry{
+					{Location: "registry.suse.com", Mirrors: []Mirror{{Location: "mirror.example.com:5000/suse", Insecure: true}}},
+				},
+				Auths: map[string]Auth{"mirror.example.com:5000": {Username: "user", Password: "secret"}},
+			},
+		},
+		{
+			name:          "registry without location",
+			configuration: Configuration{Registries: []Registry{{Insecure: true}}},
+			expectErrMsg:  "location is required",
+		},
+		{
+			name:          "location with a scheme",
+			configuration: Configuration{Registries: []Registry{{Location: "https://registry.suse.com"}}},
+			expectErrMsg:  `invalid location "https://registry.suse.com", expected a registry optionally followed by a repository prefix`,
+		},
+		{
+			name:          "registry configured twice",
+			configuration: Configuration{Registries: []Registry{{Location: "registry.suse.com"}, {Location: "registry.suse.com"}}},
+			expectErrMsg:  `registry "registry.suse.com" is configured more than once`,
+		},
+		{
+			name: "mirror without location",
+			configuration: Configuration{Registries: []Registry{
+				{Location: "registry.suse.com", Mirrors: []Mirror{{}}},
+			}},
+			expectErrMsg: `registry "registry.suse.com": location is required`,
+		},
+		{
+			name:          "auth without username",
+			configuration: Configuration{Auths: map[string]Auth{"registry.suse.com": {Password: "secret"}}},
+			expectErrMsg:  `auth of "registry.suse.com": username is required`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.configuration.Validate()
+			if tt.expectErrMsg == "" {
+				if err != nil {
+					t.Errorf("error not expected, but an error was reported (%v)", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectErrMsg {
+				t.Errorf("expected error %q, got %v", tt.expectErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestRegistriesConf(t *testing.T) {
+	configuration := Configuration{
+		Registries: []Registry{
+			{Location: "registry.suse.com", Mirrors: []Mirror{{Location: "mirror.example.com:5000", Insecure: true}}},
+			{Location: "registry.example.com", Blocked: true},
+		},
+	}
+	registriesConf, err := configuration.RegistriesConf()
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	for _, expected := range []string{
+		RegistriesConfMarker,
+		"[[registry]]\nlocation = \"registry.suse.com\"\ninsecure = false\nblocked = false\n",
+		"[[registry.mirror]]\nlocation = \"mirror.example.com:5000\"\ninsecure = true\n",
+		"[[registry]]\nlocation = \"registry.example.com\"\ninsecure = false\nblocked = true\n",
+	} {
+		if !strings.Contains(registriesConf, expected) {
+			t.Errorf("expected registries.conf to contain %q, got %q", expected, registriesConf)
+		}
+	}
+}
+
+func TestAuthFileContents(t *testing.T) {
+	configuration := Configuration{
+		Auths: map[string]Auth{"mirror.example.com:5000": {Username: "user", Password: "secret"}},
+	}
+	contents, err := configuration.AuthFileContents()
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	authFile := struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal([]byte(contents), &authFile); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	// base64 of "user:secret"
+	if auth := authFile.Auths["mirror.example.com:5000"].Auth; auth != "dXNlcjpzZWNyZXQ=" {
+		t.Errorf("expected auth %q, got %q", "dXNlcjpzZWNyZXQ=", auth)
+	}
+}
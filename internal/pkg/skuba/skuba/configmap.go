@@ -19,6 +19,9 @@ package skuba
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
@@ -28,6 +31,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
 )
 
 const (
@@ -37,6 +41,35 @@ const (
 
 type SkubaConfiguration struct {
 	AddonsVersion kubernetes.AddonsVersion
+	// DisabledAddons are neither deployed nor upgraded
+	DisabledAddons []kubernetes.Addon `json:",omitempty"`
+	// ImageRepository replaces the image registry of skuba for the
+	// control plane, the pause container and the addons
+	ImageRepository string `json:",omitempty"`
+}
+
+// IsAddonDisabled returns whether the addon has been disabled
+func (skubaConfiguration *SkubaConfiguration) IsAddonDisabled(addon kubernetes.Addon) bool {
+	for _, disabledAddon := range skubaConfiguration.DisabledAddons {
+		if disabledAddon == addon {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAddonDisabled records whether the addon is disabled
+func (skubaConfiguration *SkubaConfiguration) SetAddonDisabled(addon kubernetes.Addon, disabled bool) {
+	disabledAddons := []kubernetes.Addon{}
+	for _, disabledAddon := range skubaConfiguration.DisabledAddons {
+		if disabledAddon != addon {
+			disabledAddons = append(disabledAddons, disabledAddon)
+		}
+	}
+	if disabled {
+		disabledAddons = append(disabledAddons, addon)
+	}
+	skubaConfiguration.DisabledAddons = disabledAddons
 }
 
 func GetSkubaConfiguration(client clientset.Interface) (*SkubaConfiguration, error) {
@@ -77,3 +110,49 @@ func UpdateSkubaConfiguration(client clientset.Interface, skubaConfiguration *Sk
 	}
 	return nil
 }
+
+// GetImageRepository returns the custom image registry recorded in the
+// cluster, empty when the images of skuba are used
+func GetImageRepository(client clientset.Interface) (string, error) {
+	skubaConfiguration, err := GetSkubaConfiguration(client)
+	if err != nil {
+		return "", errors.Wrap(err, "could not retrieve the skuba configuration")
+	}
+	return skubaConfiguration.ImageRepository, nil
+}
+
+// SaveImageRepository records the image registry of the cluster, an empty
+// image registry standing for the image registry of skuba
+func SaveImageRepository(client clientset.Interface, imageRepository string) error {
+	skubaConfiguration, err := GetSkubaConfiguration(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the skuba configuration")
+	}
+	skubaConfiguration.ImageRepository = imageRepository
+	return UpdateSkubaConfiguration(client, skubaConfiguration)
+}
+
+// ReadLocalImageRepository returns the custom image registry written to the
+// cluster definition folder by `skuba cluster init --image-repository`,
+// empty when the images of skuba are used
+func ReadLocalImageRepository() (string, error) {
+	contents, err := ioutil.ReadFile(skubaconstants.ImageRepositoryFile())
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "could not read %s", skubaconstants.ImageRepositoryFile())
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// WriteLocalImageRepository writes the custom image registry to the cluster
+// definition folder; nothing is written for the image registry of skuba
+func WriteLocalImageRepository(imageRepository string) error {
+	if imageRepository == "" {
+		return nil
+	}
+	if err := ioutil.WriteFile(skubaconstants.ImageRepositoryFile(), []byte(imageRepository+"\n"), 0600); err != nil {
+		return errors.Wrapf(err, "could not write %s", skubaconstants.ImageRepositoryFile())
+	}
+	return nil
+}
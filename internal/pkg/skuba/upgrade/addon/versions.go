@@ -31,6 +31,9 @@ import (
 type AddonVersionInfoUpdate struct {
 	Current kubernetes.AddonsVersion
 	Updated kubernetes.AddonsVersion
+	// Removed are the deployed addons no longer supported by the cluster
+	// version, which are pruned
+	Removed kubernetes.AddonsVersion
 }
 
 func UpdatedAddons(client clientset.Interface, clusterVersion *version.Version) (AddonVersionInfoUpdate, error) {
@@ -38,7 +41,12 @@ func UpdatedAddons(client clientset.Interface, clusterVersion *version.Version)
 	if err != nil {
 		return AddonVersionInfoUpdate{}, err
 	}
-	return UpdatedAddonsForAddonsVersion(clusterVersion, skubaConfig.AddonsVersion, kubernetes.AllAddonVersionsForClusterVersion), nil
+	aviu := UpdatedAddonsForAddonsVersion(clusterVersion, skubaConfig.AddonsVersion, kubernetes.AllAddonVersionsForClusterVersion)
+	for _, addon := range skubaConfig.DisabledAddons {
+		delete(aviu.Current, addon)
+		delete(aviu.Updated, addon)
+	}
+	return aviu, nil
 }
 
 func UpdatedAddonsForAddonsVersion(clusterVersion *version.Version, addonsVersion kubernetes.AddonsVersion, clusterAddonsKnownVersions kubernetes.ClusterAddonsKnownVersions) AddonVersionInfoUpdate {
@@ -54,6 +62,15 @@ func UpdatedAddonsForAddonsVersion(clusterVersion *version.Version, addonsVersio
 			aviu.Updated[addonName] = addonLatestVersion
 		}
 	}
+	for addonName, addonCurrentVersion := range addonsVersion {
+		if _, found := latestAddonVersions[addonName]; found {
+			continue
+		}
+		if aviu.Removed == nil {
+			aviu.Removed = kubernetes.AddonsVersion{}
+		}
+		aviu.Removed[addonName] = addonCurrentVersion
+	}
 	return aviu
 }
 
@@ -77,6 +94,14 @@ func hasAddonVersionBump(addonVersionInfoUpdate AddonVersionInfoUpdate, addon ku
 }
 
 func PrintAddonUpdates(updatedAddons AddonVersionInfoUpdate) {
+	for _, addon := range addonsByName(updatedAddons.Removed) {
+		if updatedAddons.Removed[addon] != nil && len(updatedAddons.Removed[addon].Version) > 0 {
+			fmt.Printf("  - %s: %s (removed)\n", addon, updatedAddons.Removed[addon].Version)
+		} else {
+			fmt.Printf("  - %s (removed)\n", addon)
+		}
+	}
+
 	for _, addon := range addonsByName(updatedAddons.Updated) {
 		if updatedAddons.Current[addon] == nil && updatedAddons.Updated[addon] != nil {
 			if len(updatedAddons.Updated[addon].Version) > 0 {
@@ -103,5 +128,5 @@ func PrintAddonUpdates(updatedAddons AddonVersionInfoUpdate) {
 }
 
 func HasAddonUpdate(aviu AddonVersionInfoUpdate) bool {
-	return len(aviu.Updated) > 0
+	return len(aviu.Updated) > 0 || len(aviu.Removed) > 0
 }
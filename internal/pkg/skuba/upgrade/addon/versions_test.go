@@ -223,3 +223,64 @@ func ExamplePrintAddonUpdates() {
 	//   - dex: 2.16.0 -> 2.17.0
 	//   - gangway: 3.1.0 (new addon)
 }
+
+func TestUpdatedAddonsForAddonsVersionRemoved(t *testing.T) {
+	known := func(clusterVersion *version.Version) kubernetes.AddonsVersion {
+		return kubernetes.AddonsVersion{
+			kubernetes.Cilium: &kubernetes.AddonVersion{Version: "1.7.6", ManifestVersion: 1},
+		}
+	}
+	current := kubernetes.AddonsVersion{
+		kubernetes.Cilium: &kubernetes.AddonVersion{Version: "1.7.6", ManifestVersion: 1},
+		kubernetes.Kucero: &kubernetes.AddonVersion{Version: "1.1.1", ManifestVersion: 0},
+	}
+	aviu := UpdatedAddonsForAddonsVersion(version.MustParseSemantic("1.2.3"), current, known)
+	expected := kubernetes.AddonsVersion{
+		kubernetes.Kucero: &kubernetes.AddonVersion{Version: "1.1.1", ManifestVersion: 0},
+	}
+	if !reflect.DeepEqual(aviu.Removed, expected) {
+		t.Errorf("got removed addons: %v, expect: %v", aviu.Removed, expected)
+	}
+	if !HasAddonUpdate(aviu) {
+		t.Error("removed addons expected to be an addon update")
+	}
+}
+
+func TestUpdatedAddonsSkipsDisabledAddons(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	clusterVersion := kubernetes.LatestVersion()
+	err := skuba.UpdateSkubaConfiguration(client, &skuba.SkubaConfiguration{
+		AddonsVersion:  kubernetes.AllAddonVersionsForClusterVersion(clusterVersion),
+		DisabledAddons: []kubernetes.Addon{kubernetes.Kured},
+	})
+	if err != nil {
+		t.Fatalf("error not expected but an error was reported %v", err)
+	}
+	// the disabled addon is also missing from the deployed addons
+	skubaConfiguration, _ := skuba.GetSkubaConfiguration(client)
+	delete(skubaConfiguration.AddonsVersion, kubernetes.Kured)
+	if err := skuba.UpdateSkubaConfiguration(client, skubaConfiguration); err != nil {
+		t.Fatalf("error not expected but an error was reported %v", err)
+	}
+
+	aviu, err := UpdatedAddons(client, clusterVersion)
+	if err != nil {
+		t.Fatalf("error not expected but an error was reported %v", err)
+	}
+	if HasAddonUpdate(aviu) {
+		t.Errorf("no addon update expected for a disabled addon, got %v", aviu.Updated)
+	}
+}
+
+func ExamplePrintAddonUpdates_removed() {
+	PrintAddonUpdates(AddonVersionInfoUpdate{
+		Current: kubernetes.AddonsVersion{},
+		Updated: kubernetes.AddonsVersion{},
+		Removed: kubernetes.AddonsVersion{
+			kubernetes.Kucero: &kubernetes.AddonVersion{Version: "1.1.1", ManifestVersion: 0},
+		},
+	})
+
+	// Output:
+	//   - kucero: 1.1.1 (removed)
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	"github.com/SUSE/skuba/pkg/skuba"
 	clusterinit "github.com/SUSE/skuba/pkg/skuba/actions/cluster/init"
 )
@@ -45,8 +46,12 @@ func CriMigrate() error {
 }
 
 func criGenerateLocalConfiguration() error {
+	imageRepository, err := skubaconfig.ReadLocalImageRepository()
+	if err != nil {
+		return err
+	}
 	cfg := clusterinit.InitConfiguration{
-		PauseImage:        kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, kubernetes.LatestVersion()),
+		PauseImage:        kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, kubernetes.LatestVersion(), imageRepository),
 		StrictCapDefaults: !criHadStrictCapDefaults(),
 	}
 
@@ -34,16 +34,10 @@ func AddonsBaseManifest(client clientset.Interface) error {
 		return err
 	}
 
-	clusterConfiguration, err := kubeadm.GetClusterConfiguration(client)
-	if err != nil {
-		return errors.Wrap(err, "Could not fetch cluster configuration")
-	}
-
 	// re-render all addons manifest
-	addonConfiguration := addons.AddonConfiguration{
-		ClusterVersion: currentClusterVersion,
-		ControlPlane:   clusterConfiguration.ControlPlaneEndpoint,
-		ClusterName:    clusterConfiguration.ClusterName,
+	addonConfiguration, err := addons.NewAddonConfiguration(client, currentClusterVersion)
+	if err != nil {
+		return err
 	}
 	for addonName, addon := range addons.Addons {
 		if !addon.IsPresentForClusterVersion(currentClusterVersion) {
@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+// Package toggle implements the `skuba addon enable` and `skuba addon
+// disable` commands
+package toggle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+var (
+	renderAddon = func(addon addons.Addon, addonConfiguration addons.AddonConfiguration) error {
+		return addon.Write(addonConfiguration)
+	}
+	applyAddon = func(client clientset.Interface, addon addons.Addon, addonConfiguration addons.AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration) error {
+		return addon.Apply(client, addonConfiguration, skubaConfiguration, addons.ApplyOptions{RolloutTimeout: addons.DefaultRolloutTimeout})
+	}
+	deleteAddon = func(client clientset.Interface, addon addons.Addon, skubaConfiguration *skuba.SkubaConfiguration) error {
+		return addon.Delete(client, skubaConfiguration, false)
+	}
+)
+
+func getAddonConfiguration(client clientset.Interface) (addons.AddonConfiguration, error) {
+	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return addons.AddonConfiguration{}, err
+	}
+	return addons.NewAddonConfiguration(client, currentClusterVersion)
+}
+
+// Disable deletes the objects of the addon from the cluster and records it as
+// disabled, so it is neither deployed nor upgraded anymore
+func Disable(client clientset.Interface, addonName string) error {
+	addon, err := addons.GetAddon(addonName)
+	if err != nil {
+		return err
+	}
+	if addon.IsRequired() {
+		return errors.Errorf("addon %s is required by the cluster and cannot be disabled", addonName)
+	}
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		return err
+	}
+	if skubaConfiguration.IsAddonDisabled(addon.Addon) {
+		fmt.Printf("[disable] addon %s is already disabled\n", addonName)
+		return nil
+	}
+	if dependents := enabledDependents(addon, skubaConfiguration); len(dependents) > 0 {
+		return errors.Errorf("addon %s is required by the enabled addons %s, disable them first", addonName, strings.Join(dependents, ", "))
+	}
+
+	if _, deployed := skubaConfiguration.AddonsVersion[addon.Addon]; deployed {
+		addonConfiguration, err := getAddonConfiguration(client)
+		if err != nil {
+			return err
+		}
+		if addon.IsPresentForClusterVersion(addonConfiguration.ClusterVersion) {
+			if err := renderAddon(addon, addonConfiguration); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("[disable] deleting addon %s from the cluster\n", addonName)
+		if err := deleteAddon(client, addon, skubaConfiguration); err != nil {
+			return errors.Wrapf(err, "failed to delete addon %s", addonName)
+		}
+	}
+
+	skubaConfiguration.SetAddonDisabled(addon.Addon, true)
+	if err := skuba.UpdateSkubaConfiguration(client, skubaConfiguration); err != nil {
+		return err
+	}
+	fmt.Printf("[disable] addon %s disabled\n", addonName)
+	return nil
+}
+
+// enabledDependents returns the sorted names of the addons that are not
+// disabled and depend on the addon
+func enabledDependents(addon addons.Addon, skubaConfiguration *skuba.SkubaConfiguration) []string {
+	dependents := []string{}
+	for name, dependent := range addons.Addons {
+		if dependent.DependsOn(addon.Addon) && !skubaConfiguration.IsAddonDisabled(name) {
+			dependents = append(dependents, string(name))
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// disabledDependencies returns the sorted names of the disabled addons the
+// addon depends on
+func disabledDependencies(addon addons.Addon, skubaConfiguration *skuba.SkubaConfiguration) []string {
+	dependencies := []string{}
+	for name := range addons.Addons {
+		if addon.DependsOn(name) && skubaConfiguration.IsAddonDisabled(name) {
+			dependencies = append(dependencies, string(name))
+		}
+	}
+	sort.Strings(dependencies)
+	return dependencies
+}
+
+// Enable removes the addon from the disabled addons and deploys it
+func Enable(client clientset.Interface, addonName string) error {
+	addon, err := addons.GetAddon(addonName)
+	if err != nil {
+		return err
+	}
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		return err
+	}
+	if !skubaConfiguration.IsAddonDisabled(addon.Addon) {
+		fmt.Printf("[enable] addon %s is already enabled\n", addonName)
+		return nil
+	}
+	if dependencies := disabledDependencies(addon, skubaConfiguration); len(dependencies) > 0 {
+		return errors.Errorf("addon %s requires the disabled addons %s, enable them first", addonName, strings.Join(dependencies, ", "))
+	}
+
+	skubaConfiguration.SetAddonDisabled(addon.Addon, false)
+	if err := skuba.UpdateSkubaConfiguration(client, skubaConfiguration); err != nil {
+		return err
+	}
+
+	addonConfiguration, err := getAddonConfiguration(client)
+	if err != nil {
+		return err
+	}
+	if !addon.IsPresentForClusterVersion(addonConfiguration.ClusterVersion) {
+		fmt.Printf("[enable] addon %s enabled, it is not available on Kubernetes %s\n", addonName, addonConfiguration.ClusterVersion)
+		return nil
+	}
+	if err := renderAddon(addon, addonConfiguration); err != nil {
+		return err
+	}
+	fmt.Printf("[enable] deploying addon %s\n", addonName)
+	if err := applyAddon(client, addon, addonConfiguration, skubaConfiguration); err != nil {
+		return errors.Wrapf(err, "failed to deploy addon %s", addonName)
+	}
+	fmt.Printf("[enable] addon %s enabled\n", addonName)
+	return nil
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package toggle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+func toggleTestClientset(t *testing.T, skubaConfiguration *skuba.SkubaConfiguration) clientset.Interface {
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: metav1.NamespaceSystem},
+			Data: map[string]string{
+				"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.18.10
+clusterName: my-cluster
+controlPlaneEndpoint: 10.0.0.1:6443
+`,
+			},
+		},
+	)
+	if err := skuba.UpdateSkubaConfiguration(client, skubaConfiguration); err != nil {
+		t.Fatalf("could not create skuba-config: %v", err)
+	}
+	return client
+}
+
+func stubAddonActions(rendered, applied, deleted *[]kubernetes.Addon) func() {
+	originalRender, originalApply, originalDelete := renderAddon, applyAddon, deleteAddon
+	renderAddon = func(addon addons.Addon, addonConfiguration addons.AddonConfiguration) error {
+		*rendered = append(*rendered, addon.Addon)
+		return nil
+	}
+	applyAddon = func(client clientset.Interface, addon addons.Addon, addonConfiguration addons.AddonConfiguration, skubaConfiguration *skuba.SkubaConfiguration) error {
+		*applied = append(*applied, addon.Addon)
+		return nil
+	}
+	deleteAddon = func(client clientset.Interface, addon addons.Addon, skubaConfiguration *skuba.SkubaConfiguration) error {
+		*deleted = append(*deleted, addon.Addon)
+		delete(skubaConfiguration.AddonsVersion, addon.Addon)
+		return skuba.UpdateSkubaConfiguration(client, skubaConfiguration)
+	}
+	return func() {
+		renderAddon, applyAddon, deleteAddon = originalRender, originalApply, originalDelete
+	}
+}
+
+func TestDisable(t *testing.T) {
+	kuredVersion := &kubernetes.AddonVersion{Version: "1.4.3", ManifestVersion: 4520}
+	tests := []struct {
+		name           string
+		addon          string
+		config         skuba.SkubaConfiguration
+		expectDeleted  []kubernetes.Addon
+		expectDisabled []kubernetes.Addon
+		expectErrMsg   string
+	}{
+		{
+			name:           "deployed addon",
+			addon:          "kured",
+			config:         skuba.SkubaConfiguration{AddonsVersion: kubernetes.AddonsVersion{kubernetes.Kured: kuredVersion}},
+			expectDeleted:  []kubernetes.Addon{kubernetes.Kured},
+			expectDisabled: []kubernetes.Addon{kubernetes.Kured},
+		},
+		{
+			name:           "addon not deployed",
+			addon:          "kured",
+			expectDisabled: []kubernetes.Addon{kubernetes.Kured},
+		},
+		{
+			name:           "already disabled addon",
+			addon:          "kured",
+			config:         skuba.SkubaConfiguration{DisabledAddons: []kubernetes.Addon{kubernetes.Kured}},
+			expectDisabled: []kubernetes.Addon{kubernetes.Kured},
+		},
+		{
+			name:         "addon with enabled dependents",
+			addon:        "dex",
+			expectErrMsg: "addon dex is required by the enabled addons gangway, disable them first",
+		},
+		{
+			name:           "addon with disabled dependents",
+			addon:          "dex",
+			config:         skuba.SkubaConfiguration{DisabledAddons: []kubernetes.Addon{kubernetes.Gangway}},
+			expectDisabled: []kubernetes.Addon{kubernetes.Gangway, kubernetes.Dex},
+		},
+		{
+			name:         "required addon",
+			addon:        "cilium",
+			expectErrMsg: "addon cilium is required by the cluster and cannot be disabled",
+		},
+		{
+			name:         "unknown addon",
+			addon:        "foo",
+			expectErrMsg: `unknown addon "foo", available addons:`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			var rendered, applied, deleted []kubernetes.Addon
+			defer stubAddonActions(&rendered, &applied, &deleted)()
+			client := toggleTestClientset(t, &tt.config)
+
+			err := Disable(client, tt.addon)
+			if tt.expectErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErrMsg) {
+					t.Errorf("returned error (%v) does not contain %q", err, tt.expectErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if !reflect.DeepEqual(deleted, tt.expectDeleted) {
+				t.Errorf("deleted addons %v, expected %v", deleted, tt.expectDeleted)
+			}
+			skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+			if err != nil {
+				t.Fatalf("could not get skuba-config: %v", err)
+			}
+			if !reflect.DeepEqual(skubaConfiguration.DisabledAddons, tt.expectDisabled) {
+				t.Errorf("disabled addons %v, expected %v", skubaConfiguration.DisabledAddons, tt.expectDisabled)
+			}
+			if _, found := skubaConfiguration.AddonsVersion[kubernetes.Kured]; found {
+				t.Error("version of a disabled addon expected to be removed from skuba-config")
+			}
+		})
+	}
+}
+
+func TestEnable(t *testing.T) {
+	var rendered, applied, deleted []kubernetes.Addon
+	defer stubAddonActions(&rendered, &applied, &deleted)()
+	client := toggleTestClientset(t, &skuba.SkubaConfiguration{DisabledAddons: []kubernetes.Addon{kubernetes.Dex, kubernetes.Kured}})
+
+	if err := Enable(client, "kured"); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if expected := []kubernetes.Addon{kubernetes.Kured}; !reflect.DeepEqual(applied, expected) || !reflect.DeepEqual(rendered, expected) {
+		t.Errorf("rendered %v and applied %v addons, expected %v", rendered, applied, expected)
+	}
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		t.Fatalf("could not get skuba-config: %v", err)
+	}
+	if expected := []kubernetes.Addon{kubernetes.Dex}; !reflect.DeepEqual(skubaConfiguration.DisabledAddons, expected) {
+		t.Errorf("disabled addons %v, expected %v", skubaConfiguration.DisabledAddons, expected)
+	}
+
+	applied = nil
+	if err := Enable(client, "kured"); err != nil {
+		t.Fatalf("error not expected enabling an enabled addon (%v)", err)
+	}
+	if len(applied) > 0 {
+		t.Errorf("enabled addon expected not to be applied again, applied %v", applied)
+	}
+}
+
+func TestEnableWithDisabledDependency(t *testing.T) {
+	var rendered, applied, deleted []kubernetes.Addon
+	defer stubAddonActions(&rendered, &applied, &deleted)()
+	client := toggleTestClientset(t, &skuba.SkubaConfiguration{DisabledAddons: []kubernetes.Addon{kubernetes.Dex, kubernetes.Gangway}})
+
+	err := Enable(client, "gangway")
+	if expected := "addon gangway requires the disabled addons dex, enable them first"; err == nil || err.Error() != expected {
+		t.Errorf("returned error (%v) does not match the expected one (%v)", err, expected)
+	}
+	if len(applied) > 0 {
+		t.Errorf("addon with a disabled dependency expected not to be applied, applied %v", applied)
+	}
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		t.Fatalf("could not get skuba-config: %v", err)
+	}
+	if expected := []kubernetes.Addon{kubernetes.Dex, kubernetes.Gangway}; !reflect.DeepEqual(skubaConfiguration.DisabledAddons, expected) {
+		t.Errorf("disabled addons %v, expected %v", skubaConfiguration.DisabledAddons, expected)
+	}
+}
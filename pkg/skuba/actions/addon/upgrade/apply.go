@@ -31,21 +31,15 @@ import (
 )
 
 // Apply implements the `skuba addon upgrade apply` command.
-func Apply(client clientset.Interface) error {
+func Apply(client clientset.Interface, applyOptions addons.ApplyOptions) error {
 	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
 	if err != nil {
 		return err
 	}
 
-	clusterConfiguration, err := kubeadm.GetClusterConfiguration(client)
+	addonConfiguration, err := addons.NewAddonConfiguration(client, currentClusterVersion)
 	if err != nil {
-		return errors.Wrap(err, "[apply] Could not fetch cluster configuration")
-	}
-
-	addonConfiguration := addons.AddonConfiguration{
-		ClusterVersion: currentClusterVersion,
-		ControlPlane:   clusterConfiguration.ControlPlaneEndpoint,
-		ClusterName:    clusterConfiguration.ClusterName,
+		return errors.Wrap(err, "[apply] could not get the addon configuration")
 	}
 
 	// check local addons cluster folder configuration is up-to-date
@@ -80,8 +74,7 @@ func Apply(client clientset.Interface) error {
 	}
 
 	if addon.HasAddonUpdate(updatedAddons) {
-		dryRun := false
-		if err := addons.DeployAddons(client, addonConfiguration, dryRun); err != nil {
+		if err := addons.DeployAddons(client, addonConfiguration, applyOptions); err != nil {
 			return errors.Wrap(err, "[apply] Failed to deploy addons")
 		}
 
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package addons
+
+import (
+	"fmt"
+
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+// Diff implements the `skuba addon diff` command. It prints the changes that
+// applying the addon manifest of the cluster definition folder, with its
+// patches, makes to the objects of the cluster.
+func Diff(client clientset.Interface, addonName string) error {
+	addon, err := addons.GetAddon(addonName)
+	if err != nil {
+		return err
+	}
+	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return err
+	}
+	addonConfiguration, err := addons.NewAddonConfiguration(client, currentClusterVersion)
+	if err != nil {
+		return err
+	}
+
+	// check local addons cluster folder configuration is up-to-date
+	match, err := addons.CheckLocalAddonsBaseManifests(addonConfiguration)
+	if err != nil {
+		return err
+	}
+	if !match {
+		fmt.Println("Current local addons cluster folder configuration is out-of-date.")
+		fmt.Println("Please run \"skuba addon refresh localconfig\" before you diff addons.")
+		return nil
+	}
+
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		return err
+	}
+	diffs, err := addon.Diff(addonConfiguration, skubaConfiguration)
+	if err != nil {
+		return err
+	}
+	printDiffs(diffs)
+	return nil
+}
+
+func printDiffs(diffs []addons.ObjectDiff) {
+	if len(diffs) == 0 {
+		fmt.Println("No changes to the cluster objects")
+		return
+	}
+	fmt.Println("Changes to the cluster objects:")
+	addons.PrintObjectDiffs(diffs)
+}
@@ -29,20 +29,16 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/upgrade/addon"
 )
 
-func Plan(client clientset.Interface) error {
+// Plan implements the `skuba addon upgrade plan` command. With showDiff, the
+// changes the upgrade makes to the objects of the cluster are printed too.
+func Plan(client clientset.Interface, showDiff bool) error {
 	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
 	if err != nil {
 		return err
 	}
-	clusterConfiguration, err := kubeadm.GetClusterConfiguration(client)
+	addonConfiguration, err := addons.NewAddonConfiguration(client, currentClusterVersion)
 	if err != nil {
-		return errors.Wrap(err, "Could not fetch cluster configuration")
-	}
-
-	addonConfiguration := addons.AddonConfiguration{
-		ClusterVersion: currentClusterVersion,
-		ControlPlane:   clusterConfiguration.ControlPlaneEndpoint,
-		ClusterName:    clusterConfiguration.ClusterName,
+		return err
 	}
 
 	// check local addons cluster folder configuration is up-to-date
@@ -79,10 +75,18 @@ func Plan(client clientset.Interface) error {
 		fmt.Printf("Addon upgrades for %s:\n", currentVersion)
 		addon.PrintAddonUpdates(updatedAddons)
 
-		dryRun := true
-		if err := addons.DeployAddons(client, addonConfiguration, dryRun); err != nil {
+		if err := addons.DeployAddons(client, addonConfiguration, addons.ApplyOptions{DryRun: true}); err != nil {
 			return errors.Wrap(err, "Failed to plan addons")
 		}
+
+		if showDiff {
+			diffs, err := addons.DiffAddons(client, addonConfiguration)
+			if err != nil {
+				return errors.Wrap(err, "Failed to diff addons")
+			}
+			fmt.Println()
+			printDiffs(diffs)
+		}
 	} else {
 		fmt.Printf("Congratulations! Addons for %s are already at the latest version available\n", currentVersion)
 	}
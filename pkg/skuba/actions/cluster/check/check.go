@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+const (
+	// DefaultCertExpirationThreshold is the default time before expiration
+	// at which a certificate is reported as failing
+	DefaultCertExpirationThreshold = 30 * 24 * time.Hour
+	// DefaultEndpointTimeout is the default time to wait for an API endpoint
+	// to answer
+	DefaultEndpointTimeout = 10 * time.Second
+)
+
+// CheckOptions are the options of the cluster check
+type CheckOptions struct {
+	// CertExpirationThreshold is the time before expiration at which a
+	// certificate is reported as failing
+	CertExpirationThreshold time.Duration
+	// EndpointTimeout is the time to wait for an API endpoint to answer
+	EndpointTimeout time.Duration
+	// PkiDir is the local directory holding the cluster certificate authorities
+	PkiDir string
+}
+
+// ProbeResult is the result of a probe on one of its targets
+type ProbeResult struct {
+	Probe   string
+	Target  string
+	Healthy bool
+	Details string
+}
+
+// clusterHealth is replaced in tests, as etcdctl jobs never complete with a
+// fake clientset
+var clusterHealth = etcd.GetClusterHealth
+
+type checker struct {
+	client  clientset.Interface
+	options CheckOptions
+	now     time.Time
+}
+
+type probe struct {
+	name string
+	run  func(*checker) ([]ProbeResult, error)
+}
+
+var probes = []probe{
+	{name: "etcd", run: (*checker).checkEtcd},
+	{name: "api-endpoint", run: (*checker).checkAPIEndpoints},
+	{name: "certificate", run: (*checker).checkCertificates},
+	{name: "addon", run: (*checker).checkAddons},
+	{name: "cilium", run: (*checker).checkCilium},
+}
+
+// Check runs all the probes against the cluster, prints a report of every
+// probe on the standard output and returns an error if any probe failed
+func Check(client clientset.Interface, options CheckOptions) error {
+	if options.PkiDir == "" {
+		options.PkiDir = skubaconstants.PkiDir()
+	}
+	results := RunProbes(client, options, time.Now())
+	if err := PrintReport(os.Stdout, results); err != nil {
+		return err
+	}
+	return Failed(results)
+}
+
+// RunProbes runs all the probes against the cluster as of now and returns
+// their results. A probe that cannot run is reported as failed.
+func RunProbes(client clientset.Interface, options CheckOptions, now time.Time) []ProbeResult {
+	c := &checker{
+		client:  client,
+		options: options,
+		now:     now,
+	}
+	results := []ProbeResult{}
+	for _, probe := range probes {
+		probeResults, err := probe.run(c)
+		if err != nil {
+			probeResults = append(probeResults, ProbeResult{
+				Probe:   probe.name,
+				Target:  "cluster",
+				Details: err.Error(),
+			})
+		}
+		results = append(results, probeResults...)
+	}
+	return results
+}
+
+// Failed returns an error if any of the results is not healthy
+func Failed(results []ProbeResult) error {
+	failed := 0
+	for _, result := range results {
+		if !result.Healthy {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d out of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+// PrintReport writes a table with the result of every probe
+func PrintReport(out io.Writer, results []ProbeResult) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"PROBE", "TARGET", "STATUS", "DETAILS"}, "\t"))
+	for _, result := range results {
+		status := "FAILED"
+		if result.Healthy {
+			status = "OK"
+		}
+		fmt.Fprintln(w, strings.Join([]string{result.Probe, result.Target, status, result.Details}, "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	certutil "k8s.io/client-go/util/cert"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+func selfSignedCertPEM(t *testing.T, commonName string) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	cert, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: commonName}, key)
+	if err != nil {
+		t.Fatalf("could not generate certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func checkTestClientset(t *testing.T, apiServerAddress string, dexCertPEM []byte) clientset.Interface {
+	host, port, err := net.SplitHostPort(apiServerAddress)
+	if err != nil {
+		t.Fatalf("invalid address: %v", err)
+	}
+	addonsVersion := kubernetes.AddonsVersion{}
+	for addon, addonVersion := range kubernetes.AllAddonVersionsForClusterVersion(version.MustParseSemantic("v1.18.10")) {
+		addonsVersion[addon] = &kubernetes.AddonVersion{Version: addonVersion.Version, ManifestVersion: addonVersion.ManifestVersion}
+	}
+	addonsVersion[kubernetes.Kured].ManifestVersion--
+	delete(addonsVersion, kubernetes.MetricsServer)
+	skubaConfiguration, err := yaml.Marshal(skuba.SkubaConfiguration{AddonsVersion: addonsVersion, DisabledAddons: []kubernetes.Addon{kubernetes.MetricsServer}})
+	if err != nil {
+		t.Fatalf("could not marshal skuba configuration: %v", err)
+	}
+
+	return fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: metav1.NamespaceSystem},
+			Data: map[string]string{
+				"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.18.10
+`,
+				"ClusterStatus": fmt.Sprintf(`
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterStatus
+apiEndpoints:
+  master-1:
+    advertiseAddress: %s
+    bindPort: %s
+  master-2:
+    advertiseAddress: 127.0.0.1
+    bindPort: 1
+`, host, port),
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: skuba.ConfigMapName, Namespace: metav1.NamespaceSystem},
+			Data:       map[string]string{skuba.SkubaConfigurationKeyName: string(skubaConfiguration)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "oidc-dex-cert", Namespace: metav1.NamespaceSystem},
+			Data:       map[string][]byte{corev1.TLSCertKey: dexCertPEM},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "cilium-abcde", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"k8s-app": "cilium"}},
+			Spec:       corev1.PodSpec{NodeName: "master-1"},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "cilium-fghij", Namespace: metav1.NamespaceSystem, Labels: map[string]string{"k8s-app": "cilium"}},
+			Spec:       corev1.PodSpec{NodeName: "worker-1"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "cilium-agent", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+				},
+			},
+		},
+	)
+}
+
+func TestRunProbes(t *testing.T) {
+	apiServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer apiServer.Close()
+
+	pkiDir, err := ioutil.TempDir("", "skuba-check")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(pkiDir)
+	if err := os.MkdirAll(filepath.Join(pkiDir, "etcd"), 0700); err != nil {
+		t.Fatalf("could not create etcd pki directory: %v", err)
+	}
+	apiServerCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: apiServer.Certificate().Raw})
+	if err := ioutil.WriteFile(filepath.Join(pkiDir, "ca.crt"), apiServerCA, 0600); err != nil {
+		t.Fatalf("could not write certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(pkiDir, "etcd", "ca.crt"), selfSignedCertPEM(t, "etcd-ca"), 0600); err != nil {
+		t.Fatalf("could not write certificate: %v", err)
+	}
+
+	defer func(original func(clientset.Interface, *version.Version) (*etcd.ClusterHealth, error)) {
+		clusterHealth = original
+	}(clusterHealth)
+	clusterHealth = func(client clientset.Interface, clusterVersion *version.Version) (*etcd.ClusterHealth, error) {
+		return &etcd.ClusterHealth{
+			Executor: "master-1",
+			Endpoints: []etcd.EndpointHealth{
+				{Endpoint: "https://10.0.0.1:2379", Health: true},
+				{Endpoint: "https://10.0.0.2:2379", Error: "context deadline exceeded"},
+			},
+			Members: []etcd.EndpointStatus{
+				{Endpoint: "https://10.0.0.1:2379", MemberID: 0x1234, IsLeader: true},
+			},
+		}, nil
+	}
+
+	client := checkTestClientset(t, apiServer.Listener.Addr().String(), selfSignedCertPEM(t, "oidc-dex"))
+	options := CheckOptions{
+		CertExpirationThreshold: DefaultCertExpirationThreshold,
+		EndpointTimeout:         DefaultEndpointTimeout,
+		PkiDir:                  pkiDir,
+	}
+
+	statuses := func(results []ProbeResult) map[string]bool {
+		healthy := map[string]bool{}
+		for _, result := range results {
+			healthy[fmt.Sprintf("%s %s", result.Probe, result.Target)] = result.Healthy
+		}
+		return healthy
+	}
+
+	results := RunProbes(client, options, time.Now())
+	expected := map[string]bool{
+		"etcd https://10.0.0.1:2379": true,
+		"etcd https://10.0.0.2:2379": false,
+		"etcd leader":                true,
+		fmt.Sprintf("api-endpoint %s (master-1)", apiServer.Listener.Addr()): true,
+		"api-endpoint 127.0.0.1:1 (master-2)":                                false,
+		"certificate apiserver serving certificate on master-1":              true,
+		"certificate " + filepath.Join(pkiDir, "ca.crt"):                     true,
+		"certificate " + filepath.Join(pkiDir, "etcd", "ca.crt"):             true,
+		"certificate secret kube-system/oidc-dex-cert":                       true,
+		"addon kured":                    false,
+		"addon cilium":                   true,
+		"cilium cilium-abcde (master-1)": true,
+		"cilium cilium-fghij (worker-1)": false,
+	}
+	healthy := statuses(results)
+	for target, expectedHealthy := range expected {
+		if got, found := healthy[target]; !found || got != expectedHealthy {
+			t.Errorf("expected %q to be healthy=%v, got %v (found: %v)", target, expectedHealthy, got, found)
+		}
+	}
+	if _, found := healthy["certificate secret kube-system/oidc-gangway-cert"]; !found {
+		t.Error("expected missing gangway certificate secret to be reported")
+	}
+	if _, found := healthy["addon metrics-server"]; found {
+		t.Error("expected disabled metrics-server addon not to be checked")
+	}
+
+	// ten years later, the self signed certificates are about to expire
+	healthy = statuses(RunProbes(client, options, time.Now().Add(10*365*24*time.Hour-10*24*time.Hour)))
+	if healthy["certificate "+filepath.Join(pkiDir, "etcd", "ca.crt")] {
+		t.Error("expected etcd CA about to expire to be reported as failed")
+	}
+	if !healthy["certificate "+filepath.Join(pkiDir, "ca.crt")] {
+		t.Error("expected API server CA far from expiration to be reported as healthy")
+	}
+
+	if err := Failed(results); err == nil || !strings.HasSuffix(err.Error(), "checks failed") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	report := bytes.Buffer{}
+	if err := PrintReport(&report, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(report.String(), "CrashLoopBackOff") {
+		t.Errorf("report does not include the cilium failure:\n%s", report.String())
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	certutil "k8s.io/client-go/util/cert"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/oidc"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+func (c *checker) checkEtcd() ([]ProbeResult, error) {
+	clusterConfiguration, err := kubeadm.GetClusterConfiguration(c.client)
+	if err != nil {
+		return nil, err
+	}
+	if clusterConfiguration.Etcd.External != nil {
+		return []ProbeResult{{Probe: "etcd", Target: "cluster", Healthy: true, Details: "external etcd, not checked"}}, nil
+	}
+	clusterVersion, err := kubeadm.GetCurrentClusterVersion(c.client)
+	if err != nil {
+		return nil, err
+	}
+	health, err := clusterHealth(c.client, clusterVersion)
+	if err != nil {
+		return nil, err
+	}
+	results := []ProbeResult{}
+	for _, endpoint := range health.Endpoints {
+		result := ProbeResult{Probe: "etcd", Target: endpoint.Endpoint, Healthy: endpoint.Health, Details: "healthy"}
+		if !endpoint.Health {
+			result.Details = fmt.Sprintf("unhealthy: %s", endpoint.Error)
+		}
+		results = append(results, result)
+	}
+	if leader, found := health.Leader(); found {
+		results = append(results, ProbeResult{
+			Probe:   "etcd",
+			Target:  "leader",
+			Healthy: true,
+			Details: fmt.Sprintf("member %x at %s", leader.MemberID, leader.Endpoint),
+		})
+	} else {
+		results = append(results, ProbeResult{Probe: "etcd", Target: "leader", Details: "no member reports to be the leader"})
+	}
+	return results, nil
+}
+
+func (c *checker) checkAPIEndpoints() ([]ProbeResult, error) {
+	apiEndpoints, err := kubeadm.GetAPIEndpointsByNodeFromConfigMap(c.client)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiEndpoints) == 0 {
+		return nil, errors.New("no API endpoints found in the kubeadm-config configmap")
+	}
+	tlsConfig := &tls.Config{}
+	caCertPath := filepath.Join(c.options.PkiDir, "ca.crt")
+	if caCerts, err := certutil.CertsFromFile(caCertPath); err == nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		for _, caCert := range caCerts {
+			tlsConfig.RootCAs.AddCert(caCert)
+		}
+	} else {
+		// without the cluster CA the endpoint can still be probed, but its
+		// identity cannot be verified
+		tlsConfig.InsecureSkipVerify = true
+	}
+	httpClient := &http.Client{
+		Timeout:   c.options.EndpointTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	nodeNames := []string{}
+	for nodeName := range apiEndpoints {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	results := []ProbeResult{}
+	for _, nodeName := range nodeNames {
+		apiEndpoint := apiEndpoints[nodeName]
+		address := net.JoinHostPort(apiEndpoint.AdvertiseAddress, strconv.Itoa(int(apiEndpoint.BindPort)))
+		target := fmt.Sprintf("%s (%s)", address, nodeName)
+		response, err := httpClient.Get(fmt.Sprintf("https://%s/healthz", address))
+		if err != nil {
+			results = append(results, ProbeResult{Probe: "api-endpoint", Target: target, Details: err.Error()})
+			continue
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		result := ProbeResult{Probe: "api-endpoint", Target: target, Healthy: response.StatusCode == http.StatusOK, Details: "answering"}
+		if !result.Healthy {
+			result.Details = fmt.Sprintf("healthz returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+		}
+		results = append(results, result)
+		if response.TLS != nil && len(response.TLS.PeerCertificates) > 0 {
+			results = append(results, c.certificateResult(fmt.Sprintf("apiserver serving certificate on %s", nodeName), response.TLS.PeerCertificates[0]))
+		}
+	}
+	return results, nil
+}
+
+func (c *checker) checkCertificates() ([]ProbeResult, error) {
+	certPaths := []string{}
+	for _, pattern := range []string{"*.crt", filepath.Join("etcd", "*.crt")} {
+		matches, err := filepath.Glob(filepath.Join(c.options.PkiDir, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list certificates in %s", c.options.PkiDir)
+		}
+		certPaths = append(certPaths, matches...)
+	}
+	if len(certPaths) == 0 {
+		return nil, errors.Errorf("no certificates found in %s", c.options.PkiDir)
+	}
+	sort.Strings(certPaths)
+	results := []ProbeResult{}
+	for _, certPath := range certPaths {
+		certs, err := certutil.CertsFromFile(certPath)
+		if err != nil {
+			results = append(results, ProbeResult{Probe: "certificate", Target: certPath, Details: err.Error()})
+			continue
+		}
+		results = append(results, c.certificateResult(certPath, certs[0]))
+	}
+
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(c.client)
+	if err != nil {
+		return results, errors.Wrap(err, "could not retrieve the skuba-config configmap")
+	}
+	secrets := []struct {
+		addon      kubernetes.Addon
+		secretName string
+	}{
+		{addon: kubernetes.Dex, secretName: oidc.DexCertSecretName},
+		{addon: kubernetes.Gangway, secretName: oidc.GangwayCertSecretName},
+	}
+	for _, secret := range secrets {
+		if skubaConfiguration.AddonsVersion[secret.addon] == nil {
+			continue
+		}
+		target := fmt.Sprintf("secret %s/%s", metav1.NamespaceSystem, secret.secretName)
+		certs, err := c.secretCertificates(secret.secretName)
+		if err != nil {
+			results = append(results, ProbeResult{Probe: "certificate", Target: target, Details: err.Error()})
+			continue
+		}
+		results = append(results, c.certificateResult(target, certs[0]))
+	}
+	return results, nil
+}
+
+func (c *checker) secretCertificates(secretName string) ([]*x509.Certificate, error) {
+	secret, err := c.client.CoreV1().Secrets(metav1.NamespaceSystem).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not retrieve secret %s", secretName)
+	}
+	certs, err := certutil.ParseCertsPEM(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse certificate in secret %s", secretName)
+	}
+	return certs, nil
+}
+
+func (c *checker) certificateResult(target string, cert *x509.Certificate) ProbeResult {
+	result := ProbeResult{Probe: "certificate", Target: target}
+	expiration := cert.NotAfter.UTC().Format("2006-01-02")
+	remaining := cert.NotAfter.Sub(c.now)
+	switch {
+	case remaining <= 0:
+		result.Details = fmt.Sprintf("expired on %s", expiration)
+	case remaining < c.options.CertExpirationThreshold:
+		result.Details = fmt.Sprintf("expires on %s, in %d days", expiration, int(remaining.Hours()/24))
+	default:
+		result.Healthy = true
+		result.Details = fmt.Sprintf("expires on %s", expiration)
+	}
+	return result
+}
+
+func (c *checker) checkAddons() ([]ProbeResult, error) {
+	clusterVersion, err := kubeadm.GetCurrentClusterVersion(c.client)
+	if err != nil {
+		return nil, err
+	}
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(c.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve the skuba-config configmap")
+	}
+	expectedAddons := kubernetes.AllAddonVersionsForClusterVersion(clusterVersion)
+	addonNames := []string{}
+	for addon := range expectedAddons {
+		addonNames = append(addonNames, string(addon))
+	}
+	sort.Strings(addonNames)
+	results := []ProbeResult{}
+	for _, addonName := range addonNames {
+		addon := kubernetes.Addon(addonName)
+		if skubaConfiguration.IsAddonDisabled(addon) {
+			continue
+		}
+		expected := expectedAddons[addon]
+		current := skubaConfiguration.AddonsVersion[addon]
+		result := ProbeResult{Probe: "addon", Target: addonName}
+		switch {
+		case current == nil && addon == kubernetes.Cilium:
+			// a different CNI plugin may have been chosen on init
+			result.Healthy = true
+			result.Details = "not deployed"
+		case current == nil:
+			result.Details = fmt.Sprintf("not deployed, expected %s", addonVersionString(expected))
+		case current.Version != expected.Version || current.ManifestVersion < expected.ManifestVersion:
+			result.Details = fmt.Sprintf("%s deployed, expected %s", addonVersionString(current), addonVersionString(expected))
+		default:
+			result.Healthy = true
+			result.Details = addonVersionString(current)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func addonVersionString(addonVersion *kubernetes.AddonVersion) string {
+	if addonVersion.Version == "" {
+		return fmt.Sprintf("manifest %d", addonVersion.ManifestVersion)
+	}
+	return fmt.Sprintf("%s (manifest %d)", addonVersion.Version, addonVersion.ManifestVersion)
+}
+
+func (c *checker) checkCilium() ([]ProbeResult, error) {
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(c.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve the skuba-config configmap")
+	}
+	if skubaConfiguration.AddonsVersion[kubernetes.Cilium] == nil {
+		return []ProbeResult{{Probe: "cilium", Target: "cluster", Healthy: true, Details: "cilium not deployed, not checked"}}, nil
+	}
+	pods, err := c.client.CoreV1().Pods(metav1.NamespaceSystem).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "k8s-app=cilium",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list cilium pods")
+	}
+	if len(pods.Items) == 0 {
+		return nil, errors.New("no cilium pods found")
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Spec.NodeName < pods.Items[j].Spec.NodeName
+	})
+	results := []ProbeResult{}
+	for _, pod := range pods.Items {
+		result := ProbeResult{Probe: "cilium", Target: fmt.Sprintf("%s (%s)", pod.ObjectMeta.Name, pod.Spec.NodeName)}
+		if kubernetes.IsPodReady(&pod) {
+			result.Healthy = true
+			result.Details = "ready"
+		} else {
+			result.Details = podNotReadyReason(&pod)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func podNotReadyReason(pod *corev1.Pod) string {
+	reasons := []string{}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Waiting != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", containerStatus.Name, containerStatus.State.Waiting.Reason))
+		}
+	}
+	if len(reasons) == 0 {
+		return fmt.Sprintf("not ready (phase %s)", pod.Status.Phase)
+	}
+	return fmt.Sprintf("not ready (%s)", strings.Join(reasons, ", "))
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	skubaconstants "github.com/SUSE/skuba/pkg/skuba"
+)
+
+// BackupOptions are the options of an etcd backup
+type BackupOptions struct {
+	// Node is the control plane node taking the snapshot; any ready control
+	// plane node is used when empty
+	Node string
+	// Dir is the local directory where the snapshot is saved
+	Dir string
+	// Name is the file name of the snapshot, without extension; it defaults
+	// to the name of the node and the current time
+	Name string
+}
+
+// Backup takes a snapshot of etcd and saves it locally, along with a file
+// holding its sha256 checksum. It returns the path of the snapshot.
+func Backup(client clientset.Interface, config *rest.Config, options BackupOptions) (string, error) {
+	clusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return "", errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	executorNode, err := etcd.ExecutorNode(client, options.Node)
+	if err != nil {
+		return "", err
+	}
+	if options.Dir == "" {
+		options.Dir = skubaconstants.EtcdSnapshotsDir()
+	}
+	if options.Name == "" {
+		options.Name = fmt.Sprintf("etcd-snapshot-%s-%s", executorNode.ObjectMeta.Name, time.Now().UTC().Format("20060102-150405"))
+	}
+	if err := os.MkdirAll(options.Dir, 0700); err != nil {
+		return "", errors.Wrapf(err, "could not create snapshot directory %s", options.Dir)
+	}
+
+	snapshotPath := filepath.Join(options.Dir, fmt.Sprintf("%s.db", options.Name))
+	partialPath := fmt.Sprintf("%s.partial", snapshotPath)
+	fmt.Printf("[etcd] taking a snapshot of etcd on node %s\n", executorNode.ObjectMeta.Name)
+	f, err := os.OpenFile(partialPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create snapshot file %s", partialPath)
+	}
+	hash := sha256.New()
+	err = etcd.Snapshot(client, config, executorNode, clusterVersion, io.MultiWriter(f, hash))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partialPath)
+		return "", err
+	}
+	if err := os.Rename(partialPath, snapshotPath); err != nil {
+		return "", errors.Wrapf(err, "could not rename snapshot file to %s", snapshotPath)
+	}
+	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	if err := ioutil.WriteFile(checksumPath(snapshotPath), []byte(fmt.Sprintf("%s  %s\n", checksum, filepath.Base(snapshotPath))), 0600); err != nil {
+		return "", errors.Wrapf(err, "could not write checksum of snapshot %s", snapshotPath)
+	}
+	fmt.Printf("[etcd] snapshot saved to %s (sha256 %s)\n", snapshotPath, checksum)
+	return snapshotPath, nil
+}
+
+func checksumPath(snapshotPath string) string {
+	return fmt.Sprintf("%s.sha256", snapshotPath)
+}
+
+// verifySnapshot verifies the snapshot against the checksum saved along with
+// it, returning whether there was a checksum to verify. A snapshot without
+// checksum file is accepted, so snapshots taken by other means can be
+// restored.
+func verifySnapshot(snapshotPath string) (bool, error) {
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not read snapshot %s", snapshotPath)
+	}
+	defer f.Close()
+	checksumContents, err := ioutil.ReadFile(checksumPath(snapshotPath))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "could not read checksum of snapshot %s", snapshotPath)
+	}
+	fields := strings.Fields(string(checksumContents))
+	if len(fields) == 0 {
+		return false, errors.Errorf("empty checksum file %s", checksumPath(snapshotPath))
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false, errors.Wrapf(err, "could not read snapshot %s", snapshotPath)
+	}
+	if checksum := fmt.Sprintf("%x", hash.Sum(nil)); checksum != fields[0] {
+		return false, errors.Errorf("snapshot %s is corrupted: sha256 is %s, expected %s", snapshotPath, checksum, fields[0])
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+const defragHealthRetries = 12
+
+var (
+	// these are replaced in tests, as etcdctl jobs never complete with a
+	// fake clientset
+	clusterHealth = etcd.GetClusterHealth
+	defragment    = etcd.Defragment
+
+	defragHealthInterval = 5 * time.Second
+)
+
+type defragTarget struct {
+	member etcd.Member
+	node   *v1.Node
+}
+
+// Defrag defragments the etcd members one at a time, starting with the
+// followers and finishing with the leader. The cluster has to be healthy
+// before every member is defragmented.
+func Defrag(client clientset.Interface, out io.Writer) error {
+	clusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	members, err := listMembers(client, clusterVersion)
+	if err != nil {
+		return err
+	}
+	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	if err != nil {
+		return errors.Wrap(err, "could not get the list of control plane nodes")
+	}
+
+	followers, leaders := []defragTarget{}, []defragTarget{}
+	for _, member := range members {
+		node := etcd.NodeForMember(member, controlPlaneNodes.Items)
+		if node == nil {
+			fmt.Fprintf(out, "[etcd] skipping member %x (%s), it does not run on any control plane node\n", member.ID, valueOrUnstarted(member.Name))
+			continue
+		}
+		if member.IsLeader {
+			leaders = append(leaders, defragTarget{member: member, node: node})
+		} else {
+			followers = append(followers, defragTarget{member: member, node: node})
+		}
+	}
+
+	for _, target := range append(followers, leaders...) {
+		if err := waitForHealthyCluster(client, clusterVersion, out); err != nil {
+			return errors.Wrapf(err, "not defragmenting member %s", target.member.Name)
+		}
+		fmt.Fprintf(out, "[etcd] defragmenting member %s on node %s (database size %s)\n", target.member.Name, target.node.ObjectMeta.Name, formatDBSize(target.member.DBSize))
+		if err := defragment(client, target.node, clusterVersion); err != nil {
+			return err
+		}
+	}
+	if err := waitForHealthyCluster(client, clusterVersion, out); err != nil {
+		return errors.Wrap(err, "etcd cluster not healthy after defragmentation")
+	}
+
+	members, err = listMembers(client, clusterVersion)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		fmt.Fprintf(out, "[etcd] member %s database size: %s\n", valueOrUnstarted(member.Name), formatDBSize(member.DBSize))
+	}
+	return nil
+}
+
+func waitForHealthyCluster(client clientset.Interface, clusterVersion *version.Version, out io.Writer) error {
+	var reasons []string
+	for i := 0; i < defragHealthRetries; i++ {
+		health, err := clusterHealth(client, clusterVersion)
+		if err != nil {
+			reasons = []string{err.Error()}
+		} else if reasons = health.Unhealthy(); len(reasons) == 0 {
+			return nil
+		}
+		fmt.Fprintf(out, "[etcd] waiting for the etcd cluster to be healthy: %s\n", strings.Join(reasons, "; "))
+		time.Sleep(defragHealthInterval)
+	}
+	return errors.Errorf("etcd cluster is not healthy: %s", strings.Join(reasons, "; "))
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// listMembers is replaced in tests, as etcdctl jobs never complete with a
+// fake clientset
+var listMembers = etcd.ListMembers
+
+// Members prints the members of the etcd cluster along with the control
+// plane node running each of them
+func Members(client clientset.Interface, out io.Writer) error {
+	clusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	members, err := listMembers(client, clusterVersion)
+	if err != nil {
+		return err
+	}
+	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	if err != nil {
+		return errors.Wrap(err, "could not get the list of control plane nodes")
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"ID", "NAME", "NODE", "PEER-URLS", "CLIENT-URLS", "DB-SIZE", "LEADER"}, "\t"))
+	for _, member := range members {
+		nodeName := "<none>"
+		if node := etcd.NodeForMember(member, controlPlaneNodes.Items); node != nil {
+			nodeName = node.ObjectMeta.Name
+		}
+		fmt.Fprintln(w, strings.Join([]string{
+			fmt.Sprintf("%x", member.ID),
+			valueOrUnstarted(member.Name),
+			nodeName,
+			strings.Join(member.PeerURLs, ","),
+			valueOrUnstarted(strings.Join(member.ClientURLs, ",")),
+			formatDBSize(member.DBSize),
+			fmt.Sprintf("%t", member.IsLeader),
+		}, "\t"))
+	}
+	return w.Flush()
+}
+
+func valueOrUnstarted(value string) string {
+	if value == "" {
+		return "<unstarted>"
+	}
+	return value
+}
+
+func formatDBSize(size int64) string {
+	if size < 0 {
+		return "<unknown>"
+	}
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+)
+
+func etcdTestClientset() clientset.Interface {
+	controlPlane := func(name string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"node-role.kubernetes.io/master": ""},
+			},
+		}
+	}
+	return fake.NewSimpleClientset(
+		controlPlane("master-1"),
+		controlPlane("master-2"),
+		controlPlane("master-3"),
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeadm-config", Namespace: metav1.NamespaceSystem},
+			Data: map[string]string{
+				"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.18.10
+`,
+				"ClusterStatus": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterStatus
+apiEndpoints:
+  master-1:
+    advertiseAddress: 10.0.0.1
+    bindPort: 6443
+  master-2:
+    advertiseAddress: 10.0.0.2
+    bindPort: 6443
+  master-5:
+    advertiseAddress: 10.0.0.5
+    bindPort: 6443
+`,
+			},
+		},
+	)
+}
+
+func stubMembers(t *testing.T) func() {
+	originalListMembers, originalClusterHealth, originalDefragment := listMembers, clusterHealth, defragment
+	defragHealthInterval = 0
+	listMembers = func(client clientset.Interface, clusterVersion *version.Version) ([]etcd.Member, error) {
+		return []etcd.Member{
+			{ID: 0x1, Name: "master-1", PeerURLs: []string{"https://10.0.0.1:2380"}, ClientURLs: []string{"https://10.0.0.1:2379"}, DBSize: 3 * 1024 * 1024, IsLeader: true},
+			{ID: 0x2, Name: "master-2", PeerURLs: []string{"https://10.0.0.2:2380"}, ClientURLs: []string{"https://10.0.0.2:2379"}, DBSize: 1536},
+			{ID: 0x3, Name: "master-3", PeerURLs: []string{"https://10.0.0.3:2380"}, ClientURLs: []string{"https://10.0.0.3:2379"}, DBSize: 512},
+			{ID: 0x4, Name: "master-4", PeerURLs: []string{"https://10.0.0.4:2380"}, DBSize: -1},
+		}, nil
+	}
+	return func() {
+		listMembers, clusterHealth, defragment = originalListMembers, originalClusterHealth, originalDefragment
+	}
+}
+
+func TestMembers(t *testing.T) {
+	defer stubMembers(t)()
+
+	out := bytes.Buffer{}
+	if err := Members(etcdTestClientset(), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[2] != "master-1" || fields[5] != "3.0" || fields[7] != "true" {
+		t.Errorf("unexpected leader line: %s", lines[1])
+	}
+	if fields := strings.Fields(lines[4]); fields[2] != "<none>" || fields[4] != "<unstarted>" || fields[5] != "<unknown>" {
+		t.Errorf("unexpected stale member line: %s", lines[4])
+	}
+}
+
+func TestDefrag(t *testing.T) {
+	defer stubMembers(t)()
+
+	t.Run("followers first, leader last", func(t *testing.T) {
+		defragmented := []string{}
+		clusterHealth = func(client clientset.Interface, clusterVersion *version.Version) (*etcd.ClusterHealth, error) {
+			return &etcd.ClusterHealth{
+				Endpoints: []etcd.EndpointHealth{{Endpoint: "https://10.0.0.1:2379", Health: true}},
+				Members:   []etcd.EndpointStatus{{Endpoint: "https://10.0.0.1:2379", IsLeader: true}},
+			}, nil
+		}
+		defragment = func(client clientset.Interface, node *corev1.Node, clusterVersion *version.Version) error {
+			defragmented = append(defragmented, node.ObjectMeta.Name)
+			return nil
+		}
+		out := bytes.Buffer{}
+		if err := Defrag(etcdTestClientset(), &out); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, out.String())
+		}
+		if expected := []string{"master-2", "master-3", "master-1"}; !reflect.DeepEqual(defragmented, expected) {
+			t.Errorf("expected members to be defragmented in order %v, got %v", expected, defragmented)
+		}
+		if !strings.Contains(out.String(), "skipping member 4 (master-4)") {
+			t.Errorf("expected member without node to be skipped:\n%s", out.String())
+		}
+	})
+
+	t.Run("stops when the cluster becomes unhealthy", func(t *testing.T) {
+		defragmented := []string{}
+		clusterHealth = func(client clientset.Interface, clusterVersion *version.Version) (*etcd.ClusterHealth, error) {
+			health := &etcd.ClusterHealth{
+				Endpoints: []etcd.EndpointHealth{{Endpoint: "https://10.0.0.2:2379", Health: true}},
+				Members:   []etcd.EndpointStatus{{Endpoint: "https://10.0.0.1:2379", IsLeader: true}},
+			}
+			if len(defragmented) > 0 {
+				health.Endpoints[0] = etcd.EndpointHealth{Endpoint: "https://10.0.0.2:2379", Error: "context deadline exceeded"}
+			}
+			return health, nil
+		}
+		defragment = func(client clientset.Interface, node *corev1.Node, clusterVersion *version.Version) error {
+			defragmented = append(defragmented, node.ObjectMeta.Name)
+			return nil
+		}
+		err := Defrag(etcdTestClientset(), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "not defragmenting member master-3") {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(defragmented) != 1 {
+			t.Errorf("expected a single member to be defragmented, got %v", defragmented)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+var (
+	// these are replaced in tests, as etcdctl jobs never complete with a
+	// fake clientset
+	removeMember      = etcd.RemoveMemberByID
+	removeAPIEndpoint = kubeadm.RemoveAPIEndpointFromConfigMap
+)
+
+// ReconcileOptions are the options of an etcd membership reconciliation
+type ReconcileOptions struct {
+	// AssumeYes skips the confirmation before stale members are removed
+	AssumeYes bool
+}
+
+// Reconcile compares the etcd members with the control plane nodes and the
+// API endpoints recorded in the kubeadm-config configmap. Members that do
+// not run on any control plane node are stale, usually left behind by a
+// failed node removal, and are removed from the etcd cluster along with
+// their API endpoint once the operator confirms.
+func Reconcile(client clientset.Interface, options ReconcileOptions, in io.Reader, out io.Writer) error {
+	clusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the current cluster version")
+	}
+	members, err := listMembers(client, clusterVersion)
+	if err != nil {
+		return err
+	}
+	controlPlaneNodes, err := kubernetes.GetControlPlaneNodes(client)
+	if err != nil {
+		return errors.Wrap(err, "could not get the list of control plane nodes")
+	}
+	apiEndpoints, err := kubeadm.GetAPIEndpointsByNodeFromConfigMap(client)
+	if err != nil {
+		return err
+	}
+
+	staleMembers := []etcd.Member{}
+	nodesWithMember := map[string]bool{}
+	for _, member := range members {
+		if node := etcd.NodeForMember(member, controlPlaneNodes.Items); node != nil {
+			nodesWithMember[node.ObjectMeta.Name] = true
+			continue
+		}
+		if member.IsLeader {
+			fmt.Fprintf(out, "[etcd] member %x (%s) is the leader but does not run on any control plane node, not removing it\n", member.ID, valueOrUnstarted(member.Name))
+			continue
+		}
+		staleMembers = append(staleMembers, member)
+	}
+	for _, node := range controlPlaneNodes.Items {
+		if !nodesWithMember[node.ObjectMeta.Name] {
+			fmt.Fprintf(out, "[etcd] control plane node %s does not run any etcd member\n", node.ObjectMeta.Name)
+		}
+	}
+	staleAPIEndpoints := []string{}
+	for nodeName := range apiEndpoints {
+		if !isControlPlaneNode(nodeName, controlPlaneNodes.Items) {
+			staleAPIEndpoints = append(staleAPIEndpoints, nodeName)
+		}
+	}
+	sort.Strings(staleAPIEndpoints)
+
+	if len(staleMembers) == 0 && len(staleAPIEndpoints) == 0 {
+		fmt.Fprintln(out, "[etcd] the etcd members match the control plane nodes, nothing to reconcile")
+		return nil
+	}
+	if len(staleMembers) > 0 {
+		fmt.Fprintln(out, "The following etcd members do not run on any control plane node and will be removed from the etcd cluster:")
+		for _, member := range staleMembers {
+			fmt.Fprintf(out, "  - member %x (%s), peer URLs %v\n", member.ID, valueOrUnstarted(member.Name), member.PeerURLs)
+		}
+	}
+	if len(staleAPIEndpoints) > 0 {
+		fmt.Fprintln(out, "The API endpoints of the following nodes, which are not control plane nodes, will be removed from the kubeadm-config configmap:")
+		for _, nodeName := range staleAPIEndpoints {
+			endpoint := apiEndpoints[nodeName]
+			fmt.Fprintf(out, "  - %s (%s:%d)\n", nodeName, endpoint.AdvertiseAddress, endpoint.BindPort)
+		}
+	}
+	if !options.AssumeYes {
+		confirmed, err := confirm(in, out, "Do you want to continue?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return errors.New("etcd reconciliation aborted")
+		}
+	}
+
+	for _, member := range staleMembers {
+		fmt.Fprintf(out, "[etcd] removing member %x (%s)\n", member.ID, valueOrUnstarted(member.Name))
+		if err := removeMember(client, member.ID, clusterVersion); err != nil {
+			return err
+		}
+	}
+	for _, nodeName := range staleAPIEndpoints {
+		fmt.Fprintf(out, "[etcd] removing the API endpoint of %s from the kubeadm-config configmap\n", nodeName)
+		if err := removeAPIEndpoint(client, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}); err != nil {
+			return errors.Wrapf(err, "could not remove the API endpoint of %s", nodeName)
+		}
+	}
+
+	if len(staleMembers) > 0 {
+		members, err = listMembers(client, clusterVersion)
+		if err != nil {
+			return errors.Wrap(err, "could not verify the etcd member removal")
+		}
+		for _, member := range members {
+			for _, staleMember := range staleMembers {
+				if member.ID == staleMember.ID {
+					return errors.Errorf("etcd member %x (%s) is still part of the etcd cluster", member.ID, valueOrUnstarted(member.Name))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(out, "[etcd] etcd membership reconciled")
+	return nil
+}
+
+func isControlPlaneNode(name string, controlPlaneNodes []v1.Node) bool {
+	for _, node := range controlPlaneNodes {
+		if node.ObjectMeta.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+)
+
+func TestReconcile(t *testing.T) {
+	defer stubMembers(t)()
+	defer func(originalRemoveMember func(clientset.Interface, uint64, *version.Version) error, originalRemoveAPIEndpoint func(clientset.Interface, *corev1.Node) error) {
+		removeMember, removeAPIEndpoint = originalRemoveMember, originalRemoveAPIEndpoint
+	}(removeMember, removeAPIEndpoint)
+	stubbedListMembers := listMembers
+
+	tests := []struct {
+		name               string
+		options            ReconcileOptions
+		input              string
+		removalSucceeds    bool
+		expectedMembers    []uint64
+		expectedEndpoints  []string
+		expectedErrMessage string
+	}{
+		{
+			name:              "removes stale members and API endpoints once confirmed",
+			input:             "y\n",
+			removalSucceeds:   true,
+			expectedMembers:   []uint64{0x4},
+			expectedEndpoints: []string{"master-5"},
+		},
+		{
+			name:              "does not ask for confirmation with assume yes",
+			options:           ReconcileOptions{AssumeYes: true},
+			removalSucceeds:   true,
+			expectedMembers:   []uint64{0x4},
+			expectedEndpoints: []string{"master-5"},
+		},
+		{
+			name:               "aborts when not confirmed",
+			input:              "n\n",
+			expectedErrMessage: "etcd reconciliation aborted",
+		},
+		{
+			name:               "fails when the stale member is still present",
+			options:            ReconcileOptions{AssumeYes: true},
+			expectedMembers:    []uint64{0x4},
+			expectedEndpoints:  []string{"master-5"},
+			expectedErrMessage: "etcd member 4 (master-4) is still part of the etcd cluster",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			removedMembers := []uint64{}
+			removedEndpoints := []string{}
+			listMembers = func(client clientset.Interface, clusterVersion *version.Version) ([]etcd.Member, error) {
+				members, err := stubbedListMembers(client, clusterVersion)
+				if !tt.removalSucceeds {
+					return members, err
+				}
+				remaining := []etcd.Member{}
+				for _, member := range members {
+					if len(removedMembers) == 0 || member.ID != removedMembers[0] {
+						remaining = append(remaining, member)
+					}
+				}
+				return remaining, err
+			}
+			removeMember = func(client clientset.Interface, id uint64, clusterVersion *version.Version) error {
+				removedMembers = append(removedMembers, id)
+				return nil
+			}
+			removeAPIEndpoint = func(client clientset.Interface, node *corev1.Node) error {
+				removedEndpoints = append(removedEndpoints, node.ObjectMeta.Name)
+				return nil
+			}
+
+			out := bytes.Buffer{}
+			err := Reconcile(etcdTestClientset(), tt.options, strings.NewReader(tt.input), &out)
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("expected error %q, got %v", tt.expectedErrMessage, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v\n%s", err, out.String())
+			}
+			if len(removedMembers)+len(tt.expectedMembers) > 0 && !reflect.DeepEqual(removedMembers, tt.expectedMembers) {
+				t.Errorf("expected removed members %v, got %v", tt.expectedMembers, removedMembers)
+			}
+			if len(removedEndpoints)+len(tt.expectedEndpoints) > 0 && !reflect.DeepEqual(removedEndpoints, tt.expectedEndpoints) {
+				t.Errorf("expected removed API endpoints %v, got %v", tt.expectedEndpoints, removedEndpoints)
+			}
+			if tt.expectedErrMessage == "" && !strings.Contains(out.String(), "etcd membership reconciled") {
+				t.Errorf("expected reconciliation to finish:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+)
+
+var (
+	apiServerInterval = 5 * time.Second
+	apiServerTimeout  = 5 * time.Minute
+)
+
+// RestoreOptions are the options of an etcd restore
+type RestoreOptions struct {
+	// SnapshotPath is the local path of the snapshot to restore
+	SnapshotPath string
+	// AssumeYes skips the confirmation before the control plane is stopped
+	AssumeYes bool
+	// Command is recorded as the holder of the cluster lock
+	Command string
+}
+
+type restoreMember struct {
+	target        *deployments.Target
+	configuration deployments.EtcdRestoreConfiguration
+}
+
+// Restore restores the snapshot on every control plane node of the cluster.
+// The etcd and apiserver static pods are stopped on all targets, the snapshot
+// is restored in a new etcd data directory on each of them, and the static
+// pods are started again. Every step is explained before it runs, and the
+// operator has to confirm before the control plane is stopped. Once the API
+// is available again, a cluster lock restored from the snapshot is removed.
+//
+// The cluster lock is held during the restore when the API server answers.
+// When it does not, as etcd is down, the snapshot is restored without it.
+func Restore(client clientset.Interface, targets []*deployments.Target, options RestoreOptions, in io.Reader, out io.Writer) error {
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		fmt.Fprintf(out, "[etcd] the API server is not reachable (%v), restoring without holding the cluster lock\n", err)
+		return restore(client, targets, options, in, out)
+	}
+	return lock.Run(client, options.Command, func() error {
+		return restore(client, targets, options, in, out)
+	})
+}
+
+func restore(client clientset.Interface, targets []*deployments.Target, options RestoreOptions, in io.Reader, out io.Writer) error {
+	if len(targets) == 0 {
+		return errors.New("no control plane nodes to restore the snapshot on")
+	}
+
+	fmt.Fprintf(out, "[etcd] step 1/5: verifying snapshot %s\n", options.SnapshotPath)
+	verified, err := verifySnapshot(options.SnapshotPath)
+	if err != nil {
+		return err
+	}
+	if verified {
+		fmt.Fprintln(out, "[etcd] snapshot checksum verified")
+	} else {
+		fmt.Fprintf(out, "[etcd] no checksum file %s found, the snapshot integrity could not be verified\n", checksumPath(options.SnapshotPath))
+	}
+
+	fmt.Fprintln(out, "[etcd] step 2/5: reading the etcd configuration of the control plane nodes")
+	now := time.Now().UTC()
+	members, err := restoreMembers(targets, options.SnapshotPath, now)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		fmt.Fprintf(out, "  - %s: member %s, peer URL %s, data directory %s\n", member.target.Target, member.configuration.MemberName, member.configuration.PeerURL, member.configuration.DataDir)
+	}
+	fmt.Fprintln(out, "The etcd and kube-apiserver static pods will be stopped on all the nodes above, and the cluster API")
+	fmt.Fprintln(out, "will be unavailable until the restore finishes. The current etcd data directories are kept with")
+	fmt.Fprintf(out, "the %q suffix.\n", members[0].configuration.BackupSuffix)
+	if !options.AssumeYes {
+		confirmed, err := confirm(in, out, "Restore the snapshot on these nodes?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return errors.New("restore aborted")
+		}
+	}
+
+	fmt.Fprintln(out, "[etcd] step 3/5: stopping etcd and kube-apiserver on the control plane nodes")
+	for _, member := range members {
+		if err := member.target.Apply(member.configuration, "etcd.restore.stop-control-plane"); err != nil {
+			return restoreError(err, member)
+		}
+	}
+	fmt.Fprintln(out, "[etcd] step 4/5: restoring the snapshot on the control plane nodes")
+	for _, member := range members {
+		fmt.Fprintf(out, "[etcd] restoring member %s on %s\n", member.configuration.MemberName, member.target.Target)
+		if err := member.target.Apply(member.configuration, "etcd.restore.snapshot"); err != nil {
+			return restoreError(err, member)
+		}
+	}
+	fmt.Fprintln(out, "[etcd] step 5/5: starting etcd and kube-apiserver on the control plane nodes")
+	for _, member := range members {
+		if err := member.target.Apply(member.configuration, "etcd.restore.start-control-plane"); err != nil {
+			return restoreError(err, member)
+		}
+	}
+	fmt.Fprintln(out, "[etcd] waiting for the API to be available again")
+	err = wait.PollImmediate(apiServerInterval, apiServerTimeout, func() (bool, error) {
+		_, err := client.Discovery().ServerVersion()
+		return err == nil, nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "snapshot restored, but the API did not become available again")
+	}
+	// the snapshot predates the lock held by this restore, but it may hold
+	// the lock of a command that was running when it was taken
+	holder, err := lock.ForceUnlock(client)
+	if err != nil {
+		return err
+	}
+	if holder != nil {
+		fmt.Fprintf(out, "[etcd] removed the cluster lock restored from the snapshot, held by %s\n", holder)
+	}
+	fmt.Fprintln(out, "[etcd] snapshot restored; verify the cluster with `skuba cluster check`")
+	return nil
+}
+
+func restoreError(err error, member restoreMember) error {
+	return errors.Wrapf(err, "restore failed on %s; the stopped static pod manifests are kept in %s on the nodes, fix the issue and run the restore again", member.target.Target, deployments.EtcdRestoreStashDir)
+}
+
+func restoreMembers(targets []*deployments.Target, snapshotPath string, now time.Time) ([]restoreMember, error) {
+	members := []restoreMember{}
+	initialCluster := []string{}
+	memberNames := map[string]bool{}
+	for _, target := range targets {
+		manifest, err := target.DownloadFileContents(deployments.EtcdStaticPodManifest)
+		if err != nil {
+			// a previous restore attempt may have stopped etcd already
+			manifest, err = target.DownloadFileContents(filepath.Join(deployments.EtcdRestoreStashDir, filepath.Base(deployments.EtcdStaticPodManifest)))
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not read the etcd static pod manifest on %s", target.Target)
+			}
+		}
+		configuration, err := restoreConfigurationFromManifest(manifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid etcd static pod manifest on %s", target.Target)
+		}
+		if memberNames[configuration.MemberName] {
+			return nil, errors.Errorf("etcd member %s is defined on more than one node", configuration.MemberName)
+		}
+		memberNames[configuration.MemberName] = true
+		configuration.SnapshotPath = snapshotPath
+		configuration.InitialClusterToken = fmt.Sprintf("skuba-restore-%d", now.Unix())
+		configuration.BackupSuffix = fmt.Sprintf("before-restore-%s", now.Format("20060102-150405"))
+		members = append(members, restoreMember{target: target, configuration: configuration})
+		initialCluster = append(initialCluster, fmt.Sprintf("%s=%s", configuration.MemberName, configuration.PeerURL))
+	}
+	for i := range members {
+		members[i].configuration.InitialCluster = strings.Join(initialCluster, ",")
+	}
+	return members, nil
+}
+
+// restoreConfigurationFromManifest reads the member name, peer URL, data
+// directory and image of the etcd member from its static pod manifest
+func restoreConfigurationFromManifest(manifest string) (deployments.EtcdRestoreConfiguration, error) {
+	configuration := deployments.EtcdRestoreConfiguration{}
+	pod := corev1.Pod{}
+	if err := yaml.Unmarshal([]byte(manifest), &pod); err != nil {
+		return configuration, errors.Wrap(err, "could not parse etcd static pod manifest")
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "etcd" {
+			continue
+		}
+		configuration.Image = container.Image
+		for _, arg := range append(container.Command, container.Args...) {
+			switch {
+			case strings.HasPrefix(arg, "--name="):
+				configuration.MemberName = strings.TrimPrefix(arg, "--name=")
+			case strings.HasPrefix(arg, "--initial-advertise-peer-urls="):
+				configuration.PeerURL = strings.TrimPrefix(arg, "--initial-advertise-peer-urls=")
+			case strings.HasPrefix(arg, "--data-dir="):
+				configuration.DataDir = strings.TrimPrefix(arg, "--data-dir=")
+			}
+		}
+	}
+	missing := []string{}
+	if configuration.Image == "" {
+		missing = append(missing, "etcd container")
+	}
+	if configuration.MemberName == "" {
+		missing = append(missing, "--name")
+	}
+	if configuration.PeerURL == "" {
+		missing = append(missing, "--initial-advertise-peer-urls")
+	}
+	if configuration.DataDir == "" {
+		missing = append(missing, "--data-dir")
+	}
+	if len(missing) > 0 {
+		return configuration, errors.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return configuration, nil
+}
+
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Wrap(err, "read user input")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	ktest "k8s.io/client-go/testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+)
+
+const etcdManifestTemplate = `apiVersion: v1
+kind: Pod
+metadata:
+  name: etcd
+  namespace: kube-system
+spec:
+  containers:
+  - command:
+    - etcd
+    - --advertise-client-urls=https://%[2]s:2379
+    - --data-dir=/var/lib/etcd
+    - --initial-advertise-peer-urls=https://%[2]s:2380
+    - --name=%[1]s
+    image: registry.suse.com/caasp/v4.5/etcd:3.4.3
+    name: etcd
+`
+
+type fakeNode struct {
+	files   map[string]string
+	applied []string
+	failOn  string
+	// onApply is called with every state applied
+	onApply func(state string)
+}
+
+func (f *fakeNode) Apply(data interface{}, states ...string) error {
+	for _, state := range states {
+		if state == f.failOn {
+			return errors.New("state failed")
+		}
+		if f.onApply != nil {
+			f.onApply(state)
+		}
+		configuration := data.(deployments.EtcdRestoreConfiguration)
+		f.applied = append(f.applied, fmt.Sprintf("%s %s", state, configuration.InitialCluster))
+	}
+	return nil
+}
+
+func (f *fakeNode) UploadFileContents(targetPath, contents string, perm os.FileMode) error {
+	return nil
+}
+
+func (f *fakeNode) DownloadFileContents(sourcePath string) (string, error) {
+	if contents, found := f.files[sourcePath]; found {
+		return contents, nil
+	}
+	return "", errors.Errorf("%s not found", sourcePath)
+}
+
+func (f *fakeNode) IsServiceEnabled(serviceName string) (bool, error) {
+	return true, nil
+}
+
+func writeSnapshot(t *testing.T, dir string, contents string, checksum bool) string {
+	snapshotPath := filepath.Join(dir, "etcd-snapshot.db")
+	if err := ioutil.WriteFile(snapshotPath, []byte(contents), 0600); err != nil {
+		t.Fatalf("could not write snapshot: %v", err)
+	}
+	if checksum {
+		if err := ioutil.WriteFile(checksumPath(snapshotPath), []byte(fmt.Sprintf("%x  etcd-snapshot.db\n", sha256.Sum256([]byte("snapshot")))), 0600); err != nil {
+			t.Fatalf("could not write checksum: %v", err)
+		}
+	}
+	return snapshotPath
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-etcd-snapshot")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	verified, err := verifySnapshot(writeSnapshot(t, tmpDir, "snapshot", true))
+	if err != nil || !verified {
+		t.Errorf("expected snapshot to be verified, got %v, %v", verified, err)
+	}
+	if _, err := verifySnapshot(writeSnapshot(t, tmpDir, "corrupted", true)); err == nil || !strings.Contains(err.Error(), "is corrupted") {
+		t.Errorf("expected corrupted snapshot error, got %v", err)
+	}
+	os.Remove(checksumPath(filepath.Join(tmpDir, "etcd-snapshot.db")))
+	if verified, err := verifySnapshot(writeSnapshot(t, tmpDir, "snapshot", false)); err != nil || verified {
+		t.Errorf("expected snapshot without checksum to be accepted unverified, got %v, %v", verified, err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-etcd-snapshot")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	snapshotPath := writeSnapshot(t, tmpDir, "snapshot", true)
+
+	newTargets := func() ([]*fakeNode, []*deployments.Target) {
+		nodes := []*fakeNode{
+			{files: map[string]string{deployments.EtcdStaticPodManifest: fmt.Sprintf(etcdManifestTemplate, "master-1", "10.0.0.1")}},
+			// etcd already stopped by a previous attempt
+			{files: map[string]string{filepath.Join(deployments.EtcdRestoreStashDir, "etcd.yaml"): fmt.Sprintf(etcdManifestTemplate, "master-2", "10.0.0.2")}},
+		}
+		targets := []*deployments.Target{
+			{Actionable: nodes[0], Target: "10.0.0.1"},
+			{Actionable: nodes[1], Target: "10.0.0.2"},
+		}
+		return nodes, targets
+	}
+
+	t.Run("confirmed", func(t *testing.T) {
+		nodes, targets := newTargets()
+		client := fake.NewSimpleClientset()
+		holder := "admin@workstation (pid 42)"
+		// the snapshot replaces the lock held by the restore with the lock
+		// held when it was taken
+		nodes[0].onApply = func(state string) {
+			if state != "etcd.restore.snapshot" {
+				return
+			}
+			current, err := lock.CurrentHolder(client)
+			if err != nil || current == nil || current.Command != "skuba cluster etcd restore" {
+				t.Errorf("expected the cluster lock to be held by the restore, got %v, %v", current, err)
+			}
+			if _, err := lock.ForceUnlock(client); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := client.CoordinationV1().Leases(metav1.NamespaceSystem).Create(context.TODO(), &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: lock.LeaseName, Namespace: metav1.NamespaceSystem},
+				Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder},
+			}, metav1.CreateOptions{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		out := bytes.Buffer{}
+		if err := Restore(client, targets, RestoreOptions{SnapshotPath: snapshotPath, Command: "skuba cluster etcd restore"}, strings.NewReader("y\n"), &out); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, out.String())
+		}
+		if current, err := lock.CurrentHolder(client); err != nil || current != nil {
+			t.Errorf("expected no cluster lock after the restore, got %v, %v", current, err)
+		}
+		if !strings.Contains(out.String(), "removed the cluster lock restored from the snapshot, held by "+holder) {
+			t.Errorf("expected the restored cluster lock to be removed, got:\n%s", out.String())
+		}
+		initialCluster := "master-1=https://10.0.0.1:2380,master-2=https://10.0.0.2:2380"
+		expected := []string{
+			"etcd.restore.stop-control-plane " + initialCluster,
+			"etcd.restore.snapshot " + initialCluster,
+			"etcd.restore.start-control-plane " + initialCluster,
+		}
+		for _, node := range nodes {
+			if !reflect.DeepEqual(node.applied, expected) {
+				t.Errorf("unexpected states applied:\n got: %v\nwant: %v", node.applied, expected)
+			}
+		}
+	})
+
+	t.Run("API server unreachable", func(t *testing.T) {
+		nodes, targets := newTargets()
+		client := newUnreachableClientset()
+		// etcd is down, the API server answers again once the control plane
+		// is started with the restored snapshot
+		nodes[1].onApply = func(state string) {
+			if state == "etcd.restore.start-control-plane" {
+				client.reachable = true
+			}
+		}
+		out := bytes.Buffer{}
+		if err := Restore(client, targets, RestoreOptions{SnapshotPath: snapshotPath, AssumeYes: true}, strings.NewReader(""), &out); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, out.String())
+		}
+		if !strings.Contains(out.String(), "restoring without holding the cluster lock") {
+			t.Errorf("expected the restore to run without the cluster lock, got:\n%s", out.String())
+		}
+		for _, node := range nodes {
+			if len(node.applied) != 3 {
+				t.Errorf("expected the snapshot to be restored on every node, got %v", node.applied)
+			}
+		}
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		nodes, targets := newTargets()
+		err := Restore(fake.NewSimpleClientset(), targets, RestoreOptions{SnapshotPath: snapshotPath}, strings.NewReader("n\n"), &bytes.Buffer{})
+		if err == nil || err.Error() != "restore aborted" {
+			t.Errorf("expected restore to be aborted, got %v", err)
+		}
+		if len(nodes[0].applied) > 0 {
+			t.Errorf("expected no states to be applied, got %v", nodes[0].applied)
+		}
+	})
+
+	t.Run("failed restore keeps the control plane stopped", func(t *testing.T) {
+		nodes, targets := newTargets()
+		nodes[1].failOn = "etcd.restore.snapshot"
+		err := Restore(fake.NewSimpleClientset(), targets, RestoreOptions{SnapshotPath: snapshotPath, AssumeYes: true}, strings.NewReader(""), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "run the restore again") {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(nodes[0].applied) != 2 {
+			t.Errorf("expected first node to be stopped and restored, got %v", nodes[0].applied)
+		}
+	})
+}
+
+// unreachableClientset fails to reach the API server until reachable is set
+type unreachableClientset struct {
+	*fake.Clientset
+	reachable bool
+}
+
+func newUnreachableClientset() *unreachableClientset {
+	c := &unreachableClientset{Clientset: fake.NewSimpleClientset()}
+	c.PrependReactor("*", "*", func(action ktest.Action) (bool, runtime.Object, error) {
+		if !c.reachable {
+			return true, nil, errors.New("connection refused")
+		}
+		return false, nil, nil
+	})
+	return c
+}
+
+func (c *unreachableClientset) Discovery() discovery.DiscoveryInterface {
+	return &unreachableDiscovery{FakeDiscovery: c.Clientset.Discovery().(*fakediscovery.FakeDiscovery), clientset: c}
+}
+
+type unreachableDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	clientset *unreachableClientset
+}
+
+func (d *unreachableDiscovery) ServerVersion() (*version.Info, error) {
+	if !d.clientset.reachable {
+		return nil, errors.New("connection refused")
+	}
+	return d.FakeDiscovery.ServerVersion()
+}
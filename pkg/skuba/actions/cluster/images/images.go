@@ -18,37 +18,232 @@
 package cluster
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 )
 
-// Print out a list of images that will use
-// This can be used as input to skopeo for mirroring in air-gapped scenarios
-// Ensure images only appear once
-func Images() error {
-	fmt.Printf("VERSION    IMAGE\n")
+const (
+	// OutputTable prints the images of every version, the default output
+	OutputTable = "table"
+	// OutputJSON prints the images as JSON
+	OutputJSON = "json"
+	// OutputYAML prints the images as YAML
+	OutputYAML = "yaml"
+)
+
+// OutputFormats are the formats accepted by Images
+var OutputFormats = []string{OutputTable, OutputJSON, OutputYAML}
+
+// ImagesOptions are the options of `skuba cluster images`
+type ImagesOptions struct {
+	// OutputFormat is the format of the image list
+	OutputFormat string
+	// Version is the Kubernetes version whose images are listed; the images
+	// of all the available versions are listed when it is empty
+	Version string
+	// Addon only lists the images of the addon
+	Addon string
+	// ResolveDigests resolves the digest of every image in its registry
+	ResolveDigests bool
+	// CredentialsFile is a Docker config.json file with the credentials of
+	// the registries the digests are resolved in
+	CredentialsFile string
+	// ImageRepository lists the images of the image repository a cluster
+	// was initialized with, see `skuba cluster init --image-repository`
+	ImageRepository string
+}
+
+// ClusterImage is an image used by a Kubernetes component or by an addon
+type ClusterImage struct {
+	Version   string `json:"version"`
+	Component string `json:"component,omitempty"`
+	Addon     string `json:"addon,omitempty"`
+	Image     string `json:"image"`
+	Digest    string `json:"digest,omitempty"`
+}
+
+// usedBy returns the component or the addon using the image
+func (image ClusterImage) usedBy() string {
+	if image.Component != "" {
+		return image.Component
+	}
+	return image.Addon
+}
 
-	for _, version := range kubernetes.AvailableVersions() {
-		imagesEncountered := map[string]bool{}
-		for _, component := range kubernetes.AllComponentContainerImagesForClusterVersion(version) {
-			imagesEncountered[kubernetes.ComponentContainerImageForClusterVersion(component, version)] = true
+// Images implements the `skuba cluster images` command. It prints the images
+// of the Kubernetes components and of the addons, sorted by version and by
+// component or addon. The table output lists every image once per version,
+// and can be used as input to skopeo for mirroring in air-gapped scenarios.
+func Images(out io.Writer, options ImagesOptions) error {
+	versions, err := clusterVersions(options.Version)
+	if err != nil {
+		return err
+	}
+	images, err := clusterImages(versions, options.Addon, options.ImageRepository)
+	if err != nil {
+		return err
+	}
+	if options.ResolveDigests {
+		remoteOptions, err := registryOptions(options.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		if err := resolveDigests(images, remoteOptions); err != nil {
+			return err
+		}
+	}
+
+	switch options.OutputFormat {
+	case OutputTable, "":
+		return printImagesTable(out, images)
+	case OutputJSON:
+		contents, err := json.MarshalIndent(images, "", "    ")
+		if err != nil {
+			return errors.Wrap(err, "could not marshal cluster images")
+		}
+		_, err = fmt.Fprintln(out, string(contents))
+		return err
+	case OutputYAML:
+		contents, err := yaml.Marshal(images)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal cluster images")
+		}
+		_, err = out.Write(contents)
+		return err
+	}
+	return errors.Errorf("invalid output format %q, must be one of: %s", options.OutputFormat, strings.Join(OutputFormats, ", "))
+}
+
+// printImagesTable prints every image once per version
+func printImagesTable(out io.Writer, images []ClusterImage) error {
+	if _, err := fmt.Fprintf(out, "VERSION    IMAGE\n"); err != nil {
+		return err
+	}
+	imagesEncountered := map[string]bool{}
+	for _, image := range images {
+		imagelocation := image.Image
+		if image.Digest != "" {
+			imagelocation = fmt.Sprintf("%s@%s", image.Image, image.Digest)
+		}
+		if imagesEncountered[image.Version+" "+imagelocation] {
+			continue
 		}
+		imagesEncountered[image.Version+" "+imagelocation] = true
+		if _, err := fmt.Fprintf(out, "%-10v %v\n", image.Version, imagelocation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clusterVersions returns the Kubernetes versions matching the version
+// string, or all the available versions when it is empty
+func clusterVersions(clusterVersion string) ([]*version.Version, error) {
+	if clusterVersion == "" {
+		return kubernetes.AvailableVersions(), nil
+	}
+	parsedVersion, err := version.ParseSemantic(clusterVersion)
+	if err != nil || !kubernetes.IsVersionAvailable(parsedVersion) {
+		return nil, errors.Errorf("version %s does not exist or cannot be parsed", clusterVersion)
+	}
+	return []*version.Version{parsedVersion}, nil
+}
 
-		for addonName, addon := range addons.Addons {
-			addonVersion := kubernetes.AddonVersionForClusterVersion(addonName, version)
+// clusterImages returns the images of the Kubernetes components and of the
+// addons of the versions, sorted by version and by component or addon. When
+// addonName is not empty, only the images of that addon are returned. When
+// imageRepository is not empty, the images are located in it.
+func clusterImages(versions []*version.Version, addonName, imageRepository string) ([]ClusterImage, error) {
+	if addonName != "" {
+		if _, err := addons.GetAddon(addonName); err != nil {
+			return nil, err
+		}
+	}
+	images := []ClusterImage{}
+	for _, clusterVersion := range versions {
+		versionImages := []ClusterImage{}
+		if addonName == "" {
+			for _, component := range kubernetes.AllComponentContainerImagesForClusterVersion(clusterVersion) {
+				versionImages = append(versionImages, ClusterImage{
+					Version:   clusterVersion.String(),
+					Component: string(component),
+					Image:     kubernetes.ComponentContainerImageForClusterVersion(component, clusterVersion, imageRepository),
+				})
+			}
+		}
+		for name, addon := range addons.Addons {
+			if addonName != "" && string(name) != addonName {
+				continue
+			}
+			addonVersion := kubernetes.AddonVersionForClusterVersion(name, clusterVersion)
 			if addonVersion == nil {
 				continue
 			}
-			for _, addonImageLoc := range addon.Images(version, addonVersion.Version) {
-				imagesEncountered[addonImageLoc] = true
+			for _, addonImageLoc := range addon.Images(clusterVersion, imageRepository, addonVersion.Version) {
+				versionImages = append(versionImages, ClusterImage{
+					Version: clusterVersion.String(),
+					Addon:   string(name),
+					Image:   addonImageLoc,
+				})
 			}
 		}
+		sort.SliceStable(versionImages, func(i, j int) bool {
+			if versionImages[i].usedBy() != versionImages[j].usedBy() {
+				return versionImages[i].usedBy() < versionImages[j].usedBy()
+			}
+			return versionImages[i].Image < versionImages[j].Image
+		})
+		images = append(images, versionImages...)
+	}
+	return images, nil
+}
 
-		for imagelocation := range imagesEncountered {
-			fmt.Printf("%-10v %v\n", version, imagelocation)
+// imagesForClusterVersion returns the sorted images of the kubeadm components
+// and of the addons of the Kubernetes version
+func imagesForClusterVersion(clusterVersion *version.Version) []string {
+	clusterImages, _ := clusterImages([]*version.Version{clusterVersion}, "", "")
+	imagesEncountered := map[string]bool{}
+	images := []string{}
+	for _, image := range clusterImages {
+		if !imagesEncountered[image.Image] {
+			imagesEncountered[image.Image] = true
+			images = append(images, image.Image)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// resolveDigests sets the digest of the manifest every image tag points to
+func resolveDigests(images []ClusterImage, remoteOptions []remote.Option) error {
+	digests := map[string]string{}
+	for i := range images {
+		digest, found := digests[images[i].Image]
+		if !found {
+			reference, err := name.ParseReference(images[i].Image)
+			if err != nil {
+				return errors.Wrapf(err, "invalid image %s", images[i].Image)
+			}
+			descriptor, err := remote.Head(reference, remoteOptions...)
+			if err != nil {
+				return errors.Wrapf(err, "unable to resolve the digest of %s", images[i].Image)
+			}
+			digest = descriptor.Digest.String()
+			digests[images[i].Image] = digest
 		}
+		images[i].Digest = digest
 	}
 	return nil
 }
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+func TestImages(t *testing.T) {
+	clusterVersion := version.MustParseSemantic("1.18.10")
+	dexVersion := kubernetes.AddonVersionForClusterVersion(kubernetes.Dex, clusterVersion).Version
+
+	tests := []struct {
+		name         string
+		options      ImagesOptions
+		expectOutput string
+		expectErrMsg string
+	}{
+		{
+			name:         "json output of an addon",
+			options:      ImagesOptions{OutputFormat: OutputJSON, Version: "1.18.10", Addon: "dex"},
+			expectOutput: `"addon": "dex"`,
+		},
+		{
+			name:         "table output of an addon",
+			options:      ImagesOptions{Version: "1.18.10", Addon: "dex"},
+			expectOutput: "VERSION    IMAGE\n1.18.10    ",
+		},
+		{
+			name:         "custom image repository",
+			options:      ImagesOptions{Version: "1.18.10", Addon: "dex", ImageRepository: "mirror.example.com/caasp/v4.5/"},
+			expectOutput: "1.18.10    mirror.example.com/caasp/v4.5/caasp-dex:",
+		},
+		{
+			name:         "unknown addon",
+			options:      ImagesOptions{Addon: "dashboard"},
+			expectErrMsg: `unknown addon "dashboard"`,
+		},
+		{
+			name:         "unknown version",
+			options:      ImagesOptions{Version: "1.10.0"},
+			expectErrMsg: "version 1.10.0 does not exist or cannot be parsed",
+		},
+		{
+			name:         "unknown output format",
+			options:      ImagesOptions{OutputFormat: "wide"},
+			expectErrMsg: `invalid output format "wide", must be one of: table, json, yaml`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Images(&out, tt.options)
+			if tt.expectErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErrMsg) {
+					t.Errorf("expected error %q, got %v", tt.expectErrMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if !strings.Contains(out.String(), tt.expectOutput) || !strings.Contains(out.String(), "caasp-dex:"+dexVersion) {
+				t.Errorf("output expected to contain %q and the dex image, got %q", tt.expectOutput, out.String())
+			}
+		})
+	}
+}
+
+func TestClusterImages(t *testing.T) {
+	versions := []*version.Version{version.MustParseSemantic("1.18.10"), version.MustParseSemantic("1.17.13")}
+	images, err := clusterImages(versions, "", "")
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	var out bytes.Buffer
+	if err := Images(&out, ImagesOptions{OutputFormat: OutputJSON, Version: "1.18.10"}); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	printed := []ClusterImage{}
+	if err := json.Unmarshal(out.Bytes(), &printed); err != nil {
+		t.Fatalf("json output expected, got %v", err)
+	}
+	for i, image := range printed {
+		if image.Version != "1.18.10" {
+			t.Errorf("only images of version 1.18.10 expected, got %v", image)
+		}
+		if (image.Component == "") == (image.Addon == "") {
+			t.Errorf("image expected to be used by either a component or an addon, got %v", image)
+		}
+		if i > 0 && printed[i-1].usedBy() > image.usedBy() {
+			t.Errorf("images expected to be sorted by component or addon, got %v before %v", printed[i-1], image)
+		}
+	}
+	for i := range images {
+		if i > 0 && images[i-1].Version == "1.18.10" && images[i].Version == "1.17.13" {
+			// the versions are kept in the requested order
+			return
+		}
+	}
+	t.Error("images expected to be grouped by version")
+}
+
+func TestResolveDigests(t *testing.T) {
+	source, digests := mirrorTestRegistry(t)
+	defer source.Close()
+
+	images := []ClusterImage{}
+	for image := range digests {
+		images = append(images, ClusterImage{Version: "1.18.10", Addon: "kured", Image: image})
+		images = append(images, ClusterImage{Version: "1.18.6", Addon: "kured", Image: image})
+	}
+	if err := resolveDigests(images, nil); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	for _, image := range images {
+		if image.Digest != digests[image.Image].String() {
+			t.Errorf("%s expected to resolve to digest %s, got %s", image.Image, digests[image.Image], image.Digest)
+		}
+	}
+
+	empty := httptest.NewServer(registry.New())
+	defer empty.Close()
+	missing := []ClusterImage{{Image: strings.TrimPrefix(empty.URL, "http://") + "/caasp/v4.5/pause:3.2"}}
+	if err := resolveDigests(missing, nil); err == nil || !strings.Contains(err.Error(), "unable to resolve the digest of") {
+		t.Errorf("missing image expected to fail, got %v", err)
+	}
+}
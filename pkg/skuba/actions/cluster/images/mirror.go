@@ -0,0 +1,441 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+)
+
+// refNameAnnotation is the annotation of the OCI image layout index holding
+// the original reference of every image
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// MirrorOptions are the options of `skuba cluster images mirror`
+type MirrorOptions struct {
+	// Registry is the registry the images are copied to, optionally followed
+	// by a repository prefix
+	Registry string
+	// Version is the Kubernetes version whose images are copied; the images
+	// of all the available versions are copied when it is empty
+	Version string
+	// CredentialsFile is a Docker config.json file with the credentials of
+	// the registries; the Docker configuration of the user is used when it
+	// is empty
+	CredentialsFile string
+	// OCILayout is the tarball the images are written to as an OCI image
+	// layout, instead of copying them to Registry
+	OCILayout string
+	// FromOCILayout is a tarball written with OCILayout, whose images are
+	// copied to Registry instead of the images of Version
+	FromOCILayout string
+}
+
+// Mirror implements the `skuba cluster images mirror` command. It copies the
+// images of the kubeadm components and of the addons to a registry, or to an
+// OCI image layout tarball that can be copied to a registry later on. The
+// images keep their digests.
+func Mirror(options MirrorOptions) error {
+	if (options.Registry == "") == (options.OCILayout == "") {
+		return errors.New("either a registry or an OCI layout tarball has to be provided")
+	}
+	if options.FromOCILayout != "" && (options.Registry == "" || options.Version != "") {
+		return errors.New("the images of an OCI layout tarball can only be copied to a registry")
+	}
+	remoteOptions, err := registryOptions(options.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	if options.FromOCILayout != "" {
+		return mirrorOCILayout(options.FromOCILayout, options.Registry, remoteOptions)
+	}
+
+	versions, err := clusterVersions(options.Version)
+	if err != nil {
+		return err
+	}
+	imagesEncountered := map[string]bool{}
+	images := []string{}
+	for _, version := range versions {
+		for _, image := range imagesForClusterVersion(version) {
+			if !imagesEncountered[image] {
+				imagesEncountered[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	if options.OCILayout != "" {
+		return writeOCILayout(images, options.OCILayout, remoteOptions)
+	}
+	return mirrorImages(images, options.Registry, remoteOptions)
+}
+
+// mirrorImages copies the images to the registry
+func mirrorImages(images []string, registry string, remoteOptions []remote.Option) error {
+	failed := 0
+	for _, image := range images {
+		source, err := name.ParseReference(image)
+		if err != nil {
+			return errors.Wrapf(err, "invalid image %s", image)
+		}
+		descriptor, err := remote.Get(source, remoteOptions...)
+		if err != nil {
+			fmt.Printf("Unable to pull %s: %s\n", image, err)
+			failed++
+			continue
+		}
+		if err := pushImage(image, registry, descriptor.Descriptor, descriptor, remoteOptions); err != nil {
+			fmt.Printf("Unable to mirror %s: %s\n", image, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d out of %d images failed to mirror", failed, len(images))
+	}
+	return nil
+}
+
+// imageSource is an image or an image index, read from a registry or from an
+// OCI image layout
+type imageSource interface {
+	Image() (v1.Image, error)
+	ImageIndex() (v1.ImageIndex, error)
+}
+
+// pushImage copies the image, or image index, to the registry, and checks it
+// keeps its digest
+func pushImage(image, registry string, descriptor v1.Descriptor, source imageSource, remoteOptions []remote.Option) error {
+	target, err := mirrorReference(image, registry)
+	if err != nil {
+		return err
+	}
+	if isIndex(descriptor.MediaType) {
+		index, err := source.ImageIndex()
+		if err != nil {
+			return err
+		}
+		err = remote.WriteIndex(target, index, remoteOptions...)
+	} else {
+		var img v1.Image
+		if img, err = source.Image(); err != nil {
+			return err
+		}
+		err = remote.Write(target, img, remoteOptions...)
+	}
+	if err != nil {
+		return err
+	}
+	pushed, err := remote.Head(target, remoteOptions...)
+	if err != nil {
+		return err
+	}
+	if pushed.Digest != descriptor.Digest {
+		return errors.Errorf("%s has digest %s instead of %s", target, pushed.Digest, descriptor.Digest)
+	}
+	fmt.Printf("%s -> %s@%s\n", image, target.Context(), pushed.Digest)
+	return nil
+}
+
+// mirrorReference returns the reference of the image in the registry, which
+// keeps the name and the tag or digest of the image. The SUSE repository
+// prefix of the image is dropped, as the images are located directly in the
+// registry given to `skuba cluster init --image-repository`.
+func mirrorReference(image, registry string) (name.Reference, error) {
+	source, err := name.ParseReference(image)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid image %s", image)
+	}
+	separator := ":"
+	if _, isDigest := source.(name.Digest); isDigest {
+		separator = "@"
+	}
+	target := fmt.Sprintf("%s/%s%s%s", strings.TrimSuffix(registry, "/"), path.Base(source.Context().RepositoryStr()), separator, source.Identifier())
+	reference, err := name.ParseReference(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid registry %s", registry)
+	}
+	return reference, nil
+}
+
+func isIndex(mediaType types.MediaType) bool {
+	return mediaType == types.OCIImageIndex || mediaType == types.DockerManifestList
+}
+
+// writeOCILayout writes the images to an OCI image layout tarball, annotating
+// every image with its reference
+func writeOCILayout(images []string, tarball string, remoteOptions []remote.Option) error {
+	dir, err := ioutil.TempDir("", "skuba-images-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	layoutPath, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		source, err := name.ParseReference(image)
+		if err != nil {
+			return errors.Wrapf(err, "invalid image %s", image)
+		}
+		descriptor, err := remote.Get(source, remoteOptions...)
+		if err != nil {
+			return errors.Wrapf(err, "unable to pull %s", image)
+		}
+		annotations := layout.WithAnnotations(map[string]string{refNameAnnotation: image})
+		if isIndex(descriptor.MediaType) {
+			index, err := descriptor.ImageIndex()
+			if err != nil {
+				return err
+			}
+			err = layoutPath.AppendIndex(index, annotations)
+		} else {
+			var img v1.Image
+			if img, err = descriptor.Image(); err != nil {
+				return err
+			}
+			err = layoutPath.AppendImage(img, annotations)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to write %s", image)
+		}
+		fmt.Printf("%s -> %s@%s\n", image, tarball, descriptor.Digest)
+	}
+	return writeTarball(dir, tarball)
+}
+
+// layoutSource is an image or image index of an OCI image layout
+type layoutSource struct {
+	index  v1.ImageIndex
+	digest v1.Hash
+}
+
+func (s layoutSource) Image() (v1.Image, error) {
+	return s.index.Image(s.digest)
+}
+
+func (s layoutSource) ImageIndex() (v1.ImageIndex, error) {
+	return s.index.ImageIndex(s.digest)
+}
+
+// mirrorOCILayout copies the images of an OCI image layout tarball written
+// by writeOCILayout to the registry
+func mirrorOCILayout(tarball, registry string, remoteOptions []remote.Option) error {
+	dir, err := ioutil.TempDir("", "skuba-images-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := extractTarball(tarball, dir); err != nil {
+		return errors.Wrapf(err, "unable to extract %s", tarball)
+	}
+	layoutPath, err := layout.FromPath(dir)
+	if err != nil {
+		return errors.Wrapf(err, "%s is not an OCI image layout", tarball)
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, descriptor := range indexManifest.Manifests {
+		image := descriptor.Annotations[refNameAnnotation]
+		if image == "" {
+			fmt.Printf("Unable to mirror %s: no %s annotation\n", descriptor.Digest, refNameAnnotation)
+			failed++
+			continue
+		}
+		if err := pushImage(image, registry, descriptor, layoutSource{index: index, digest: descriptor.Digest}, remoteOptions); err != nil {
+			fmt.Printf("Unable to mirror %s: %s\n", image, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d out of %d images failed to mirror", failed, len(indexManifest.Manifests))
+	}
+	return nil
+}
+
+// registryOptions returns the options to access the registries with the
+// credentials of the Docker config.json file, or with the Docker
+// configuration of the user when it is empty
+func registryOptions(credentialsFile string) ([]remote.Option, error) {
+	keychain := authn.DefaultKeychain
+	if credentialsFile != "" {
+		var err error
+		if keychain, err = loadCredentialsFile(credentialsFile); err != nil {
+			return nil, err
+		}
+	}
+	return []remote.Option{remote.WithAuthFromKeychain(keychain)}, nil
+}
+
+// credentialsKeychain resolves the credentials of a registry from a Docker
+// config.json file
+type credentialsKeychain map[string]authn.AuthConfig
+
+// loadCredentialsFile reads the "auths" of a Docker config.json file
+func loadCredentialsFile(path string) (authn.Keychain, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read credentials file")
+	}
+	config := struct {
+		Auths map[string]authn.AuthConfig `json:"auths"`
+	}{}
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse credentials file %s", path)
+	}
+	keychain := credentialsKeychain{}
+	for registry, authConfig := range config.Auths {
+		keychain[registryHost(registry)] = authConfig
+	}
+	return keychain, nil
+}
+
+// registryHost returns the host of a registry of a Docker config.json file,
+// which may be a URL
+func registryHost(registry string) string {
+	if strings.Contains(registry, "://") {
+		if registryURL, err := url.Parse(registry); err == nil {
+			registry = registryURL.Host
+		}
+	}
+	registry = strings.SplitN(registry, "/", 2)[0]
+	if registry == "docker.io" || registry == "index.docker.io" {
+		return name.DefaultRegistry
+	}
+	return registry
+}
+
+// Resolve implements authn.Keychain
+func (k credentialsKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	authConfig, found := k[registryHost(target.RegistryStr())]
+	if !found {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authConfig), nil
+}
+
+// writeTarball writes the files of the directory to a tarball
+func writeTarball(dir, tarball string) error {
+	file, err := os.Create(tarball)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := tar.NewWriter(file)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relativePath)
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		contents, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer contents.Close()
+		_, err = io.Copy(writer, contents)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to write %s", tarball)
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrapf(err, "unable to write %s", tarball)
+	}
+	return file.Close()
+}
+
+// extractTarball extracts the files of a tarball written by writeTarball to
+// the directory
+func extractTarball(tarball, dir string) error {
+	file, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return errors.Errorf("invalid file name %s", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return err
+			}
+			contents, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(contents, reader)
+			contents.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
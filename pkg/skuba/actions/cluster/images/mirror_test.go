@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+func mustParseReference(t *testing.T, image string) name.Reference {
+	reference, err := name.ParseReference(image)
+	if err != nil {
+		t.Fatalf("invalid image %s: %v", image, err)
+	}
+	return reference
+}
+
+// mirrorTestRegistry starts a registry holding an image and an image index,
+// and returns their references and digests
+func mirrorTestRegistry(t *testing.T) (*httptest.Server, map[string]v1.Hash) {
+	server := httptest.NewServer(registry.New())
+	host := strings.TrimPrefix(server.URL, "http://")
+	image, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatalf("unable to create image: %v", err)
+	}
+	index, err := random.Index(1024, 1, 2)
+	if err != nil {
+		t.Fatalf("unable to create image index: %v", err)
+	}
+	imageRef := host + "/caasp/v4.5/kured:1.4.0"
+	indexRef := host + "/caasp/v4.5/dex:2.23.0"
+	if err := remote.Write(mustParseReference(t, imageRef), image); err != nil {
+		t.Fatalf("unable to push image: %v", err)
+	}
+	if err := remote.WriteIndex(mustParseReference(t, indexRef), index); err != nil {
+		t.Fatalf("unable to push image index: %v", err)
+	}
+	imageDigest, _ := image.Digest()
+	indexDigest, _ := index.Digest()
+	return server, map[string]v1.Hash{imageRef: imageDigest, indexRef: indexDigest}
+}
+
+func checkMirroredImages(t *testing.T, images map[string]v1.Hash, registry string) {
+	for image, digest := range images {
+		target, err := mirrorReference(image, registry)
+		if err != nil {
+			t.Fatalf("error not expected, but an error was reported (%v)", err)
+		}
+		descriptor, err := remote.Head(target)
+		if err != nil {
+			t.Errorf("%s expected to be mirrored, got %v", target, err)
+			continue
+		}
+		if descriptor.Digest != digest {
+			t.Errorf("%s expected to keep digest %s, got %s", target, digest, descriptor.Digest)
+		}
+	}
+}
+
+func TestMirrorImages(t *testing.T) {
+	source, images := mirrorTestRegistry(t)
+	defer source.Close()
+	target := httptest.NewServer(registry.New())
+	defer target.Close()
+
+	mirrorRegistry := strings.TrimPrefix(target.URL, "http://") + "/mirror"
+	refs := []string{}
+	for image := range images {
+		refs = append(refs, image)
+	}
+	if err := mirrorImages(refs, mirrorRegistry, nil); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	checkMirroredImages(t, images, mirrorRegistry)
+	if _, err := remote.Head(mustParseReference(t, mirrorRegistry+"/kured:1.4.0")); err != nil {
+		t.Errorf("expected the image to be mirrored without its repository prefix, got %v", err)
+	}
+
+	err := mirrorImages([]string{strings.TrimPrefix(source.URL, "http://") + "/caasp/v4.5/missing:1.0.0"}, mirrorRegistry, nil)
+	if err == nil || err.Error() != "1 out of 1 images failed to mirror" {
+		t.Errorf("missing image expected to fail, got %v", err)
+	}
+}
+
+func TestMirrorOCILayout(t *testing.T) {
+	source, images := mirrorTestRegistry(t)
+	refs := []string{}
+	for image := range images {
+		refs = append(refs, image)
+	}
+	dir, err := ioutil.TempDir("", "skuba-mirror-test")
+	if err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	tarball := filepath.Join(dir, "images.tar")
+	if err := writeOCILayout(refs, tarball, nil); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	// the images are copied from the tarball, not from their registry
+	source.Close()
+
+	target := httptest.NewServer(registry.New())
+	defer target.Close()
+	mirrorRegistry := strings.TrimPrefix(target.URL, "http://")
+	if err := Mirror(MirrorOptions{Registry: mirrorRegistry, FromOCILayout: tarball}); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	checkMirroredImages(t, images, mirrorRegistry)
+}
+
+func TestMirrorReference(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		registry string
+		expected string
+	}{
+		{
+			name:     "tag",
+			image:    "registry.suse.com/caasp/v4.5/hyperkube:v1.18.10",
+			registry: "mirror.example.com:5000",
+			expected: "mirror.example.com:5000/hyperkube:v1.18.10",
+		},
+		{
+			name:     "repository prefix",
+			image:    "registry.suse.com/caasp/v4.5/pause:3.2",
+			registry: "mirror.example.com/suse/",
+			expected: "mirror.example.com/suse/pause:3.2",
+		},
+		{
+			name:     "digest",
+			image:    "registry.suse.com/caasp/v4.5/pause@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			registry: "mirror.example.com",
+			expected: "mirror.example.com/pause@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			reference, err := mirrorReference(tt.image, tt.registry)
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if reference.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, reference)
+			}
+		})
+	}
+}
+
+// TestMirrorToImageRepository checks that the images of every version are
+// mirrored where a cluster using the registry as its image repository pulls
+// them from
+func TestMirrorToImageRepository(t *testing.T) {
+	imageRepository := "mirror.example.com/suse"
+	versions, err := clusterVersions("")
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	for _, clusterVersion := range versions {
+		defaultRepository := skuba.ImageRepository(clusterVersion, "")
+		for _, image := range imagesForClusterVersion(clusterVersion) {
+			reference, err := mirrorReference(image, imageRepository)
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			expected := skuba.ImageRepository(clusterVersion, imageRepository) + strings.TrimPrefix(image, defaultRepository)
+			if reference.String() != expected {
+				t.Errorf("image %s of version %s expected to be mirrored to %s, got %s", image, clusterVersion, expected, reference)
+			}
+		}
+	}
+}
+
+func TestLoadCredentialsFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "skuba-credentials")
+	if err != nil {
+		t.Fatalf("unable to create file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	contents := `{"auths": {"https://mirror.example.com:5000/v2/": {"username": "skuba", "password": "secret"}, "https://index.docker.io/v1/": {"auth": "c2t1YmE6ZG9ja2Vy"}}}`
+	if err := ioutil.WriteFile(file.Name(), []byte(contents), 0600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	keychain, err := loadCredentialsFile(file.Name())
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+
+	tests := []struct {
+		name     string
+		image    string
+		expected authn.AuthConfig
+	}{
+		{
+			name:     "registry with credentials",
+			image:    "mirror.example.com:5000/caasp/v4.5/pause:3.2",
+			expected: authn.AuthConfig{Username: "skuba", Password: "secret"},
+		},
+		{
+			name:     "docker hub",
+			image:    "busybox:latest",
+			expected: authn.AuthConfig{Auth: "c2t1YmE6ZG9ja2Vy"},
+		},
+		{
+			name:  "registry without credentials",
+			image: "registry.suse.com/caasp/v4.5/pause:3.2",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator, err := keychain.Resolve(mustParseReference(t, tt.image).Context())
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			authConfig, err := authenticator.Authorization()
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if *authConfig != tt.expected {
+				t.Errorf("expected credentials %v, got %v", tt.expected, *authConfig)
+			}
+		})
+	}
+}
+
+func TestMirrorOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      MirrorOptions
+		expectErrMsg string
+	}{
+		{
+			name:         "no destination",
+			options:      MirrorOptions{},
+			expectErrMsg: "either a registry or an OCI layout tarball has to be provided",
+		},
+		{
+			name:         "both destinations",
+			options:      MirrorOptions{Registry: "mirror.example.com", OCILayout: "images.tar"},
+			expectErrMsg: "either a registry or an OCI layout tarball has to be provided",
+		},
+		{
+			name:         "OCI layout to OCI layout",
+			options:      MirrorOptions{OCILayout: "images.tar", FromOCILayout: "images.tar"},
+			expectErrMsg: "the images of an OCI layout tarball can only be copied to a registry",
+		},
+		{
+			name:         "unknown version",
+			options:      MirrorOptions{Registry: "mirror.example.com", Version: "1.10.0"},
+			expectErrMsg: "version 1.10.0 does not exist or cannot be parsed",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			err := Mirror(tt.options)
+			if err == nil || err.Error() != tt.expectErrMsg {
+				t.Errorf("expected error %q, got %v", tt.expectErrMsg, err)
+			}
+		})
+	}
+}
@@ -47,6 +47,13 @@ var (
 		},
 	}
 
+	registriesScaffoldFiles = []ScaffoldFile{
+		{
+			Location: skuba.RegistriesReadmeFile(),
+			Content:  registriesReadme,
+		},
+	}
+
 	cloudScaffoldFiles = map[string][]ScaffoldFile{
 		"openstack": {
 			{
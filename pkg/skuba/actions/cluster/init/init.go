@@ -23,8 +23,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -38,6 +40,7 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	"github.com/SUSE/skuba/internal/pkg/skuba/util"
 	"github.com/SUSE/skuba/pkg/skuba"
 )
@@ -49,10 +52,13 @@ type InitConfiguration struct {
 	PauseImage        string
 	KubernetesVersion *versionutil.Version
 	ImageRepository   string
-	EtcdImageTag      string
-	CoreDNSImageTag   string
-	CloudProvider     string
-	StrictCapDefaults bool
+	// CustomImageRepository is the image registry given to
+	// `--image-repository`, empty for the default image registry
+	CustomImageRepository string
+	EtcdImageTag          string
+	CoreDNSImageTag       string
+	CloudProvider         string
+	StrictCapDefaults     bool
 	// Note: UseHyperKube can be removed when we drop the support of
 	// provisioning clusters of version 1.17.
 	UseHyperKube bool
@@ -67,7 +73,7 @@ func (initConfiguration InitConfiguration) ControlPlaneHostAndPort() string {
 	return util.ControlPlaneHostAndPort(initConfiguration.ControlPlane)
 }
 
-func NewInitConfiguration(clusterName, cloudProvider, controlPlane, kubernetesDesiredVersion string, strictCapDefaults bool, cniPlugin string) (InitConfiguration, error) {
+func NewInitConfiguration(clusterName, cloudProvider, controlPlane, kubernetesDesiredVersion string, strictCapDefaults bool, cniPlugin, imageRepository string) (InitConfiguration, error) {
 	kubernetesVersion := kubernetes.LatestVersion()
 	var err error
 	needsHyperKube := false
@@ -78,6 +84,11 @@ func NewInitConfiguration(clusterName, cloudProvider, controlPlane, kubernetesDe
 		}
 	}
 
+	if imageRepository != "" {
+		if _, err := name.NewRepository(strings.TrimSuffix(imageRepository, "/")); err != nil {
+			return InitConfiguration{}, errors.Wrapf(err, "invalid image repository %q", imageRepository)
+		}
+	}
 	// Without this, it will be impossible to greenfield an older caasp cluster:
 	// defaults have been changed in 1.17, so we *need* to have UseHyperKubeImage: set into the init configuration.
 	if kubernetesVersion.Minor() < 18 {
@@ -85,17 +96,18 @@ func NewInitConfiguration(clusterName, cloudProvider, controlPlane, kubernetesDe
 	}
 
 	return InitConfiguration{
-		ClusterName:       clusterName,
-		CloudProvider:     cloudProvider,
-		ControlPlane:      controlPlane,
-		PauseImage:        kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, kubernetesVersion),
-		KubernetesVersion: kubernetesVersion,
-		ImageRepository:   skuba.ImageRepository(kubernetesVersion),
-		EtcdImageTag:      kubernetes.ComponentVersionForClusterVersion(kubernetes.Etcd, kubernetesVersion),
-		CoreDNSImageTag:   kubernetes.ComponentVersionForClusterVersion(kubernetes.CoreDNS, kubernetesVersion),
-		StrictCapDefaults: strictCapDefaults,
-		UseHyperKube:      needsHyperKube,
-		CniPlugin:         kubernetes.Addon(cniPlugin),
+		ClusterName:           clusterName,
+		CloudProvider:         cloudProvider,
+		ControlPlane:          controlPlane,
+		PauseImage:            kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, kubernetesVersion, imageRepository),
+		KubernetesVersion:     kubernetesVersion,
+		ImageRepository:       skuba.ImageRepository(kubernetesVersion, imageRepository),
+		CustomImageRepository: imageRepository,
+		EtcdImageTag:          kubernetes.ComponentVersionForClusterVersion(kubernetes.Etcd, kubernetesVersion),
+		CoreDNSImageTag:       kubernetes.ComponentVersionForClusterVersion(kubernetes.CoreDNS, kubernetesVersion),
+		StrictCapDefaults:     strictCapDefaults,
+		UseHyperKube:          needsHyperKube,
+		CniPlugin:             kubernetes.Addon(cniPlugin),
 	}, nil
 }
 
@@ -142,7 +154,7 @@ func isAddonRequired(addon addons.Addon, initConfiguration InitConfiguration) bo
 }
 
 func writeScaffoldFiles(initConfiguration InitConfiguration) error {
-	scaffoldFilesToWrite := CriScaffoldFiles["criconfig"]
+	scaffoldFilesToWrite := append(CriScaffoldFiles["criconfig"], registriesScaffoldFiles...)
 
 	if len(initConfiguration.CloudProvider) > 0 {
 		if cloudScaffoldFiles, found := cloudScaffoldFiles[initConfiguration.CloudProvider]; found {
@@ -163,6 +175,11 @@ func writeScaffoldFiles(initConfiguration InitConfiguration) error {
 			return err
 		}
 	}
+	// the pause image, the control plane images and the addon images all
+	// come from the custom image repository, bootstrap reads it back
+	if err := skubaconfig.WriteLocalImageRepository(initConfiguration.CustomImageRepository); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -217,9 +234,10 @@ func writeKubeadmFiles(initConfiguration InitConfiguration) error {
 func writeAddonConfigFiles(initConfiguration InitConfiguration) error {
 	// Write addon configuration files
 	addonConfiguration := addons.AddonConfiguration{
-		ClusterVersion: initConfiguration.KubernetesVersion,
-		ControlPlane:   initConfiguration.ControlPlane,
-		ClusterName:    initConfiguration.ClusterName,
+		ClusterVersion:  initConfiguration.KubernetesVersion,
+		ControlPlane:    initConfiguration.ControlPlane,
+		ClusterName:     initConfiguration.ClusterName,
+		ImageRepository: initConfiguration.CustomImageRepository,
 	}
 	for addonName, addon := range addons.Addons {
 		if !isAddonRequired(addon, initConfiguration) {
@@ -292,7 +310,7 @@ func writeKubeadmInitConf(initConfiguration InitConfiguration) error {
 	if len(initConfiguration.CloudProvider) > 0 {
 		updateInitConfigurationWithCloudIntegration(&initCfg, initConfiguration)
 	}
-	kubeadm.UpdateClusterConfigurationWithClusterVersion(&initCfg, initConfiguration.KubernetesVersion)
+	kubeadm.UpdateClusterConfigurationWithClusterVersion(&initCfg, initConfiguration.KubernetesVersion, initConfiguration.CustomImageRepository)
 	initCfgContents, err := kubeadmconfigutil.MarshalInitConfigurationToBytes(&initCfg, schema.GroupVersion{
 		Group:   "kubeadm.k8s.io",
 		Version: kubeadm.GetKubeadmApisVersion(initConfiguration.KubernetesVersion),
@@ -22,8 +22,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
 	constants "github.com/SUSE/skuba/pkg/skuba"
 )
@@ -99,7 +101,8 @@ func TestInitCloudProvider(t *testing.T) {
 				controlPlane,
 				k8sDesiredVersion,
 				strictCapDefaults,
-				cniPlugin)
+				cniPlugin,
+				"")
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -170,7 +173,8 @@ func TestInitCniPlugin(t *testing.T) {
 				controlPlane,
 				k8sDesiredVersion,
 				strictCapDefaults,
-				cniPlugin)
+				cniPlugin,
+				"")
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -203,6 +207,55 @@ func TestInitCniPlugin(t *testing.T) {
 	}
 }
 
+func TestInitImageRepository(t *testing.T) {
+	tests := []struct {
+		name                  string
+		imageRepository       string
+		expectImageRepository string
+		expectErr             bool
+	}{
+		{
+			name:                  "default image repository",
+			expectImageRepository: constants.ImageRepository(kubernetes.LatestVersion(), ""),
+		},
+		{
+			name:                  "custom image repository",
+			imageRepository:       "mirror.example.com:5000/caasp/v4.5/",
+			expectImageRepository: "mirror.example.com:5000/caasp/v4.5",
+		},
+		{
+			name:            "invalid image repository",
+			imageRepository: "mirror.example.com/CaaSP",
+			expectErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			initConf, err := NewInitConfiguration("testCluster", "", "k8s.example.com", "", true, "cilium", tt.imageRepository)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("error expected, but no error reported")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error not expected, but an error was reported (%v)", err)
+			}
+			if initConf.CustomImageRepository != tt.imageRepository {
+				t.Errorf("expected custom image repository %q, got %q", tt.imageRepository, initConf.CustomImageRepository)
+			}
+			if initConf.ImageRepository != tt.expectImageRepository {
+				t.Errorf("expected image repository %q, got %q", tt.expectImageRepository, initConf.ImageRepository)
+			}
+			expectPauseImagePrefix := tt.expectImageRepository + "/pause:"
+			if !strings.HasPrefix(initConf.PauseImage, expectPauseImagePrefix) {
+				t.Errorf("expected pause image %q to start with %q", initConf.PauseImage, expectPauseImagePrefix)
+			}
+		})
+	}
+}
+
 // check the init configuration hold inside of `file`. Path to file is built starting
 // from the test `ctx` and the `clusterName`, plus the ending name of the `file`.
 // `cloud` holds the name of the CPI - leave empty if CPI is disabled.
@@ -22,6 +22,29 @@ const (
 All the files (except this README) will be uploaded to the /etc/crio/crio.conf.d/ folder.
 If you need any customization, please add a custom files with the name 99-custom.conf
 	`
+	registriesReadme = `# Container registries
+
+Create a registries.yaml file in this folder to configure the registry mirrors,
+the insecure registries and the registry credentials of the nodes, e.g.:
+
+    registries:
+    - location: registry.suse.com
+      mirrors:
+      - location: mirror.example.com:5000
+        insecure: true
+    - location: registry.example.com
+      blocked: true
+    auths:
+      mirror.example.com:5000:
+        username: user
+        password: secret
+
+The configuration is rendered on every node by "skuba node bootstrap" and
+"skuba node join". After changing it, roll it out to the existing nodes with
+"skuba node registries apply --all --inventory <file>".
+
+It replaces the addons/containers/registries.conf file, if any, on the nodes.
+`
 	criDockerDefaultsConf = `# PLEASE DON'T EDIT THIS FILE!
 # This file is managed by CaaSP skuba.
 
@@ -19,65 +19,330 @@ package cluster
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientset "k8s.io/client-go/kubernetes"
-	kubectlget "k8s.io/kubectl/pkg/cmd/get"
+	"k8s.io/klog"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+	configutil "k8s.io/kubernetes/cmd/kubeadm/app/util/config"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/skuba"
+)
+
+const (
+	// OutputTable prints the nodes in a table, the default output
+	OutputTable = "table"
+	// OutputWide prints the nodes in a table with additional columns, followed by the cluster information
+	OutputWide = "wide"
+	// OutputJSON prints the whole status as JSON
+	OutputJSON = "json"
+	// OutputYAML prints the whole status as YAML
+	OutputYAML = "yaml"
+
+	hasUpdatesAnnotation           = "caasp.suse.com/has-updates"
+	hasDisruptiveUpdatesAnnotation = "caasp.suse.com/has-disruptive-updates"
+	caaspReleaseVersionAnnotation  = "caasp.suse.com/caasp-release-version"
+
+	noValue = "<none>"
+
+	// etcdPodSelector selects the etcd static pods created by kubeadm
+	etcdPodSelector = "component=etcd,tier=control-plane"
 )
 
-// Status prints the status of the cluster on the standard output by reading the
-// admin configuration file from the current folder
-func Status(client clientset.Interface) error {
+// OutputFormats are the formats accepted by Status
+var OutputFormats = []string{OutputTable, OutputWide, OutputJSON, OutputYAML}
+
+// NodeStatus is the status of a node of the cluster
+type NodeStatus struct {
+	Name                    string `json:"name"`
+	Ready                   bool   `json:"ready"`
+	Unschedulable           bool   `json:"unschedulable"`
+	Role                    string `json:"role,omitempty"`
+	InternalIP              string `json:"internalIP,omitempty"`
+	OSImage                 string `json:"osImage"`
+	KernelVersion           string `json:"kernelVersion"`
+	KubeletVersion          string `json:"kubeletVersion"`
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+	HasUpdates              string `json:"hasUpdates,omitempty"`
+	HasDisruptiveUpdates    string `json:"hasDisruptiveUpdates,omitempty"`
+	CaaSPReleaseVersion     string `json:"caaspReleaseVersion,omitempty"`
+}
+
+// AddonStatus is the version of an addon deployed in the cluster, as
+// recorded in the skuba-config ConfigMap
+type AddonStatus struct {
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	ManifestVersion uint   `json:"manifestVersion"`
+}
+
+// APIEndpoint is the API server endpoint of a control plane node
+type APIEndpoint struct {
+	Node    string `json:"node"`
+	Address string `json:"address"`
+}
+
+// ClusterStatus is the status of the cluster and all its nodes. EtcdMembers
+// is nil when the etcd members could not be counted.
+type ClusterStatus struct {
+	ClusterVersion       string        `json:"clusterVersion"`
+	ControlPlaneEndpoint string        `json:"controlPlaneEndpoint"`
+	APIEndpoints         []APIEndpoint `json:"apiEndpoints"`
+	EtcdMembers          *int          `json:"etcdMembers,omitempty"`
+	Addons               []AddonStatus `json:"addons"`
+	Nodes                []NodeStatus  `json:"nodes"`
+}
+
+// GetStatus returns the status of the cluster and all its nodes
+func GetStatus(client clientset.Interface) (*ClusterStatus, error) {
+	status, err := GetNodesStatus(client)
+	if err != nil {
+		return nil, err
+	}
+	if err := addClusterInformation(client, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetNodesStatus returns the status of all the nodes, without the cluster
+// information read from the kubeadm-config and skuba-config ConfigMaps
+func GetNodesStatus(client clientset.Interface) (*ClusterStatus, error) {
 	nodeList, err := client.CoreV1().Nodes().List(
 		context.TODO(),
 		metav1.ListOptions{})
 	if err != nil {
-		return errors.Wrap(err, "could not retrieve node list")
+		return nil, errors.Wrap(err, "could not retrieve node list")
 	}
 
+	status := &ClusterStatus{
+		APIEndpoints: []APIEndpoint{},
+		Addons:       []AddonStatus{},
+		Nodes:        []NodeStatus{},
+	}
 	for _, node := range nodeList.Items {
-		status := node.Status.Conditions[len(node.Status.Conditions)-1].Status
-		if status == "True" {
-			node.Labels["node-status.kubernetes.io"] = "Ready"
-		} else {
-			node.Labels["node-status.kubernetes.io"] = "NotReady"
-		}
+		status.Nodes = append(status.Nodes, nodeStatus(node))
+	}
+	sort.Slice(status.Nodes, func(i, j int) bool {
+		return status.Nodes[i].Name < status.Nodes[j].Name
+	})
+	return status, nil
+}
 
-		if ok := node.Spec.Unschedulable; ok {
-			node.Labels["node-status.kubernetes.io"] = node.Labels["node-status.kubernetes.io"] + ",SchedulingDisabled"
-		}
+// addClusterInformation sets the cluster version, the endpoints, the etcd
+// members and the addon versions of the status
+func addClusterInformation(client clientset.Interface, status *ClusterStatus) error {
+	initCfg, err := kubeadm.GetClusterConfiguration(client)
+	if err != nil {
+		return err
+	}
+	status.ClusterVersion = initCfg.KubernetesVersion
+	status.ControlPlaneEndpoint = initCfg.ControlPlaneEndpoint
+
+	kubeadmConfig, err := client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(context.TODO(), kubeadmconstants.KubeadmConfigConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the kubeadm-config configmap")
+	}
+	clusterStatus, err := configutil.UnmarshalClusterStatus(kubeadmConfig.Data)
+	if err != nil {
+		return errors.Wrap(err, "could not unmarshal cluster status from kubeadm-config configmap")
+	}
+	for nodeName, endpoint := range clusterStatus.APIEndpoints {
+		status.APIEndpoints = append(status.APIEndpoints, APIEndpoint{
+			Node:    nodeName,
+			Address: fmt.Sprintf("%s:%d", endpoint.AdvertiseAddress, endpoint.BindPort),
+		})
+	}
+	sort.Slice(status.APIEndpoints, func(i, j int) bool {
+		return status.APIEndpoints[i].Node < status.APIEndpoints[j].Node
+	})
 
-		for label := range node.Labels {
-			if strings.Contains(label, "node-role.kubernetes.io") && len(strings.Split(label, "/")) > 0 {
-				node.Labels["caasp-role.kubernetes.io"] = strings.Split(label, "/")[1]
-			}
+	if initCfg.Etcd.External != nil {
+		etcdMembers := len(initCfg.Etcd.External.Endpoints)
+		status.EtcdMembers = &etcdMembers
+	} else if etcdMembers, err := countEtcdPods(client); err != nil {
+		klog.Warningf("the etcd members are unknown: %s", err)
+	} else {
+		status.EtcdMembers = &etcdMembers
+	}
+
+	skubaConfiguration, err := skuba.GetSkubaConfiguration(client)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve the skuba-config configmap")
+	}
+	for addonName, addonVersion := range skubaConfiguration.AddonsVersion {
+		if addonVersion == nil {
+			continue
 		}
+		status.Addons = append(status.Addons, AddonStatus{
+			Name:            string(addonName),
+			Version:         addonVersion.Version,
+			ManifestVersion: addonVersion.ManifestVersion,
+		})
 	}
+	sort.Slice(status.Addons, func(i, j int) bool {
+		return status.Addons[i].Name < status.Addons[j].Name
+	})
+	return nil
+}
 
-	outputFormat := "custom-columns=" +
-		"NAME:.metadata.name," +
-		"STATUS:.metadata.labels.node-status\\.kubernetes\\.io," +
-		"ROLE:.metadata.labels.caasp-role\\.kubernetes\\.io," +
-		"OS-IMAGE:.status.nodeInfo.osImage," +
-		"KERNEL-VERSION:.status.nodeInfo.kernelVersion," +
-		"KUBELET-VERSION:.status.nodeInfo.kubeletVersion," +
-		"CONTAINER-RUNTIME:.status.nodeInfo.containerRuntimeVersion," +
-		"HAS-UPDATES:.metadata.annotations.caasp\\.suse\\.com/has-updates," +
-		"HAS-DISRUPTIVE-UPDATES:.metadata.annotations.caasp\\.suse\\.com/has-disruptive-updates," +
-		"CAASP-RELEASE-VERSION:.metadata.annotations.caasp\\.suse\\.com/caasp-release-version"
+// countEtcdPods counts the etcd static pods of the control plane nodes, one
+// per member of a stacked etcd, without running etcdctl in the cluster
+func countEtcdPods(client clientset.Interface) (int, error) {
+	podList, err := client.CoreV1().Pods(metav1.NamespaceSystem).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: etcdPodSelector,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "could not list the etcd pods")
+	}
+	if len(podList.Items) == 0 {
+		return 0, errors.New("no etcd pod found")
+	}
+	return len(podList.Items), nil
+}
 
-	printFlags := kubectlget.NewGetPrintFlags()
-	printFlags.OutputFormat = &outputFormat
+func nodeStatus(node corev1.Node) NodeStatus {
+	status := NodeStatus{
+		Name:                    node.ObjectMeta.Name,
+		Unschedulable:           node.Spec.Unschedulable,
+		OSImage:                 node.Status.NodeInfo.OSImage,
+		KernelVersion:           node.Status.NodeInfo.KernelVersion,
+		KubeletVersion:          node.Status.NodeInfo.KubeletVersion,
+		ContainerRuntimeVersion: node.Status.NodeInfo.ContainerRuntimeVersion,
+		HasUpdates:              node.ObjectMeta.Annotations[hasUpdatesAnnotation],
+		HasDisruptiveUpdates:    node.ObjectMeta.Annotations[hasDisruptiveUpdatesAnnotation],
+		CaaSPReleaseVersion:     node.ObjectMeta.Annotations[caaspReleaseVersionAnnotation],
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			status.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			status.InternalIP = address.Address
+		}
+	}
+	for label := range node.ObjectMeta.Labels {
+		if strings.HasPrefix(label, "node-role.kubernetes.io/") {
+			status.Role = strings.TrimPrefix(label, "node-role.kubernetes.io/")
+		}
+	}
+	return status
+}
 
-	printer, err := printFlags.ToPrinter()
+// Status prints the status of the cluster on the standard output in the
+// given output format, by reading the admin configuration file from the
+// current folder. The cluster information is only retrieved for the output
+// formats printing it.
+func Status(client clientset.Interface, outputFormat string) error {
+	getStatus := GetStatus
+	if outputFormat == OutputTable || outputFormat == "" {
+		getStatus = GetNodesStatus
+	}
+	status, err := getStatus(client)
 	if err != nil {
-		return errors.Wrap(err, "could not create printer")
+		return err
+	}
+	return PrintStatus(os.Stdout, status, outputFormat)
+}
+
+// PrintStatus writes the status of the cluster in the given output format
+func PrintStatus(out io.Writer, status *ClusterStatus, outputFormat string) error {
+	switch outputFormat {
+	case OutputTable, "":
+		return printNodesTable(out, status, false)
+	case OutputWide:
+		if err := printNodesTable(out, status, true); err != nil {
+			return err
+		}
+		return printClusterInformation(out, status)
+	case OutputJSON:
+		contents, err := json.MarshalIndent(status, "", "    ")
+		if err != nil {
+			return errors.Wrap(err, "could not marshal cluster status")
+		}
+		_, err = fmt.Fprintln(out, string(contents))
+		return err
+	case OutputYAML:
+		contents, err := yaml.Marshal(status)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal cluster status")
+		}
+		_, err = out.Write(contents)
+		return err
 	}
-	if err := printer.PrintObj(nodeList, os.Stdout); err != nil {
-		return errors.Wrap(err, "could not print to stdout")
+	return errors.Errorf("invalid output format %q, must be one of: %s", outputFormat, strings.Join(OutputFormats, ", "))
+}
+
+func printNodesTable(out io.Writer, status *ClusterStatus, wide bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	columns := []string{"NAME", "STATUS", "ROLE"}
+	if wide {
+		columns = append(columns, "INTERNAL-IP")
 	}
-	return nil
+	columns = append(columns, "OS-IMAGE", "KERNEL-VERSION", "KUBELET-VERSION", "CONTAINER-RUNTIME", "HAS-UPDATES", "HAS-DISRUPTIVE-UPDATES", "CAASP-RELEASE-VERSION")
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	for _, node := range status.Nodes {
+		nodeStatus := "NotReady"
+		if node.Ready {
+			nodeStatus = "Ready"
+		}
+		if node.Unschedulable {
+			nodeStatus += ",SchedulingDisabled"
+		}
+		values := []string{node.Name, nodeStatus, valueOrNone(node.Role)}
+		if wide {
+			values = append(values, valueOrNone(node.InternalIP))
+		}
+		values = append(values,
+			valueOrNone(node.OSImage),
+			valueOrNone(node.KernelVersion),
+			valueOrNone(node.KubeletVersion),
+			valueOrNone(node.ContainerRuntimeVersion),
+			valueOrNone(node.HasUpdates),
+			valueOrNone(node.HasDisruptiveUpdates),
+			valueOrNone(node.CaaSPReleaseVersion),
+		)
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	return w.Flush()
+}
+
+func printClusterInformation(out io.Writer, status *ClusterStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Cluster version:\t%s\n", valueOrNone(status.ClusterVersion))
+	fmt.Fprintf(w, "Control plane endpoint:\t%s\n", valueOrNone(status.ControlPlaneEndpoint))
+	etcdMembers := "unknown"
+	if status.EtcdMembers != nil {
+		etcdMembers = strconv.Itoa(*status.EtcdMembers)
+	}
+	fmt.Fprintf(w, "Etcd members:\t%s\n", etcdMembers)
+	for _, endpoint := range status.APIEndpoints {
+		fmt.Fprintf(w, "API endpoint:\t%s\t%s\n", endpoint.Node, endpoint.Address)
+	}
+	for _, addon := range status.Addons {
+		fmt.Fprintf(w, "Addon:\t%s\t%s (manifest %d)\n", addon.Name, valueOrNone(addon.Version), addon.ManifestVersion)
+	}
+	return w.Flush()
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return noValue
+	}
+	return value
 }
@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func statusTestClientset() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "worker-1",
+				Labels: map[string]string{"node-role.kubernetes.io/worker": ""},
+			},
+			Spec: corev1.NodeSpec{Unschedulable: true},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionFalse},
+					{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+				},
+				NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.18.6"},
+			},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "master-1",
+				Labels: map[string]string{"node-role.kubernetes.io/master": ""},
+				Annotations: map[string]string{
+					"caasp.suse.com/has-updates":            "yes",
+					"caasp.suse.com/has-disruptive-updates": "no",
+				},
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+				NodeInfo: corev1.NodeSystemInfo{
+					OSImage:                 "SUSE Linux Enterprise Server 15 SP2",
+					KernelVersion:           "5.3.18-24.9-default",
+					KubeletVersion:          "v1.18.10",
+					ContainerRuntimeVersion: "cri-o://1.18.4",
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "etcd-master-1",
+				Namespace: metav1.NamespaceSystem,
+				Labels:    map[string]string{"component": "etcd", "tier": "control-plane"},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kube-apiserver-master-1",
+				Namespace: metav1.NamespaceSystem,
+				Labels:    map[string]string{"component": "kube-apiserver", "tier": "control-plane"},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "kubeadm-config",
+				Namespace: metav1.NamespaceSystem,
+			},
+			Data: map[string]string{
+				"ClusterConfiguration": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.18.10
+controlPlaneEndpoint: lb.example.com:6443
+`,
+				"ClusterStatus": `
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterStatus
+apiEndpoints:
+  master-1:
+    advertiseAddress: 10.0.0.1
+    bindPort: 6443
+`,
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "skuba-config",
+				Namespace: metav1.NamespaceSystem,
+			},
+			Data: map[string]string{
+				"SkubaConfiguration": `
+AddonsVersion:
+  kured:
+    Version: 1.4.3
+    ManifestVersion: 4520
+  cilium:
+    Version: 1.7.6-rev3
+    ManifestVersion: 4520
+`,
+			},
+		},
+	)
+}
+
+func TestGetStatus(t *testing.T) {
+	etcdMembers := 1
+
+	status, err := GetStatus(statusTestClientset())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &ClusterStatus{
+		ClusterVersion:       "v1.18.10",
+		ControlPlaneEndpoint: "lb.example.com:6443",
+		APIEndpoints:         []APIEndpoint{{Node: "master-1", Address: "10.0.0.1:6443"}},
+		EtcdMembers:          &etcdMembers,
+		Addons: []AddonStatus{
+			{Name: "cilium", Version: "1.7.6-rev3", ManifestVersion: 4520},
+			{Name: "kured", Version: "1.4.3", ManifestVersion: 4520},
+		},
+		Nodes: []NodeStatus{
+			{
+				Name:                    "master-1",
+				Ready:                   true,
+				Role:                    "master",
+				InternalIP:              "10.0.0.1",
+				OSImage:                 "SUSE Linux Enterprise Server 15 SP2",
+				KernelVersion:           "5.3.18-24.9-default",
+				KubeletVersion:          "v1.18.10",
+				ContainerRuntimeVersion: "cri-o://1.18.4",
+				HasUpdates:              "yes",
+				HasDisruptiveUpdates:    "no",
+			},
+			{
+				Name:           "worker-1",
+				Unschedulable:  true,
+				Role:           "worker",
+				KubeletVersion: "v1.18.6",
+			},
+		},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("unexpected status:\n got: %+v\nwant: %+v", status, expected)
+	}
+}
+
+func TestGetStatusDegraded(t *testing.T) {
+	// the etcd static pod of the control plane is not found
+	client := statusTestClientset()
+	if err := client.CoreV1().Pods(metav1.NamespaceSystem).Delete(context.TODO(), "etcd-master-1", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, err := GetStatus(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.EtcdMembers != nil {
+		t.Errorf("etcd members expected to be unknown, got %d", *status.EtcdMembers)
+	}
+	out := bytes.Buffer{}
+	if err := PrintStatus(&out, status, OutputWide); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "unknown") {
+		t.Errorf("etcd members expected to be printed as unknown:\n%s", out.String())
+	}
+
+	// the nodes table does not need the kubeadm-config and skuba-config
+	// ConfigMaps
+	client = statusTestClientset()
+	for _, configMap := range []string{"kubeadm-config", "skuba-config"} {
+		if err := client.CoreV1().ConfigMaps(metav1.NamespaceSystem).Delete(context.TODO(), configMap, metav1.DeleteOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := GetStatus(client); err == nil {
+		t.Error("expected error without the kubeadm-config configmap")
+	}
+	status, err = GetNodesStatus(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(status.Nodes) != 2 {
+		t.Errorf("expected 2 nodes, got %d", len(status.Nodes))
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	status, err := GetStatus(statusTestClientset())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("table", func(t *testing.T) {
+		out := bytes.Buffer{}
+		if err := PrintStatus(&out, status, OutputTable); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got %d:\n%s", len(lines), out.String())
+		}
+		if fields := strings.Fields(lines[0]); fields[0] != "NAME" || fields[len(fields)-1] != "CAASP-RELEASE-VERSION" {
+			t.Errorf("unexpected header: %s", lines[0])
+		}
+		if fields := strings.Fields(lines[2]); fields[1] != "NotReady,SchedulingDisabled" || fields[3] != "<none>" {
+			t.Errorf("unexpected worker line: %s", lines[2])
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := bytes.Buffer{}
+		if err := PrintStatus(&out, status, OutputJSON); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		decoded := &ClusterStatus{}
+		if err := json.Unmarshal(out.Bytes(), decoded); err != nil {
+			t.Fatalf("could not decode json output: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, status) {
+			t.Errorf("decoded status does not match:\n got: %+v\nwant: %+v", decoded, status)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := PrintStatus(&bytes.Buffer{}, status, "xml"); err == nil {
+			t.Error("expected error for invalid output format")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/lock"
+)
+
+// Unlock removes the cluster lock left behind by a skuba command that did not
+// finish. Without force, it only reports who holds the lock.
+func Unlock(client clientset.Interface, force bool) error {
+	if !force {
+		holder, err := lock.CurrentHolder(client)
+		if err != nil {
+			return err
+		}
+		if holder == nil {
+			fmt.Println("[unlock] the cluster is not locked")
+			return nil
+		}
+		fmt.Printf("[unlock] the cluster is locked by %s\n", holder)
+		return errors.New("refusing to remove the cluster lock without --force; make sure that command is no longer running")
+	}
+
+	holder, err := lock.ForceUnlock(client)
+	if err != nil {
+		return err
+	}
+	if holder == nil {
+		fmt.Println("[unlock] the cluster is not locked")
+		return nil
+	}
+	fmt.Printf("[unlock] removed the cluster lock held by %s\n", holder)
+	return nil
+}
@@ -0,0 +1,419 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kured"
+	upgradenode "github.com/SUSE/skuba/internal/pkg/skuba/upgrade/node"
+	nodeupgrade "github.com/SUSE/skuba/pkg/skuba/actions/node/upgrade"
+)
+
+const (
+	// DefaultWorkerBatchSize is the default number of worker nodes upgraded
+	// at the same time
+	DefaultWorkerBatchSize = 1
+	// DefaultNodeHealthTimeout is the default time to wait for an upgraded
+	// node to be ready and for its pods to be healthy
+	DefaultNodeHealthTimeout = 10 * time.Minute
+)
+
+// Status of a node at the end of a cluster upgrade
+const (
+	NodeUpgraded = "upgraded"
+	NodeUpToDate = "up-to-date"
+	NodeFailed   = "failed"
+	NodeSkipped  = "skipped"
+)
+
+var (
+	// these are replaced in tests, as node upgrades need an SSH connection
+	// and node versions are read from the static pods of the control plane
+	updateStatus        = upgradenode.UpdateStatus
+	isFirstControlPlane = func(client clientset.Interface, status upgradenode.NodeVersionInfoUpdate) (bool, error) {
+		return status.IsFirstControlPlaneNodeToBeUpgraded(client)
+	}
+	applyNode     = nodeupgrade.Apply
+	upgradeAddons = deployAddonsForCurrentVersion
+
+	nodeHealthInterval = 5 * time.Second
+	// now is replaced in tests, to measure the upgrade duration of the nodes
+	now = time.Now
+)
+
+// ApplyOptions are the options of a rolling cluster upgrade
+type ApplyOptions struct {
+	// WorkerBatchSize is the number of worker nodes upgraded at the same time
+	WorkerBatchSize int
+	// NodeHealthTimeout is the time to wait for an upgraded node to be ready
+	// and for its pods to be healthy
+	NodeHealthTimeout time.Duration
+	// NodeApplyOptions are the options of every node upgrade
+	NodeApplyOptions nodeupgrade.ApplyOptions
+	// NoAddonPrune keeps the objects of the addons that are not in their
+	// manifests anymore
+	NoAddonPrune bool
+	// AddonRolloutTimeout is the time to wait for the workloads of every
+	// upgraded addon to roll out
+	AddonRolloutTimeout time.Duration
+}
+
+// NodeUpgradeResult is the outcome of the upgrade of one node of the cluster
+type NodeUpgradeResult struct {
+	Node     string
+	Role     string
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+// TargetForNode returns the deployment target used to reach a node of the cluster
+type TargetForNode func(nodeName string, role *deployments.Role) (*deployments.Target, error)
+
+// Apply upgrades the whole cluster to the next Kubernetes version. Control
+// plane nodes are upgraded one at a time, starting with the node that runs
+// `kubeadm upgrade apply`, and workers are upgraded in batches afterwards.
+// Every upgraded node has to be ready, with healthy pods, before the next
+// step starts; the first failure stops the upgrade and the remaining nodes
+// are skipped. Addons are upgraded once all the nodes are.
+func Apply(client clientset.Interface, options ApplyOptions, targetForNode TargetForNode) ([]NodeUpgradeResult, error) {
+	batchSize := options.WorkerBatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	nodes, err := kubernetes.GetAllNodes(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the list of nodes")
+	}
+	controlPlanes, workers := []string{}, []string{}
+	targets := map[string]*deployments.Target{}
+	for _, node := range nodes.Items {
+		role := deployments.WorkerRole
+		if kubernetes.IsControlPlane(&node) {
+			role = deployments.MasterRole
+			controlPlanes = append(controlPlanes, node.ObjectMeta.Name)
+		} else {
+			workers = append(workers, node.ObjectMeta.Name)
+		}
+		target, err := targetForNode(node.ObjectMeta.Name, &role)
+		if err != nil {
+			return nil, errors.Wrapf(err, "node %s", node.ObjectMeta.Name)
+		}
+		targets[node.ObjectMeta.Name] = target
+	}
+	sort.Strings(controlPlanes)
+	sort.Strings(workers)
+	controlPlanes, err = controlPlaneUpgradeOrder(client, controlPlanes)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single kured lock for the whole upgrade, so the node upgrades of a
+	// batch do not release it while other nodes are still upgrading
+	kuredWasLocked, err := kured.LockExists(client)
+	if err != nil {
+		return nil, err
+	}
+	if !kuredWasLocked {
+		if err := kured.Lock(client); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := kured.Unlock(client); err != nil {
+				fmt.Printf("[upgrade] could not unlock kured: %v\n", err)
+			}
+		}()
+	}
+	// likewise for the kured reboot file, which node upgrades running
+	// concurrently would otherwise remove and create in any order
+	if kured.RebootFileExists() {
+		if err := kured.RebootFileRemove(); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := kured.RebootFileCreate(); err != nil {
+				fmt.Printf("[upgrade] could not restore the kured reboot file: %v\n", err)
+			}
+		}()
+	}
+	options.NodeApplyOptions.SkipKuredRebootFile = true
+
+	fmt.Printf("[upgrade] upgrading %d control plane and %d worker nodes (worker batch size: %d)\n", len(controlPlanes), len(workers), batchSize)
+
+	results := []NodeUpgradeResult{}
+	failedNode := ""
+	for _, nodeName := range controlPlanes {
+		if failedNode != "" {
+			results = append(results, skippedResult(nodeName, deployments.MasterRole, failedNode))
+			continue
+		}
+		result := upgradeNode(client, nodeName, deployments.MasterRole, targets[nodeName], options)
+		results = append(results, result)
+		if result.Err != nil {
+			failedNode = nodeName
+		}
+	}
+
+	for start := 0; start < len(workers); start += batchSize {
+		end := start + batchSize
+		if end > len(workers) {
+			end = len(workers)
+		}
+		batch := workers[start:end]
+		batchResults := make([]NodeUpgradeResult, len(batch))
+		if failedNode != "" {
+			for i, nodeName := range batch {
+				batchResults[i] = skippedResult(nodeName, deployments.WorkerRole, failedNode)
+			}
+			results = append(results, batchResults...)
+			continue
+		}
+		var wg sync.WaitGroup
+		for i, nodeName := range batch {
+			wg.Add(1)
+			go func(i int, nodeName string) {
+				defer wg.Done()
+				batchResults[i] = upgradeNode(client, nodeName, deployments.WorkerRole, targets[nodeName], options)
+			}(i, nodeName)
+		}
+		wg.Wait()
+		for _, result := range batchResults {
+			if result.Err != nil && failedNode == "" {
+				failedNode = result.Node
+			}
+		}
+		results = append(results, batchResults...)
+	}
+
+	if failedNode != "" {
+		failed := 0
+		for _, result := range results {
+			if result.Status == NodeFailed {
+				failed++
+			}
+		}
+		return results, errors.Errorf("%d out of %d nodes failed to upgrade, the upgrade stopped after node %s failed", failed, len(results), failedNode)
+	}
+
+	fmt.Println("[upgrade] all nodes upgraded, upgrading addons")
+	if err := upgradeAddons(client, addons.ApplyOptions{NoPrune: options.NoAddonPrune, RolloutTimeout: options.AddonRolloutTimeout}); err != nil {
+		return results, errors.Wrap(err, "could not upgrade addons")
+	}
+	fmt.Println("[upgrade] cluster successfully upgraded")
+	return results, nil
+}
+
+// controlPlaneUpgradeOrder moves the control plane node that has to run
+// `kubeadm upgrade apply` first
+func controlPlaneUpgradeOrder(client clientset.Interface, controlPlanes []string) ([]string, error) {
+	for i, nodeName := range controlPlanes {
+		status, err := updateStatus(client, nodeName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get the upgrade status of node %s", nodeName)
+		}
+		if status.IsUpdated() {
+			continue
+		}
+		isFirst, err := isFirstControlPlane(client, status)
+		if err != nil {
+			return nil, err
+		}
+		if isFirst {
+			ordered := append([]string{nodeName}, controlPlanes[:i]...)
+			return append(ordered, controlPlanes[i+1:]...), nil
+		}
+	}
+	return controlPlanes, nil
+}
+
+func upgradeNode(client clientset.Interface, nodeName string, role deployments.Role, target *deployments.Target, options ApplyOptions) (result NodeUpgradeResult) {
+	result = NodeUpgradeResult{Node: nodeName, Role: roleName(role)}
+	start := now()
+	defer func() {
+		result.Duration = now().Sub(start).Round(time.Second)
+	}()
+
+	// the status is read right before the upgrade, as workers only have an
+	// upgrade available once all the control plane nodes are upgraded
+	status, err := updateStatus(client, nodeName)
+	if err != nil {
+		return failedResult(result, errors.Wrap(err, "could not get the upgrade status"))
+	}
+	if status.IsUpdated() {
+		fmt.Printf("[upgrade] %s: up to date\n", nodeName)
+		result.Status = NodeUpToDate
+		return result
+	}
+
+	fmt.Printf("[upgrade] %s: upgrading kubelet from %s to %s\n", nodeName, status.Current.KubeletVersion, status.Update.KubeletVersion)
+	if err := applyNode(client, target, options.NodeApplyOptions); err != nil {
+		return failedResult(result, err)
+	}
+	fmt.Printf("[upgrade] %s: waiting for the node to be ready and its pods to be healthy\n", nodeName)
+	if err := waitForNodeHealth(client, nodeName, status.Update.KubeletVersion, options.NodeHealthTimeout); err != nil {
+		return failedResult(result, err)
+	}
+	fmt.Printf("[upgrade] %s: upgraded\n", nodeName)
+	result.Status = NodeUpgraded
+	return result
+}
+
+func failedResult(result NodeUpgradeResult, err error) NodeUpgradeResult {
+	fmt.Printf("[upgrade] %s: failed: %v\n", result.Node, err)
+	result.Status = NodeFailed
+	result.Err = err
+	return result
+}
+
+func skippedResult(nodeName string, role deployments.Role, failedNode string) NodeUpgradeResult {
+	return NodeUpgradeResult{
+		Node:   nodeName,
+		Role:   roleName(role),
+		Status: NodeSkipped,
+		Err:    errors.Errorf("skipped after node %s failed to upgrade", failedNode),
+	}
+}
+
+func roleName(role deployments.Role) string {
+	if role == deployments.MasterRole {
+		return "master"
+	}
+	return "worker"
+}
+
+// waitForNodeHealth waits for the node to be ready and schedulable with the
+// upgraded kubelet, and for all the pods running on it to be ready
+func waitForNodeHealth(client clientset.Interface, nodeName string, kubeletVersion *version.Version, timeout time.Duration) error {
+	var problems []string
+	err := wait.PollImmediate(nodeHealthInterval, timeout, func() (bool, error) {
+		problems = nodeHealthProblems(client, nodeName, kubeletVersion)
+		return len(problems) == 0, nil
+	})
+	if err != nil {
+		return errors.Errorf("node not healthy after %s: %s", timeout, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func nodeHealthProblems(client clientset.Interface, nodeName string, kubeletVersion *version.Version) []string {
+	node, err := client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return []string{err.Error()}
+	}
+	problems := []string{}
+	ready := false
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
+			ready = true
+		}
+	}
+	if !ready {
+		problems = append(problems, "node is not ready")
+	}
+	if node.Spec.Unschedulable {
+		problems = append(problems, "node is cordoned")
+	}
+	if currentVersion, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion); err != nil || !currentVersion.AtLeast(kubeletVersion) {
+		problems = append(problems, fmt.Sprintf("kubelet reports version %q, expected %s", node.Status.NodeInfo.KubeletVersion, kubeletVersion))
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != nodeName || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if !kubernetes.IsPodReady(&pod) {
+			problems = append(problems, fmt.Sprintf("pod %s/%s is not ready%s", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, podWaitingReasons(&pod)))
+		}
+	}
+	return problems
+}
+
+func podWaitingReasons(pod *v1.Pod) string {
+	reasons := []string{}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason != "" {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", containerStatus.Name, containerStatus.State.Waiting.Reason))
+		}
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(reasons, ", "))
+}
+
+// deployAddonsForCurrentVersion deploys the addons of the cluster version the
+// control plane was upgraded to
+func deployAddonsForCurrentVersion(client clientset.Interface, options addons.ApplyOptions) error {
+	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
+	if err != nil {
+		return err
+	}
+	addonConfiguration, err := addons.NewAddonConfiguration(client, currentClusterVersion)
+	if err != nil {
+		return err
+	}
+	match, err := addons.CheckLocalAddonsBaseManifests(addonConfiguration)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return errors.Errorf("the local addons configuration is out of date for %s, run `skuba addon refresh localconfig` and `skuba addon upgrade apply`", currentClusterVersion)
+	}
+	return addons.DeployAddons(client, addonConfiguration, options)
+}
+
+// PrintApplySummary writes a table with the outcome of every node upgrade
+func PrintApplySummary(out io.Writer, results []NodeUpgradeResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tROLE\tSTATUS\tDURATION\tERROR")
+	for _, result := range results {
+		errorMessage := ""
+		if result.Err != nil {
+			errorMessage = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Node, result.Role, result.Status, result.Duration, errorMessage)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package upgrade
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/addons"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+	upgradenode "github.com/SUSE/skuba/internal/pkg/skuba/upgrade/node"
+	nodeupgrade "github.com/SUSE/skuba/pkg/skuba/actions/node/upgrade"
+)
+
+func upgradedNode(name string, controlPlane bool) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			NodeInfo:   v1.NodeSystemInfo{KubeletVersion: "v1.18.10"},
+		},
+	}
+	if controlPlane {
+		node.ObjectMeta.Labels["node-role.kubernetes.io/master"] = ""
+	}
+	return node
+}
+
+func TestApply(t *testing.T) {
+	defer func(originalUpdateStatus func(clientset.Interface, string) (upgradenode.NodeVersionInfoUpdate, error),
+		originalIsFirstControlPlane func(clientset.Interface, upgradenode.NodeVersionInfoUpdate) (bool, error),
+		originalApplyNode func(clientset.Interface, *deployments.Target, nodeupgrade.ApplyOptions) error,
+		originalUpgradeAddons func(clientset.Interface, addons.ApplyOptions) error) {
+		updateStatus, isFirstControlPlane, applyNode, upgradeAddons = originalUpdateStatus, originalIsFirstControlPlane, originalApplyNode, originalUpgradeAddons
+	}(updateStatus, isFirstControlPlane, applyNode, upgradeAddons)
+	defer func(originalNow func() time.Time) { now = originalNow }(now)
+	nodeHealthInterval = time.Millisecond
+
+	// every reading of the clock is a minute later than the previous one
+	var clockMutex sync.Mutex
+	clock := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		clockMutex.Lock()
+		defer clockMutex.Unlock()
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	tests := []struct {
+		name               string
+		upToDate           []string
+		failing            string
+		unhealthyPod       string
+		kuredDisabled      bool
+		expectedStatuses   map[string]string
+		expectedAddons     bool
+		expectedErrMessage string
+	}{
+		{
+			name:     "upgrades control planes first and then workers in batches",
+			upToDate: []string{"worker-3"},
+			expectedStatuses: map[string]string{
+				"master-1": NodeUpgraded, "master-2": NodeUpgraded, "master-3": NodeUpgraded,
+				"worker-1": NodeUpgraded, "worker-2": NodeUpgraded, "worker-3": NodeUpToDate,
+			},
+			expectedAddons: true,
+		},
+		{
+			name:          "upgrades the nodes when kured is disabled",
+			kuredDisabled: true,
+			expectedStatuses: map[string]string{
+				"master-1": NodeUpgraded, "master-2": NodeUpgraded, "master-3": NodeUpgraded,
+				"worker-1": NodeUpgraded, "worker-2": NodeUpgraded, "worker-3": NodeUpgraded,
+			},
+			expectedAddons: true,
+		},
+		{
+			name:    "stops at the first control plane failure",
+			failing: "master-2",
+			expectedStatuses: map[string]string{
+				"master-1": NodeSkipped, "master-2": NodeFailed, "master-3": NodeSkipped,
+				"worker-1": NodeSkipped, "worker-2": NodeSkipped, "worker-3": NodeSkipped,
+			},
+			expectedErrMessage: "1 out of 6 nodes failed to upgrade, the upgrade stopped after node master-2 failed",
+		},
+		{
+			name:         "stops when the pods of an upgraded worker are not healthy",
+			unhealthyPod: "worker-1",
+			expectedStatuses: map[string]string{
+				"master-1": NodeUpgraded, "master-2": NodeUpgraded, "master-3": NodeUpgraded,
+				"worker-1": NodeFailed, "worker-2": NodeUpgraded, "worker-3": NodeSkipped,
+			},
+			expectedErrMessage: "1 out of 6 nodes failed to upgrade, the upgrade stopped after node worker-1 failed",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{
+				upgradedNode("master-1", true),
+				upgradedNode("master-2", true),
+				upgradedNode("master-3", true),
+				upgradedNode("worker-1", false),
+				upgradedNode("worker-2", false),
+				upgradedNode("worker-3", false),
+				&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+					Spec:       v1.PodSpec{NodeName: tt.unhealthyPod},
+					Status: v1.PodStatus{
+						Phase:      v1.PodRunning,
+						Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}},
+					},
+				},
+			}
+			if !tt.kuredDisabled {
+				objects = append(objects, &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "kured", Namespace: metav1.NamespaceSystem}})
+			}
+			client := fake.NewSimpleClientset(objects...)
+
+			var mutex sync.Mutex
+			upgraded := []string{}
+			updateStatus = func(client clientset.Interface, nodeName string) (upgradenode.NodeVersionInfoUpdate, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				current := version.MustParseSemantic("1.17.13")
+				for _, name := range append(upgraded, tt.upToDate...) {
+					if name == nodeName {
+						current = version.MustParseSemantic("1.18.10")
+					}
+				}
+				node := upgradedNode(nodeName, strings.HasPrefix(nodeName, "master"))
+				return upgradenode.NodeVersionInfoUpdate{
+					Current: kubernetes.NodeVersionInfo{Node: node, KubeletVersion: current, ContainerRuntimeVersion: current, APIServerVersion: current, ControllerManagerVersion: current, SchedulerVersion: current, EtcdVersion: current},
+					Update:  kubernetes.NodeVersionInfo{Node: node, KubeletVersion: version.MustParseSemantic("1.18.10"), ContainerRuntimeVersion: version.MustParseSemantic("1.18.10"), APIServerVersion: version.MustParseSemantic("1.18.10"), ControllerManagerVersion: version.MustParseSemantic("1.18.10"), SchedulerVersion: version.MustParseSemantic("1.18.10"), EtcdVersion: version.MustParseSemantic("1.18.10")},
+				}, nil
+			}
+			isFirstControlPlane = func(client clientset.Interface, status upgradenode.NodeVersionInfoUpdate) (bool, error) {
+				return status.Current.Node.ObjectMeta.Name == "master-2", nil
+			}
+			applyNode = func(client clientset.Interface, target *deployments.Target, options nodeupgrade.ApplyOptions) error {
+				if !options.SkipKuredRebootFile {
+					t.Errorf("expected the kured reboot file to be left to the cluster upgrade on node %s", target.Nodename)
+				}
+				if target.Nodename == tt.failing {
+					return errors.New("kubeadm upgrade failed")
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				upgraded = append(upgraded, target.Nodename)
+				return nil
+			}
+			addonsUpgraded := false
+			upgradeAddons = func(client clientset.Interface, options addons.ApplyOptions) error {
+				addonsUpgraded = true
+				return nil
+			}
+
+			options := ApplyOptions{WorkerBatchSize: 2, NodeHealthTimeout: 20 * time.Millisecond}
+			results, err := Apply(client, options, func(nodeName string, role *deployments.Role) (*deployments.Target, error) {
+				return &deployments.Target{Nodename: nodeName, Role: role}, nil
+			})
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("expected error %q, got %v", tt.expectedErrMessage, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			statuses := map[string]string{}
+			for _, result := range results {
+				statuses[result.Node] = result.Status
+				if result.Status != NodeSkipped && result.Duration < time.Minute {
+					t.Errorf("expected the upgrade duration of node %s to be measured, got %s", result.Node, result.Duration)
+				}
+			}
+			if !reflect.DeepEqual(statuses, tt.expectedStatuses) {
+				t.Errorf("expected statuses %v, got %v", tt.expectedStatuses, statuses)
+			}
+			if addonsUpgraded != tt.expectedAddons {
+				t.Errorf("expected addons upgraded to be %t", tt.expectedAddons)
+			}
+			if len(upgraded) >= 3 {
+				if !reflect.DeepEqual(upgraded[:3], []string{"master-2", "master-1", "master-3"}) {
+					t.Errorf("expected control planes to be upgraded first, starting with master-2, got %v", upgraded)
+				}
+				workers := append([]string{}, upgraded[3:]...)
+				sort.Strings(workers)
+				if len(workers) > 0 && workers[0] != "worker-1" {
+					t.Errorf("expected the first batch of workers to be upgraded, got %v", upgraded)
+				}
+			}
+		})
+	}
+}
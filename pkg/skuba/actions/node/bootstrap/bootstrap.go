@@ -34,6 +34,8 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
+	"github.com/SUSE/skuba/internal/pkg/skuba/registries"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	"github.com/SUSE/skuba/pkg/skuba"
 )
 
@@ -53,13 +55,34 @@ func Bootstrap(bootstrapConfiguration deployments.BootstrapConfiguration, target
 	if err != nil {
 		return errors.Wrapf(err, "could not parse %s file", skuba.KubeadmInitConfFile())
 	}
+	versionToDeploy, err := version.ParseSemantic(initConfiguration.KubernetesVersion)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse semantic version: %s", initConfiguration.KubernetesVersion)
+	}
+	target.SetJournalTargetVersion(versionToDeploy.String())
+
+	// the image repository set by `skuba cluster init --image-repository`
+	imageRepository, err := skubaconfig.ReadLocalImageRepository()
+	if err != nil {
+		return err
+	}
+
+	bootstrapConfiguration.Registries, err = registries.Render()
+	if err != nil {
+		return err
+	}
 
 	if !coreBootstrapDone {
-		if err := coreBootstrap(initConfiguration, bootstrapConfiguration, target); err != nil {
+		if err := coreBootstrap(initConfiguration, bootstrapConfiguration, target, imageRepository); err != nil {
 			return err
 		}
 	}
 
+	if target.DryRun {
+		fmt.Printf("[bootstrap] dry run: skipping secrets download and core add-ons deployment on node %q\n", target.Target)
+		return nil
+	}
+
 	if err := downloadSecrets(target); err != nil {
 		return err
 	}
@@ -70,15 +93,16 @@ func Bootstrap(bootstrapConfiguration deployments.BootstrapConfiguration, target
 		return err
 	}
 
-	fmt.Printf("[bootstrap] deploying core add-ons on node %q\n", target.Target)
-	versionToDeploy, err := version.ParseSemantic(initConfiguration.KubernetesVersion)
-	if err != nil {
-		return errors.Wrapf(err, "could not parse semantic version: %s", initConfiguration.KubernetesVersion)
+	if err := skubaconfig.SaveImageRepository(clientSet, imageRepository); err != nil {
+		return errors.Wrap(err, "could not record the image repository")
 	}
+
+	fmt.Printf("[bootstrap] deploying core add-ons on node %q\n", target.Target)
 	addonConfiguration := addons.AddonConfiguration{
-		ClusterVersion: versionToDeploy,
-		ControlPlane:   initConfiguration.ControlPlaneEndpoint,
-		ClusterName:    initConfiguration.ClusterName,
+		ClusterVersion:  versionToDeploy,
+		ControlPlane:    initConfiguration.ControlPlaneEndpoint,
+		ClusterName:     initConfiguration.ClusterName,
+		ImageRepository: imageRepository,
 	}
 	// re-render all addons base manifest
 	for addonName, addon := range addons.Addons {
@@ -86,8 +110,13 @@ func Bootstrap(bootstrapConfiguration deployments.BootstrapConfiguration, target
 			return errors.Wrapf(err, "failed to refresh addon %s manifest", string(addonName))
 		}
 	}
-	dryRun := false
-	if err := addons.DeployAddons(clientSet, addonConfiguration, dryRun); err != nil {
+	// the addon workloads are not waited for, as they may not be scheduled
+	// until more nodes join the cluster
+	if err := addons.DeployAddons(clientSet, addonConfiguration, addons.ApplyOptions{}); err != nil {
+		return err
+	}
+
+	if err := target.FinishJournal(); err != nil {
 		return err
 	}
 
@@ -97,7 +126,7 @@ func Bootstrap(bootstrapConfiguration deployments.BootstrapConfiguration, target
 
 // Takes care of bootstrapping the core components of the nodes, containerized add-ons are
 // not handled here.
-func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapConfiguration deployments.BootstrapConfiguration, target *deployments.Target) error {
+func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapConfiguration deployments.BootstrapConfiguration, target *deployments.Target, imageRepository string) error {
 	versionToDeploy := version.MustParseSemantic(initConfiguration.KubernetesVersion)
 
 	if err := target.Apply(deployments.KubernetesBaseOSConfiguration{
@@ -107,7 +136,7 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 	}
 
 	fmt.Println("[bootstrap] updating init configuration with target information")
-	if err := node.AddTargetInformationToInitConfigurationWithClusterVersion(target, initConfiguration, versionToDeploy); err != nil {
+	if err := node.AddTargetInformationToInitConfigurationWithClusterVersion(target, initConfiguration, versionToDeploy, imageRepository); err != nil {
 		return errors.Wrap(err, "unable to add target information to init configuration")
 	}
 
@@ -119,9 +148,13 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 		return errors.Wrap(err, "could not marshal configuration")
 	}
 
-	fmt.Println("[bootstrap] writing init configuration for node")
-	if err := ioutil.WriteFile(skuba.KubeadmInitConfFile(), finalInitConfigurationContents, 0600); err != nil {
-		return errors.Wrap(err, "error writing init configuration")
+	if target.DryRun {
+		fmt.Println("[bootstrap] dry run: skipping writing init configuration for node")
+	} else {
+		fmt.Println("[bootstrap] writing init configuration for node")
+		if err := ioutil.WriteFile(skuba.KubeadmInitConfFile(), finalInitConfigurationContents, 0600); err != nil {
+			return errors.Wrap(err, "error writing init configuration")
+		}
 	}
 
 	var criSetup string
@@ -132,35 +165,21 @@ func coreBootstrap(initConfiguration *kubeadmapi.InitConfiguration, bootstrapCon
 	}
 
 	// bsc#1155810: generate cluster-wide kubelet root certificate
-	if err := kubernetes.GenerateKubeletRootCert(); err != nil {
-		return err
+	if !target.DryRun {
+		if err := kubernetes.GenerateKubeletRootCert(); err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("[bootstrap] applying init configuration to node")
-	err = target.Apply(
-		bootstrapConfiguration,
-		"kernel.check-modules",
-		"kubeadm.reset",
-		"kubernetes.bootstrap.upload-secrets",
-		"kernel.load-modules",
-		"kernel.configure-parameters",
-		"firewalld.disable",
-		"apparmor.start",
-		criSetup,
-		"cri.start",
-		"oidc.ca.upload",
-		"kubelet.rootcert.upload",
-		"kubelet.servercert.create-and-upload",
-		"kubelet.configure",
-		"kubelet.enable",
-		"kubeadm.init",
-		"skuba-update.start.no-block",
-		"skuba-update-timer.enable",
-	)
+	err = target.Apply(bootstrapConfiguration, coreBootstrapStates(criSetup)...)
 	if err != nil {
 		return err
 	}
 
+	if target.DryRun {
+		return nil
+	}
 	fmt.Printf("[bootstrap] successfully bootstrapped core components on node %q with Kubernetes: %q\n", target.Target, versionToDeploy.String())
 	return nil
 }
@@ -183,3 +202,28 @@ func downloadSecrets(target *deployments.Target) error {
 
 	return nil
 }
+
+// coreBootstrapStates returns the states bootstrapping the core components on
+// the node, configuring the container runtime with criSetup
+func coreBootstrapStates(criSetup string) []string {
+	return []string{
+		"kernel.check-modules",
+		"kubeadm.reset",
+		"kubernetes.bootstrap.upload-secrets",
+		"kernel.load-modules",
+		"kernel.configure-parameters",
+		"firewalld.disable",
+		"apparmor.start",
+		criSetup,
+		"cri.registries",
+		"cri.start",
+		"oidc.ca.upload",
+		"kubelet.rootcert.upload",
+		"kubelet.servercert.create-and-upload",
+		"kubelet.configure",
+		"kubelet.enable",
+		"kubeadm.init",
+		"skuba-update.start.no-block",
+		"skuba-update-timer.enable",
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package node
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments/ssh"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+// nopExecutor runs no command, every read only command succeeds without any
+// output
+type nopExecutor struct{}
+
+func (nopExecutor) Execute(silent bool, stdin io.Reader, command string) (string, string, error) {
+	return "", "", nil
+}
+
+func TestDryRunWithJournal(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "skuba-bootstrap")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	// a fresh cluster definition folder, where the kubelet certificate
+	// authority has not been generated yet
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("could not change to temporary directory: %v", err)
+	}
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+
+	role := deployments.MasterRole
+	d := &deployments.Target{
+		Target:   "my-master",
+		Nodename: "my-master",
+		Role:     &role,
+		DryRun:   true,
+	}
+	d.Actionable = ssh.NewExecutorTarget(d, nopExecutor{})
+	journalPath := filepath.Join(tmpDir, skuba.NodeJournalFile(d.Target, deployments.BootstrapOperation))
+	d.Journal, err = deployments.NewJournal(journalPath, deployments.BootstrapOperation, true)
+	if err != nil {
+		t.Fatalf("unexpected error creating journal: %v", err)
+	}
+
+	states := coreBootstrapStates("cri.configure")
+	var applyErr error
+	output := captureOutput(func() {
+		applyErr = d.Apply(deployments.BootstrapConfiguration{}, states...)
+	})
+
+	for _, state := range states {
+		if !strings.Contains(output, fmt.Sprintf("[dry-run] my-master: state %s\n", state)) {
+			t.Errorf("expected state %s to be planned, got output:\n%s", state, output)
+		}
+	}
+	for _, state := range []string{"kubelet.rootcert.upload", "kubelet.servercert.create-and-upload", "kubeadm.init"} {
+		if applyErr != nil && strings.Contains(applyErr.Error(), fmt.Sprintf("state %s:", state)) {
+			t.Errorf("expected state %s to be planned without the files generated when applying the states, got %v", state, applyErr)
+		}
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("expected no state to be recorded in dry run mode, got %v", err)
+	}
+	if _, err := os.Stat(skuba.PkiDir()); !os.IsNotExist(err) {
+		t.Errorf("expected no certificate to be generated in dry run mode, got %v", err)
+	}
+}
+
+func captureOutput(f func()) string {
+	stdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	outChan := make(chan string)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		outChan <- string(out)
+	}()
+	f()
+	w.Close()
+	os.Stdout = stdout
+	return <-outChan
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package join
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/pkg/skuba/actions/validate"
+)
+
+// InventoryNode describes a node to be joined to the cluster
+type InventoryNode struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Role    string `json:"role"`
+	User    string `json:"user,omitempty"`
+}
+
+// Inventory is the list of nodes to be joined to the cluster
+type Inventory struct {
+	Nodes []InventoryNode `json:"nodes"`
+}
+
+// InventoryJoinOptions holds the settings shared by all the joins of an inventory
+type InventoryJoinOptions struct {
+	// Concurrency is the maximum number of nodes being joined at the same time
+	Concurrency      int
+	KubeadmExtraArgs map[string]string
+}
+
+// InventoryJoinResult is the outcome of joining one node of the inventory
+type InventoryJoinResult struct {
+	Node     InventoryNode
+	Duration time.Duration
+	// Exists is set when a node with the same name is already part of the
+	// cluster, so it was not joined
+	Exists  bool
+	Skipped bool
+	Err     error
+}
+
+// Status returns the outcome of the join as shown in the summary
+func (result InventoryJoinResult) Status() string {
+	switch {
+	case result.Exists:
+		return "exists"
+	case result.Skipped:
+		return "skipped"
+	case result.Err != nil:
+		return "failed"
+	}
+	return "joined"
+}
+
+// TargetForNode returns the deployment target used to reach a node of the inventory
+type TargetForNode func(node InventoryNode, role *deployments.Role) (*deployments.Target, error)
+
+// joinNode is the function used to join every node; it can be replaced in tests
+var joinNode = Join
+
+// LoadInventory reads and validates an inventory file
+func LoadInventory(path string) (*Inventory, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read inventory file %s", path)
+	}
+	inventory := &Inventory{}
+	if err := yaml.UnmarshalStrict(contents, inventory); err != nil {
+		return nil, errors.Wrapf(err, "could not parse inventory file %s", path)
+	}
+	if err := inventory.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid inventory file %s", path)
+	}
+	return inventory, nil
+}
+
+// Validate checks that every node of the inventory is well formed and unique
+func (i *Inventory) Validate() error {
+	if len(i.Nodes) == 0 {
+		return errors.New("no nodes defined")
+	}
+	names := map[string]bool{}
+	addresses := map[string]bool{}
+	for _, node := range i.Nodes {
+		if err := validate.NodeName(node.Name); err != nil {
+			return err
+		}
+		if node.Address == "" {
+			return errors.Errorf("node %q has no address", node.Name)
+		}
+		if _, err := deployments.GetRoleFromString(node.Role); err != nil {
+			return errors.Wrapf(err, "node %q", node.Name)
+		}
+		if names[node.Name] {
+			return errors.Errorf("node %q is defined more than once", node.Name)
+		}
+		if addresses[node.Address] {
+			return errors.Errorf("address %q is used by more than one node", node.Address)
+		}
+		names[node.Name] = true
+		addresses[node.Address] = true
+	}
+	return nil
+}
+
+// JoinInventory joins all the nodes of the inventory to the cluster. Control
+// plane nodes are joined one at a time, as every join changes the etcd
+// membership, while workers are joined concurrently up to the configured limit.
+// A control plane failure skips the remaining control plane nodes. Nodes that
+// are already part of the cluster are reported and left untouched.
+func JoinInventory(client clientset.Interface, inventory *Inventory, options InventoryJoinOptions, targetForNode TargetForNode) ([]InventoryJoinResult, error) {
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]InventoryJoinResult, len(inventory.Nodes))
+	masters := []int{}
+	workers := []int{}
+	for i, node := range inventory.Nodes {
+		results[i].Node = node
+		role, err := deployments.GetRoleFromString(node.Role)
+		if err != nil {
+			return nil, err
+		}
+		if role == deployments.MasterRole {
+			masters = append(masters, i)
+		} else {
+			workers = append(workers, i)
+		}
+	}
+
+	fmt.Printf("[join] joining %d control plane and %d worker nodes (concurrency: %d)\n", len(masters), len(workers), concurrency)
+
+	slots := make(chan struct{}, concurrency)
+	join := func(i int) {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+
+		node := inventory.Nodes[i]
+		role := deployments.MustGetRoleFromString(node.Role)
+		joinConfiguration := deployments.JoinConfiguration{
+			Role:             role,
+			KubeadmExtraArgs: options.KubeadmExtraArgs,
+		}
+		_, err := client.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+		if err == nil {
+			results[i].Exists = true
+			fmt.Printf("[join] %s (%s): a node with the same name already exists in the cluster, skipping\n", node.Name, node.Address)
+			return
+		}
+		if !apierrors.IsNotFound(err) {
+			results[i].Err = errors.Wrap(err, "could not check whether the node already exists in the cluster")
+			fmt.Printf("[join] %s (%s): failed: %s\n", node.Name, node.Address, results[i].Err)
+			return
+		}
+		fmt.Printf("[join] %s (%s): joining as %s\n", node.Name, node.Address, node.Role)
+		start := time.Now()
+		target, err := targetForNode(node, &role)
+		if err == nil {
+			err = joinNode(client, joinConfiguration, target)
+		}
+		results[i].Duration = time.Since(start).Round(time.Second)
+		results[i].Err = err
+		if err != nil {
+			fmt.Printf("[join] %s (%s): failed after %s: %s\n", node.Name, node.Address, results[i].Duration, err)
+		} else {
+			fmt.Printf("[join] %s (%s): joined in %s\n", node.Name, node.Address, results[i].Duration)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var failedMaster string
+		for _, i := range masters {
+			if failedMaster != "" {
+				results[i].Skipped = true
+				results[i].Err = errors.Errorf("skipped after control plane node %q failed to join", failedMaster)
+				continue
+			}
+			join(i)
+			if results[i].Err != nil {
+				failedMaster = inventory.Nodes[i].Name
+			}
+		}
+	}()
+	for _, i := range workers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			join(i)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, errors.Errorf("%d out of %d nodes failed to join", failed, len(results))
+	}
+	return results, nil
+}
+
+// PrintInventorySummary writes a table with the outcome of every node join
+func PrintInventorySummary(out io.Writer, results []InventoryJoinResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tADDRESS\tROLE\tSTATUS\tDURATION\tERROR")
+	for _, result := range results {
+		errorMessage := ""
+		if result.Err != nil {
+			errorMessage = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Node.Name, result.Node.Address, result.Node.Role, result.Status(), result.Duration, errorMessage)
+	}
+	_ = w.Flush()
+}
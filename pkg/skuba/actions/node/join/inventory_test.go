@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package join
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	ktest "k8s.io/client-go/testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestLoadInventory(t *testing.T) {
+	tests := []struct {
+		name          string
+		contents      string
+		expectedNodes int
+		expectedErr   string
+	}{
+		{
+			name: "valid inventory",
+			contents: `
+nodes:
+- name: master-1
+  address: 10.0.0.1
+  role: master
+- name: worker-1
+  address: 10.0.0.2
+  role: worker
+  user: sles
+`,
+			expectedNodes: 2,
+		},
+		{
+			name:        "empty inventory",
+			contents:    "nodes: []",
+			expectedErr: "no nodes defined",
+		},
+		{
+			name: "invalid role",
+			contents: `
+nodes:
+- name: worker-1
+  address: 10.0.0.2
+  role: minion
+`,
+			expectedErr: `invalid role provided: "minion"`,
+		},
+		{
+			name: "missing address",
+			contents: `
+nodes:
+- name: worker-1
+  role: worker
+`,
+			expectedErr: `node "worker-1" has no address`,
+		},
+		{
+			name: "duplicated name",
+			contents: `
+nodes:
+- name: worker-1
+  address: 10.0.0.2
+  role: worker
+- name: worker-1
+  address: 10.0.0.3
+  role: worker
+`,
+			expectedErr: `node "worker-1" is defined more than once`,
+		},
+		{
+			name: "unknown field",
+			contents: `
+nodes:
+- name: worker-1
+  address: 10.0.0.2
+  role: worker
+  port: 2222
+`,
+			expectedErr: "could not parse inventory file",
+		},
+	}
+
+	tmpDir, err := ioutil.TempDir("", "skuba-inventory")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, "nodes.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0600); err != nil {
+				t.Fatalf("could not write inventory: %v", err)
+			}
+			inventory, err := LoadInventory(path)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(inventory.Nodes) != tt.expectedNodes {
+				t.Errorf("expected %d nodes, got %d", tt.expectedNodes, len(inventory.Nodes))
+			}
+		})
+	}
+}
+
+func TestJoinInventory(t *testing.T) {
+	inventory := &Inventory{
+		Nodes: []InventoryNode{
+			{Name: "master-1", Address: "10.0.0.1", Role: "master"},
+			{Name: "master-2", Address: "10.0.0.2", Role: "master"},
+			{Name: "master-3", Address: "10.0.0.3", Role: "master"},
+			{Name: "worker-1", Address: "10.0.1.1", Role: "worker"},
+			{Name: "worker-2", Address: "10.0.1.2", Role: "worker"},
+			{Name: "worker-3", Address: "10.0.1.3", Role: "worker"},
+			{Name: "worker-4", Address: "10.0.1.4", Role: "worker"},
+		},
+	}
+
+	var mu sync.Mutex
+	joinedMasters := []string{}
+	runningMasters := 0
+	joinNode = func(client clientset.Interface, joinConfiguration deployments.JoinConfiguration, target *deployments.Target) error {
+		if joinConfiguration.Role != deployments.MasterRole {
+			if target.Nodename == "worker-4" {
+				t.Errorf("node %s already in the cluster joined again", target.Nodename)
+			}
+			if target.Nodename == "worker-2" {
+				return errors.New("zypper failed")
+			}
+			return nil
+		}
+		mu.Lock()
+		runningMasters++
+		if runningMasters > 1 {
+			t.Errorf("control plane nodes joined concurrently")
+		}
+		joinedMasters = append(joinedMasters, target.Nodename)
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			runningMasters--
+			mu.Unlock()
+		}()
+		if target.Nodename == "master-2" {
+			return errors.New("etcd member add failed")
+		}
+		return nil
+	}
+	defer func() { joinNode = Join }()
+
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-4"}})
+	results, err := JoinInventory(client, inventory, InventoryJoinOptions{Concurrency: 3}, func(node InventoryNode, role *deployments.Role) (*deployments.Target, error) {
+		return &deployments.Target{Target: node.Address, Nodename: node.Name, Role: role}, nil
+	})
+	if err == nil || err.Error() != "3 out of 7 nodes failed to join" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if strings.Join(joinedMasters, ",") != "master-1,master-2" {
+		t.Errorf("unexpected control plane join order: %v", joinedMasters)
+	}
+
+	expectedStatus := map[string]string{
+		"master-1": "joined",
+		"master-2": "failed",
+		"master-3": "skipped",
+		"worker-1": "joined",
+		"worker-2": "failed",
+		"worker-3": "joined",
+		"worker-4": "exists",
+	}
+	for _, result := range results {
+		status := result.Status()
+		if status != expectedStatus[result.Node.Name] {
+			t.Errorf("expected node %s to be %s, got %s", result.Node.Name, expectedStatus[result.Node.Name], status)
+		}
+	}
+
+	summary := bytes.Buffer{}
+	PrintInventorySummary(&summary, results)
+	if !strings.Contains(summary.String(), "etcd member add failed") {
+		t.Errorf("summary does not report the control plane failure:\n%s", summary.String())
+	}
+}
+
+func TestJoinInventoryNodeLookupError(t *testing.T) {
+	inventory := &Inventory{
+		Nodes: []InventoryNode{
+			{Name: "worker-1", Address: "10.0.1.1", Role: "worker"},
+			{Name: "worker-2", Address: "10.0.1.2", Role: "worker"},
+		},
+	}
+
+	var mu sync.Mutex
+	joined := []string{}
+	joinNode = func(client clientset.Interface, joinConfiguration deployments.JoinConfiguration, target *deployments.Target) error {
+		mu.Lock()
+		defer mu.Unlock()
+		joined = append(joined, target.Nodename)
+		return nil
+	}
+	defer func() { joinNode = Join }()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "nodes", func(action ktest.Action) (bool, runtime.Object, error) {
+		if action.(ktest.GetAction).GetName() == "worker-1" {
+			return true, nil, apierrors.NewUnauthorized("token expired")
+		}
+		return false, nil, nil
+	})
+	results, err := JoinInventory(client, inventory, InventoryJoinOptions{Concurrency: 2}, func(node InventoryNode, role *deployments.Role) (*deployments.Target, error) {
+		return &deployments.Target{Target: node.Address, Nodename: node.Name, Role: role}, nil
+	})
+	if err == nil || err.Error() != "1 out of 2 nodes failed to join" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if strings.Join(joined, ",") != "worker-2" {
+		t.Errorf("expected only worker-2 to be joined, got %v", joined)
+	}
+	for _, result := range results {
+		if result.Node.Name != "worker-1" {
+			continue
+		}
+		if result.Status() != "failed" || result.Err == nil || !strings.Contains(result.Err.Error(), "could not check whether the node already exists in the cluster: token expired") {
+			t.Errorf("expected worker-1 to fail on the node lookup, got %s: %v", result.Status(), result.Err)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -41,10 +42,16 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
+	"github.com/SUSE/skuba/internal/pkg/skuba/registries"
 	"github.com/SUSE/skuba/internal/pkg/skuba/replica"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	"github.com/SUSE/skuba/pkg/skuba"
 )
 
+// clusterUpdateMutex serializes the bootstrap token creation and the replica
+// updates of the nodes joined concurrently from an inventory
+var clusterUpdateMutex sync.Mutex
+
 // Join joins a new machine to the cluster. The role of the machine will be
 // provided by the JoinConfiguration, and will target Target node
 func Join(client clientset.Interface, joinConfiguration deployments.JoinConfiguration, target *deployments.Target) error {
@@ -53,6 +60,17 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		return err
 	}
 
+	// the pause image of the node comes from the image repository of the
+	// cluster
+	joinConfiguration.ImageRepository, err = skubaconfig.GetImageRepository(client)
+	if err != nil {
+		return err
+	}
+	joinConfiguration.Registries, err = registries.Render()
+	if err != nil {
+		return err
+	}
+
 	clusterTooOld, err := currentClusterVersion.Compare("1.18.0")
 	if err != nil {
 		return err
@@ -71,6 +89,7 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		return err
 	}
 
+	target.SetJournalTargetVersion(currentClusterVersion.String())
 	if err := target.Apply(deployments.KubernetesBaseOSConfiguration{
 		CurrentVersion: currentClusterVersion.String(),
 	}, "kubernetes.install-fresh-pkgs"); err != nil {
@@ -104,6 +123,7 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		"firewalld.disable",
 		"apparmor.start",
 		criSetup,
+		"cri.registries",
 		"cri.start",
 		"oidc.ca.upload",
 		"kubelet.rootcert.upload",
@@ -122,6 +142,11 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		return err
 	}
 
+	if target.DryRun {
+		fmt.Printf("[join] dry run: skipping cluster configuration updates for node %q\n", target.Target)
+		return nil
+	}
+
 	if joinConfiguration.Role == deployments.MasterRole {
 		ciliumVersion := kubernetes.AddonVersionForClusterVersion(kubernetes.Cilium, currentClusterVersion).Version
 		if err := cni.CiliumUpdateConfigMap(client, ciliumVersion); err != nil {
@@ -129,11 +154,11 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 		}
 	}
 
-	replicaHelper, err := replica.NewHelper(client)
-	if err != nil {
+	if err := updateReplicas(client); err != nil {
 		return err
 	}
-	if err := replicaHelper.UpdateNodes(); err != nil {
+
+	if err := target.FinishJournal(); err != nil {
 		return err
 	}
 
@@ -141,12 +166,22 @@ func Join(client clientset.Interface, joinConfiguration deployments.JoinConfigur
 	return nil
 }
 
+func updateReplicas(client clientset.Interface) error {
+	clusterUpdateMutex.Lock()
+	defer clusterUpdateMutex.Unlock()
+	replicaHelper, err := replica.NewHelper(client)
+	if err != nil {
+		return err
+	}
+	return replicaHelper.UpdateNodes()
+}
+
 // ConfigPath returns the configuration path for a specific Target; if this file does
 // not exist, it will be created out of the template file
-func ConfigPath(client clientset.Interface, role deployments.Role, target *deployments.Target) (string, error) {
+func ConfigPath(client clientset.Interface, joinConfiguration deployments.JoinConfiguration, target *deployments.Target) (string, error) {
 	configPath := skuba.MachineConfFile(target.Target)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = skuba.TemplatePathForRole(role)
+		configPath = skuba.TemplatePathForRole(joinConfiguration.Role)
 	}
 
 	currentClusterVersion, err := kubeadm.GetCurrentClusterVersion(client)
@@ -154,17 +189,17 @@ func ConfigPath(client clientset.Interface, role deployments.Role, target *deplo
 		return "", errors.Wrap(err, "could not get current cluster version")
 	}
 
-	joinConfiguration, err := node.LoadJoinConfigurationFromFile(configPath)
+	kubeadmJoinConfiguration, err := node.LoadJoinConfigurationFromFile(configPath)
 	if err != nil {
 		return "", errors.Wrap(err, "error parsing configuration")
 	}
-	if err := addFreshTokenToJoinConfiguration(client, target.Target, joinConfiguration); err != nil {
+	if err := addFreshTokenToJoinConfiguration(client, target.Target, kubeadmJoinConfiguration); err != nil {
 		return "", errors.Wrap(err, "error adding Token to join configuration")
 	}
-	if err := addTargetInformationToJoinConfiguration(target, role, joinConfiguration, currentClusterVersion); err != nil {
+	if err := addTargetInformationToJoinConfiguration(target, kubeadmJoinConfiguration, currentClusterVersion, joinConfiguration.ImageRepository); err != nil {
 		return "", errors.Wrap(err, "error adding target information to join configuration")
 	}
-	finalJoinConfigurationContents, err := kubeadmutil.MarshalToYamlForCodecs(joinConfiguration, schema.GroupVersion{
+	finalJoinConfigurationContents, err := kubeadmutil.MarshalToYamlForCodecs(kubeadmJoinConfiguration, schema.GroupVersion{
 		Group:   "kubeadm.k8s.io",
 		Version: kubeadm.GetKubeadmApisVersion(currentClusterVersion),
 	}, kubeadmscheme.Codecs)
@@ -180,6 +215,8 @@ func ConfigPath(client clientset.Interface, role deployments.Role, target *deplo
 }
 
 func addFreshTokenToJoinConfiguration(client clientset.Interface, target string, joinConfiguration *kubeadmapi.JoinConfiguration) error {
+	clusterUpdateMutex.Lock()
+	defer clusterUpdateMutex.Unlock()
 	if joinConfiguration.Discovery.BootstrapToken == nil {
 		joinConfiguration.Discovery.BootstrapToken = &kubeadmapi.BootstrapTokenDiscovery{}
 	}
@@ -189,14 +226,14 @@ func addFreshTokenToJoinConfiguration(client clientset.Interface, target string,
 	return err
 }
 
-func addTargetInformationToJoinConfiguration(target *deployments.Target, role deployments.Role, joinConfiguration *kubeadmapi.JoinConfiguration, clusterVersion *version.Version) error {
+func addTargetInformationToJoinConfiguration(target *deployments.Target, joinConfiguration *kubeadmapi.JoinConfiguration, clusterVersion *version.Version, imageRepository string) error {
 	if joinConfiguration.NodeRegistration.KubeletExtraArgs == nil {
 		joinConfiguration.NodeRegistration.KubeletExtraArgs = map[string]string{}
 	}
 	joinConfiguration.NodeRegistration.Name = target.Nodename
 	joinConfiguration.NodeRegistration.CRISocket = skuba.CRISocket
 	joinConfiguration.NodeRegistration.KubeletExtraArgs["hostname-override"] = target.Nodename
-	joinConfiguration.NodeRegistration.KubeletExtraArgs["pod-infra-container-image"] = kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, clusterVersion)
+	joinConfiguration.NodeRegistration.KubeletExtraArgs["pod-infra-container-image"] = kubernetes.ComponentContainerImageForClusterVersion(kubernetes.Pause, clusterVersion, imageRepository)
 	isSUSE, err := target.IsSUSEOS()
 	if err != nil {
 		return errors.Wrap(err, "unable to get os info")
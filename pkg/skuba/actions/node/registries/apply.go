@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package registries
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/registries"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+// TargetForNode returns the target used to reach a node of the cluster
+type TargetForNode func(nodeName string) (*deployments.Target, error)
+
+// NodeApplyResult is the outcome of applying the registries configuration on
+// one node
+type NodeApplyResult struct {
+	Node string
+	Err  error
+}
+
+// Apply renders the registries configuration of the cluster definition
+// folder on the target, or removes it when there is none, and reloads crio
+func Apply(target *deployments.Target) error {
+	configuration, err := registries.Render()
+	if err != nil {
+		return err
+	}
+	return apply(target, configuration)
+}
+
+// ApplyAll applies the registries configuration on every node of the
+// cluster. A failure does not stop the other nodes from being configured.
+func ApplyAll(client clientset.Interface, targetForNode TargetForNode) ([]NodeApplyResult, error) {
+	configuration, err := registries.Render()
+	if err != nil {
+		return nil, err
+	}
+	nodeList, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list the nodes of the cluster")
+	}
+	nodeNames := []string{}
+	for _, node := range nodeList.Items {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	sort.Strings(nodeNames)
+
+	results := []NodeApplyResult{}
+	failed := 0
+	for _, nodeName := range nodeNames {
+		result := NodeApplyResult{Node: nodeName}
+		target, err := targetForNode(nodeName)
+		if err == nil {
+			err = applyOnNode(target, configuration)
+		}
+		if err != nil {
+			result.Err = err
+			failed++
+		}
+		results = append(results, result)
+	}
+	if failed > 0 {
+		return results, errors.Errorf("%d out of %d nodes failed to apply the registries configuration", failed, len(results))
+	}
+	return results, nil
+}
+
+// applyOnNode is replaced in tests, to avoid reaching the nodes
+var applyOnNode = apply
+
+func apply(target *deployments.Target, configuration deployments.RegistriesConfiguration) error {
+	if configuration.RegistriesConf == "" {
+		fmt.Printf("[registries] no registries configuration in %s, removing it from node %q\n", skuba.RegistriesConfFile(), target.Target)
+	} else {
+		fmt.Printf("[registries] applying the registries configuration of %s on node %q\n", skuba.RegistriesConfFile(), target.Target)
+	}
+	if err := target.Apply(configuration, "cri.registries"); err != nil {
+		return err
+	}
+	return target.Apply(nil, "cri.reload")
+}
+
+// PrintApplySummary writes a table with the outcome of every node
+func PrintApplySummary(out io.Writer, results []NodeApplyResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tERROR")
+	for _, result := range results {
+		status := "applied"
+		errorMessage := ""
+		if result.Err != nil {
+			status = "failed"
+			errorMessage = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Node, status, errorMessage)
+	}
+	_ = w.Flush()
+}
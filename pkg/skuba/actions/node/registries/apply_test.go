@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package registries
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/pkg/skuba"
+)
+
+func TestApplyAll(t *testing.T) {
+	defer switchToRegistriesConfiguration(t, "registries:\n- location: registry.suse.com\n  mirrors:\n  - location: mirror.example.com\n")()
+
+	client := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "master-1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-2"}},
+	)
+
+	applied := []string{}
+	defer func(original func(*deployments.Target, deployments.RegistriesConfiguration) error) {
+		applyOnNode = original
+	}(applyOnNode)
+	applyOnNode = func(target *deployments.Target, configuration deployments.RegistriesConfiguration) error {
+		if !strings.Contains(configuration.RegistriesConf, `location = "mirror.example.com"`) {
+			t.Errorf("expected the rendered configuration to be applied on %s, got %q", target.Nodename, configuration.RegistriesConf)
+		}
+		applied = append(applied, target.Nodename)
+		if target.Nodename == "worker-1" {
+			return errors.New("crio is not installed")
+		}
+		return nil
+	}
+
+	results, err := ApplyAll(client, func(nodeName string) (*deployments.Target, error) {
+		if nodeName == "worker-2" {
+			return nil, errors.New("not found in inventory")
+		}
+		return &deployments.Target{Target: nodeName, Nodename: nodeName}, nil
+	})
+	if err == nil || err.Error() != "2 out of 3 nodes failed to apply the registries configuration" {
+		t.Errorf("expected the failures of 2 nodes to be reported, got %v", err)
+	}
+	if strings.Join(applied, ",") != "master-1,worker-1" {
+		t.Errorf("expected the configuration to be applied on master-1 and worker-1, got %v", applied)
+	}
+
+	var out bytes.Buffer
+	PrintApplySummary(&out, results)
+	for _, expected := range []string{"master-1   applied", "worker-1   failed    crio is not installed", "worker-2   failed    not found in inventory"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected summary to contain %q, got %q", expected, out.String())
+		}
+	}
+}
+
+func TestApplyAllWithoutConfiguration(t *testing.T) {
+	defer switchToRegistriesConfiguration(t, "")()
+	if err := os.Remove(skuba.RegistriesConfFile()); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}})
+
+	applied := []string{}
+	defer func(original func(*deployments.Target, deployments.RegistriesConfiguration) error) {
+		applyOnNode = original
+	}(applyOnNode)
+	applyOnNode = func(target *deployments.Target, configuration deployments.RegistriesConfiguration) error {
+		if configuration != (deployments.RegistriesConfiguration{}) {
+			t.Errorf("expected an empty configuration removing the registries configuration, got %v", configuration)
+		}
+		applied = append(applied, target.Nodename)
+		return nil
+	}
+
+	if _, err := ApplyAll(client, func(nodeName string) (*deployments.Target, error) {
+		return &deployments.Target{Target: nodeName, Nodename: nodeName}, nil
+	}); err != nil {
+		t.Errorf("error not expected, but an error was reported (%v)", err)
+	}
+	if strings.Join(applied, ",") != "worker-1" {
+		t.Errorf("expected the configuration to be removed from worker-1, got %v", applied)
+	}
+}
+
+func TestApplyAllInvalidConfiguration(t *testing.T) {
+	defer switchToRegistriesConfiguration(t, "registries:\n- insecure: true\n")()
+
+	_, err := ApplyAll(fake.NewSimpleClientset(), func(nodeName string) (*deployments.Target, error) {
+		t.Errorf("no node expected to be reached, got %s", nodeName)
+		return nil, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "location is required") {
+		t.Errorf("expected an invalid configuration error, got %v", err)
+	}
+}
+
+// switchToRegistriesConfiguration switches to a cluster definition folder
+// with the registries configuration, and returns the function switching back
+func switchToRegistriesConfiguration(t *testing.T, configuration string) func() {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	dir, err := ioutil.TempDir("", "skuba-registries")
+	if err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if err := os.Mkdir(skuba.RegistriesDir(), 0700); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	if err := ioutil.WriteFile(skuba.RegistriesConfFile(), []byte(configuration), 0600); err != nil {
+		t.Fatalf("error not expected, but an error was reported (%v)", err)
+	}
+	return func() {
+		_ = os.Chdir(pwd)
+		_ = os.RemoveAll(dir)
+	}
+}
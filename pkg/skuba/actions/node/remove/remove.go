@@ -20,7 +20,6 @@ package remove
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,10 +30,15 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubeadm"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/replica"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 )
 
+// removeEtcdMember is replaced in tests, as etcdctl jobs never complete with
+// a fake clientset
+var removeEtcdMember = etcd.RemoveMember
+
 // Remove removes a node from the cluster
-func Remove(client clientset.Interface, target string, drainTimeout time.Duration) error {
+func Remove(client clientset.Interface, target string, drainOptions kubernetes.DrainOptions) error {
 	node, err := client.CoreV1().Nodes().Get(context.TODO(), target, metav1.GetOptions{})
 	if err != nil {
 		return errors.Wrapf(err, "[remove-node] could not get node %s", target)
@@ -57,9 +61,14 @@ func Remove(client clientset.Interface, target string, drainTimeout time.Duratio
 			return errors.New("could not remove last master of the cluster")
 		}
 
-		fmt.Printf("[remove-node] removing control plane node %s (drain timeout: %s)\n", targetName, drainTimeout.String())
+		fmt.Printf("[remove-node] removing control plane node %s (drain timeout: %s)\n", targetName, drainOptions.Timeout.String())
 	} else {
-		fmt.Printf("[remove-node] removing worker node %s (drain timeout: %s)\n", targetName, drainTimeout.String())
+		fmt.Printf("[remove-node] removing worker node %s (drain timeout: %s)\n", targetName, drainOptions.Timeout.String())
+	}
+
+	imageRepository, err := skubaconfig.GetImageRepository(client)
+	if err != nil {
+		return err
 	}
 
 	replicaHelper, err := replica.NewHelper(client)
@@ -70,18 +79,21 @@ func Remove(client clientset.Interface, target string, drainTimeout time.Duratio
 		return errors.Wrap(err, "[remove-node] failed to update deployment replicas")
 	}
 
-	if err := kubernetes.DrainNode(client, node, drainTimeout); err != nil {
+	if err := kubernetes.DrainNode(client, node, drainOptions); err != nil {
 		return errors.Wrap(err, "[remove-node] could not drain node")
 	}
 
 	if isControlPlane {
 		fmt.Printf("[remove-node] removing etcd from node %s\n", targetName)
-		if err := etcd.RemoveMember(client, node, currentClusterVersion); err != nil {
-			fmt.Printf("[remove-node] failed removing etcd from node %s, continuing with node removal...\n", targetName)
+		err := removeEtcdMember(client, node, currentClusterVersion)
+		if errors.Cause(err) == etcd.ErrMemberNotFound {
+			fmt.Printf("[remove-node] warning: no etcd member found for node %s; it may have been removed already, continuing with node removal...\n", targetName)
+		} else if err != nil {
+			return errors.Wrapf(err, "[remove-node] could not remove the etcd member of node %s; retry once the etcd cluster is healthy, or remove it with `skuba cluster etcd reconcile`", targetName)
 		}
 	}
 
-	if err := kubernetes.DisarmKubelet(client, node, currentClusterVersion); err != nil {
+	if err := kubernetes.DisarmKubelet(client, node, currentClusterVersion, imageRepository); err != nil {
 		fmt.Printf("[remove-node] failed disarming kubelet: %v; node could be down, continuing with node removal...\n", err)
 	}
 
@@ -23,10 +23,16 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/etcd"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 )
 
 func Test_RemoveNode(t *testing.T) {
@@ -98,18 +104,32 @@ apiServer:
 	test := []struct {
 		name          string
 		target        string
-		executer      []string
 		clientset     *fake.Clientset
+		etcdError     error
 		errorExpected bool
 		errorMessage  string
 	}{
 		{
 			name:          "should remove master from cluster",
 			target:        master2.Name,
-			executer:      []string{master1.Name, master2.Name},
 			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, master2}}),
 			errorExpected: false,
 		},
+		{
+			name:          "should fail when the etcd member of the master is not removed",
+			target:        master2.Name,
+			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, master2}}),
+			etcdError:     errors.New("etcd member 1234 of node master-2 is still part of the etcd cluster"),
+			errorExpected: true,
+			errorMessage:  "[remove-node] could not remove the etcd member of node master-2; retry once the etcd cluster is healthy, or remove it with `skuba cluster etcd reconcile`: etcd member 1234 of node master-2 is still part of the etcd cluster",
+		},
+		{
+			name:          "should remove master without etcd member from cluster",
+			target:        master2.Name,
+			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, master2}}),
+			etcdError:     errors.Wrap(etcd.ErrMemberNotFound, "node master-2"),
+			errorExpected: false,
+		},
 		{
 			name:          "should fail when remove last master from cluster",
 			target:        master1.Name,
@@ -120,7 +140,6 @@ apiServer:
 		{
 			name:          "should fail when remove node does not exist",
 			target:        "not-exist",
-			executer:      []string{master1.Name},
 			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1}}),
 			errorExpected: true,
 			errorMessage:  "[remove-node] could not get node not-exist: nodes \"not-exist\" not found",
@@ -128,35 +147,25 @@ apiServer:
 		{
 			name:          "should remove worker from cluster",
 			target:        worker2.Name,
-			executer:      []string{master1.Name},
 			clientset:     fake.NewSimpleClientset(&corev1.NodeList{Items: []corev1.Node{master1, worker1, worker2}}),
 			errorExpected: false,
 		},
 	}
 
+	defer func(original func(clientset.Interface, *corev1.Node, *version.Version) error) {
+		removeEtcdMember = original
+	}(removeEtcdMember)
+
 	for _, tt := range test {
 		tt := tt // Parallel testing
 		t.Run(tt.name, func(t *testing.T) {
+			removeEtcdMember = func(client clientset.Interface, node *corev1.Node, clusterVersion *version.Version) error {
+				return tt.etcdError
+			}
 			//nolint:errcheck
 			tt.clientset.CoreV1().ConfigMaps(metav1.NamespaceSystem).Create(context.TODO(), cm, metav1.CreateOptions{})
 
 			shaTarget := fmt.Sprintf("%x", sha1.Sum([]byte(tt.target)))
-			shaExecuter := ""
-			for _, executer := range tt.executer {
-				shaExecuter = fmt.Sprintf("%x", sha1.Sum([]byte(executer)))
-				//nolint:errcheck
-				tt.clientset.BatchV1().Jobs(metav1.NamespaceSystem).Create(
-					context.TODO(),
-					&batchv1.Job{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      fmt.Sprintf("caasp-remove-etcd-member-%.10s-from-%.10s", shaTarget, shaExecuter),
-							Namespace: metav1.NamespaceSystem,
-						},
-						Spec:   jobSpec,
-						Status: batchv1.JobStatus{Active: 1},
-					},
-					metav1.CreateOptions{})
-			}
 			//nolint:errcheck
 			tt.clientset.BatchV1().Jobs(metav1.NamespaceSystem).Create(
 				context.TODO(),
@@ -169,7 +178,7 @@ apiServer:
 				},
 				metav1.CreateOptions{})
 
-			err := Remove(tt.clientset, tt.target, 0)
+			err := Remove(tt.clientset, tt.target, kubernetes.DefaultDrainOptions(0))
 			if tt.errorExpected && err == nil {
 				t.Errorf("error expected on %s, but no error reported", tt.name)
 				return
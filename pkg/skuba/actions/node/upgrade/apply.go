@@ -25,7 +25,10 @@ import (
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 	kubeadmconfigutil "k8s.io/kubernetes/cmd/kubeadm/app/util/config"
 
 	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
@@ -33,12 +36,35 @@ import (
 	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
 	"github.com/SUSE/skuba/internal/pkg/skuba/kured"
 	"github.com/SUSE/skuba/internal/pkg/skuba/node"
+	skubaconfig "github.com/SUSE/skuba/internal/pkg/skuba/skuba"
 	upgradenode "github.com/SUSE/skuba/internal/pkg/skuba/upgrade/node"
 	"github.com/SUSE/skuba/pkg/skuba"
+	etcdbackup "github.com/SUSE/skuba/pkg/skuba/actions/cluster/etcd"
 	"github.com/pkg/errors"
 )
 
-func Apply(client clientset.Interface, target *deployments.Target) error {
+// DefaultDrainTimeout is the default time to wait for a node to drain before
+// its upgrade
+const DefaultDrainTimeout = 15 * time.Minute
+
+// ApplyOptions are the options of a node upgrade
+type ApplyOptions struct {
+	// RestConfig is used to stream the etcd snapshot taken before upgrading
+	// the first control plane node
+	RestConfig *rest.Config
+	// SkipEtcdSnapshot disables the etcd snapshot taken before upgrading the
+	// first control plane node
+	SkipEtcdSnapshot bool
+	// EtcdSnapshotDir is the local directory where the etcd snapshot is saved
+	EtcdSnapshotDir string
+	// Drain configures how the node is drained before the upgrade
+	Drain kubernetes.DrainOptions
+	// SkipKuredRebootFile leaves the kured reboot file to the caller, which
+	// handles it once for all the nodes it upgrades concurrently
+	SkipKuredRebootFile bool
+}
+
+func Apply(client clientset.Interface, target *deployments.Target, options ApplyOptions) error {
 	if err := fillTargetWithNodeNameAndRole(client, target); err != nil {
 		return err
 	}
@@ -47,6 +73,12 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 	if err != nil {
 		return err
 	}
+	// the control plane images of the upgrade come from the image repository
+	// of the cluster
+	imageRepository, err := skubaconfig.GetImageRepository(client)
+	if err != nil {
+		return err
+	}
 	currentVersion := currentClusterVersion.String()
 	latestVersion := kubernetes.LatestVersion().String()
 	nodeVersionInfoUpdate, err := upgradenode.UpdateStatus(client, target.Nodename)
@@ -64,6 +96,8 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		return nil
 	}
 
+	target.SetJournalTargetVersion(nodeVersionInfoUpdate.Update.KubeletVersion.String())
+
 	// Refreshing cache info about target OS
 	isSUSE, err := target.IsSUSEOS()
 	if err != nil {
@@ -85,6 +119,24 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		return err
 	}
 
+	// Check if it's the first control plane node to be upgraded
+	isFirstControlPlaneNodeToBeUpgraded, err := nodeVersionInfoUpdate.IsFirstControlPlaneNodeToBeUpgraded(client)
+	if err != nil {
+		return err
+	}
+
+	// Record the packages and kubelet configuration before changing
+	// anything, so a failed upgrade can be rolled back
+	err = target.Apply(deployments.UpgradeBackup{
+		FromVersion:             nodeVersionInfoUpdate.Current.KubeletVersion.String(),
+		ToVersion:               nodeVersionInfoUpdate.Update.KubeletVersion.String(),
+		FirstControlPlane:       isFirstControlPlaneNodeToBeUpgraded,
+		SkubaUpdateTimerEnabled: skubaUpdateWasEnabled,
+	}, "upgrade.backup.save")
+	if err != nil {
+		return err
+	}
+
 	// Disable skuba-update.timer before upgrade
 	if skubaUpdateWasEnabled {
 		err = target.Apply(nil, "skuba-update-timer.disable")
@@ -94,8 +146,8 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 	}
 
 	// Check if a kured reboot file already exists
-	kuredRebootFilePresent := kured.RebootFileExists()
-	if kuredRebootFilePresent {
+	kuredRebootFilePresent := !options.SkipKuredRebootFile && kured.RebootFileExists()
+	if kuredRebootFilePresent && !target.DryRun {
 		err := kured.RebootFileRemove()
 		if err != nil {
 			return err
@@ -110,18 +162,15 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 
 	// Lock kured before upgrade
 	if !kuredWasLocked {
-		if err := kured.Lock(client); err != nil {
+		if target.DryRun {
+			fmt.Println("[dry-run] lock kured")
+		} else if err := kured.Lock(client); err != nil {
 			return err
 		}
 	}
 
 	var initCfgContents []byte
 
-	// Check if it's the first control plane node to be upgraded
-	isFirstControlPlaneNodeToBeUpgraded, err := nodeVersionInfoUpdate.IsFirstControlPlaneNodeToBeUpgraded(client)
-	if err != nil {
-		return err
-	}
 	if isFirstControlPlaneNodeToBeUpgraded {
 		fmt.Println("Fetching the cluster configuration...")
 
@@ -129,7 +178,7 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		if err != nil {
 			return err
 		}
-		if err := node.AddTargetInformationToInitConfigurationWithClusterVersion(target, initCfg, nodeVersionInfoUpdate.Update.APIServerVersion); err != nil {
+		if err := node.AddTargetInformationToInitConfigurationWithClusterVersion(target, initCfg, nodeVersionInfoUpdate.Update.APIServerVersion, imageRepository); err != nil {
 			return errors.Wrap(err, "error adding target information to init configuration")
 		}
 
@@ -141,7 +190,7 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 			initCfg.UseHyperKubeImage = false
 		}
 
-		kubeadm.UpdateClusterConfigurationWithClusterVersion(initCfg, nodeVersionInfoUpdate.Update.APIServerVersion)
+		kubeadm.UpdateClusterConfigurationWithClusterVersion(initCfg, nodeVersionInfoUpdate.Update.APIServerVersion, imageRepository)
 		initCfgContents, err = kubeadmconfigutil.MarshalInitConfigurationToBytes(initCfg, schema.GroupVersion{
 			Group:   "kubeadm.k8s.io",
 			Version: kubeadm.GetKubeadmApisVersion(nodeVersionInfoUpdate.Update.APIServerVersion),
@@ -149,16 +198,27 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		if err != nil {
 			return err
 		}
+
+		if err := snapshotEtcdBeforeUpgrade(client, target, initCfg, currentClusterVersion, nodeVersionInfoUpdate.Update.APIServerVersion, options); err != nil {
+			return err
+		}
 	}
 
-	const drainTimeout = 15 * time.Minute
 	node := nodeVersionInfoUpdate.Current.Node
-	fmt.Printf("Draining node %s (timeout %dmin)\n", target.Nodename, drainTimeout)
-	if err := kubernetes.DrainNode(client, node, drainTimeout); err != nil {
-		return errors.Wrapf(err, "draining node %s", target.Nodename)
+	if target.DryRun {
+		fmt.Printf("[dry-run] drain node %s (timeout %s)\n", target.Nodename, options.Drain.Timeout)
+	} else {
+		fmt.Printf("Draining node %s (timeout %s)\n", target.Nodename, options.Drain.Timeout)
+		if err := kubernetes.DrainNode(client, node, options.Drain); err != nil {
+			return errors.Wrapf(err, "draining node %s", target.Nodename)
+		}
 	}
 
-	fmt.Printf("Performing node %s (%s) upgrade, please wait...\n", target.Nodename, target.Target)
+	if target.DryRun {
+		fmt.Printf("Planning node %s (%s) upgrade, nothing will be changed\n", target.Nodename, target.Target)
+	} else {
+		fmt.Printf("Performing node %s (%s) upgrade, please wait...\n", target.Nodename, target.Target)
+	}
 
 	// Always upload crio files, regardless of the version (allows to enforce
 	// user behavior during patch updates).
@@ -180,15 +240,23 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		}
 	}
 	if isFirstControlPlaneNodeToBeUpgraded {
+		// from now on the cluster configuration is upgraded, so the node
+		// can no longer be rolled back
+		err = target.Apply(nil, "upgrade.backup.kubeadm-applied")
+		if err != nil {
+			return err
+		}
 		err = target.Apply(deployments.UpgradeConfiguration{
 			KubeadmConfigContents: string(initCfgContents),
 		}, "kubeadm.upgrade.apply")
 		if err != nil {
 			return err
 		}
-		err = downloadAdminConf(target)
-		if err != nil {
-			return err
+		if !target.DryRun {
+			err = downloadAdminConf(target)
+			if err != nil {
+				return err
+			}
 		}
 	} else if err := target.Apply(nil, "kubeadm.upgrade.node"); err != nil {
 		return err
@@ -202,8 +270,10 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 	}
 
 	// bsc#1155810: generate cluster-wide kubelet root certificate, and generate/rotate kuberlet server certificate
-	if err := kubernetes.GenerateKubeletRootCert(); err != nil {
-		return err
+	if !target.DryRun {
+		if err := kubernetes.GenerateKubeletRootCert(); err != nil {
+			return err
+		}
 	}
 	err = target.Apply(nil,
 		"kubelet.rootcert.upload",
@@ -224,6 +294,14 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 			return err
 		}
 	}
+	if target.DryRun {
+		if !kuredWasLocked {
+			fmt.Println("[dry-run] unlock kured")
+		}
+		fmt.Printf("[dry-run] uncordon node %s\n", target.Nodename)
+		return nil
+	}
+
 	if !kuredWasLocked {
 		if err := kured.Unlock(client); err != nil {
 			return err
@@ -241,11 +319,46 @@ func Apply(client clientset.Interface, target *deployments.Target) error {
 		return errors.Wrapf(err, "uncordon node %s", target.Nodename)
 	}
 
+	if err := target.FinishJournal(); err != nil {
+		return err
+	}
+
 	fmt.Printf("Node %s (%s) successfully upgraded\n", target.Nodename, target.Target)
 
 	return nil
 }
 
+// snapshotEtcdBeforeUpgrade saves an etcd snapshot named after the versions
+// of the upgrade, so a failed `kubeadm upgrade apply` can be recovered
+func snapshotEtcdBeforeUpgrade(client clientset.Interface, target *deployments.Target, initCfg *kubeadmapi.InitConfiguration, fromVersion, toVersion *version.Version, options ApplyOptions) error {
+	if options.SkipEtcdSnapshot {
+		fmt.Println("Skipping the etcd snapshot before upgrading the control plane")
+		return nil
+	}
+	if initCfg.Etcd.External != nil {
+		fmt.Println("The cluster uses an external etcd, make sure it is backed up before upgrading the control plane")
+		return nil
+	}
+	snapshotDir := options.EtcdSnapshotDir
+	if snapshotDir == "" {
+		snapshotDir = skuba.EtcdSnapshotsDir()
+	}
+	snapshotName := fmt.Sprintf("etcd-snapshot-upgrade-v%s-to-v%s-%s", fromVersion, toVersion, time.Now().UTC().Format("20060102-150405"))
+	if target.DryRun {
+		fmt.Printf("[dry-run] save etcd snapshot %s.db from node %s to %s\n", snapshotName, target.Nodename, snapshotDir)
+		return nil
+	}
+	_, err := etcdbackup.Backup(client, options.RestConfig, etcdbackup.BackupOptions{
+		Node: target.Nodename,
+		Dir:  snapshotDir,
+		Name: snapshotName,
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not save an etcd snapshot before upgrading the control plane, use --skip-etcd-snapshot to upgrade without it")
+	}
+	return nil
+}
+
 func fillTargetWithNodeNameAndRole(client clientset.Interface, target *deployments.Target) error {
 	machineID, err := target.DownloadFileContents("/etc/machine-id")
 	if err != nil {
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package upgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
+
+// Rollback restores the packages and the kubelet configuration recorded on
+// the node before its last upgrade, restarts the services and uncordons the
+// node
+func Rollback(client clientset.Interface, target *deployments.Target) error {
+	if err := fillTargetWithNodeNameAndRole(client, target); err != nil {
+		return err
+	}
+
+	backup, err := getUpgradeBackup(target)
+	if err != nil {
+		return err
+	}
+	if err := checkRollback(target.Nodename, backup); err != nil {
+		return err
+	}
+
+	node, err := client.CoreV1().Nodes().Get(context.TODO(), target.Nodename, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "could not get node %s", target.Nodename)
+	}
+
+	if target.DryRun {
+		fmt.Printf("Planning node %s (%s) rollback to %s, nothing will be changed\n", target.Nodename, target.Target, backup.FromVersion)
+	} else {
+		fmt.Printf("Rolling back node %s (%s) from %s to %s, please wait...\n", target.Nodename, target.Target, backup.ToVersion, backup.FromVersion)
+	}
+
+	err = target.Apply(*backup,
+		"upgrade.rollback",
+		"kubernetes.restart-services",
+		"kubernetes.enable-services",
+	)
+	if err != nil {
+		return err
+	}
+	if backup.SkubaUpdateTimerEnabled {
+		if err := target.Apply(nil, "skuba-update-timer.enable"); err != nil {
+			return err
+		}
+	}
+
+	if target.DryRun {
+		fmt.Printf("[dry-run] uncordon node %s\n", target.Nodename)
+		return nil
+	}
+
+	fmt.Printf("Uncordon node %s\n", target.Nodename)
+	if err := kubernetes.UncordonNode(client, node); err != nil {
+		return errors.Wrapf(err, "uncordon node %s", target.Nodename)
+	}
+
+	if err := target.FinishJournal(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Node %s (%s) successfully rolled back to %s\n", target.Nodename, target.Target, backup.FromVersion)
+
+	return nil
+}
+
+// checkRollback refuses to roll back the first control plane node once
+// `kubeadm upgrade apply` has upgraded the cluster configuration and the
+// control plane components
+func checkRollback(nodeName string, backup *deployments.UpgradeBackup) error {
+	if backup.FirstControlPlane && backup.KubeadmUpgradeApplied {
+		return errors.Errorf("node %s cannot be rolled back: it is the first control plane node upgraded, and `kubeadm upgrade apply` "+
+			"has already upgraded the cluster configuration and the control plane from %s to %s. Rolling back its packages would leave "+
+			"the cluster configuration ahead of its kubelet. Run `skuba node upgrade apply` again to finish the upgrade, or restore the etcd "+
+			"snapshot saved before the upgrade with `skuba cluster etcd restore`", nodeName, backup.FromVersion, backup.ToVersion)
+	}
+	return nil
+}
+
+func getUpgradeBackup(target *deployments.Target) (*deployments.UpgradeBackup, error) {
+	contents, err := target.DownloadFileContents(deployments.UpgradeBackupFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no upgrade recorded on node %s, it cannot be rolled back", target.Nodename)
+	}
+	backup := &deployments.UpgradeBackup{}
+	if err := json.Unmarshal([]byte(contents), backup); err != nil {
+		return nil, errors.Wrapf(err, "could not parse the upgrade recorded on node %s", target.Nodename)
+	}
+	return backup, nil
+}
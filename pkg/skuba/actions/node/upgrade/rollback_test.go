@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2020 SUSE LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package upgrade
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/deployments"
+)
+
+func TestCheckRollback(t *testing.T) {
+	tests := []struct {
+		name         string
+		backup       deployments.UpgradeBackup
+		expectErrMsg string
+	}{
+		{
+			name:   "worker",
+			backup: deployments.UpgradeBackup{FromVersion: "1.17.13", ToVersion: "1.18.10"},
+		},
+		{
+			name:   "first control plane before kubeadm upgrade apply",
+			backup: deployments.UpgradeBackup{FromVersion: "1.17.13", ToVersion: "1.18.10", FirstControlPlane: true},
+		},
+		{
+			name:         "first control plane after kubeadm upgrade apply",
+			backup:       deployments.UpgradeBackup{FromVersion: "1.17.13", ToVersion: "1.18.10", FirstControlPlane: true, KubeadmUpgradeApplied: true},
+			expectErrMsg: "node master-1 cannot be rolled back: it is the first control plane node upgraded",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Parallel testing
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRollback("master-1", &tt.backup)
+			if tt.expectErrMsg == "" {
+				if err != nil {
+					t.Errorf("error not expected, but an error was reported (%v)", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErrMsg) {
+				t.Errorf("returned error (%v) does not contain %q", err, tt.expectErrMsg)
+			}
+		})
+	}
+}
@@ -17,9 +17,34 @@
 
 package actions
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/SUSE/skuba/internal/pkg/skuba/kubernetes"
+)
 
 // AddCommonFlags adds some common flags to a cobra command
 func AddCommonFlags(cmd *cobra.Command, ignorePreflightErrors *string) {
 	cmd.Flags().StringVar(ignorePreflightErrors, "ignore-preflight-errors", "", "Comma separated list of preflight errors to ignore")
 }
+
+// AddResumeFlag adds the flag to resume the states applied on a node from its journal
+func AddResumeFlag(cmd *cobra.Command, resume *bool) {
+	cmd.Flags().BoolVar(resume, "resume", false, "Skip the states already applied with the same configuration by a previous failed run, as recorded in the node journal")
+}
+
+// AddDeploymentFlags adds the flags to apply the states on the machine skuba
+// is running on, and to only print them instead of applying them
+func AddDeploymentFlags(cmd *cobra.Command, local *bool, dryRun *bool) {
+	cmd.Flags().BoolVar(local, "local", false, "Apply the states on the machine skuba is running on, without using SSH")
+	cmd.Flags().BoolVar(dryRun, "dry-run", false, "Print the commands, file uploads and packages of every state instead of applying them")
+}
+
+// AddDrainFlags adds the flags configuring how a node is drained, except for
+// the drain timeout, whose default depends on the command
+func AddDrainFlags(cmd *cobra.Command, options *kubernetes.DrainOptions) {
+	cmd.Flags().IntVar(&options.GracePeriodSeconds, "drain-grace-period", options.GracePeriodSeconds, "Seconds given to every pod to terminate when draining the node; a negative value uses the grace period of the pod")
+	cmd.Flags().BoolVar(&options.DeleteEmptyDirData, "drain-delete-emptydir-data", options.DeleteEmptyDirData, "Drain pods using emptyDir volumes, whose data is lost")
+	cmd.Flags().BoolVar(&options.Force, "drain-force", options.Force, "Drain pods not managed by a controller, which are not recreated anywhere else")
+	cmd.Flags().StringVar(&options.SkipPodSelector, "drain-skip-pod-selector", options.SkipPodSelector, "Label selector of the pods left on the node when draining it")
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/kubernetes/cmd/kubeadm/app/constants"
@@ -43,6 +44,12 @@ func KubeadmInitConfFile() string {
 	return "kubeadm-init.conf"
 }
 
+// ImageRepositoryFile returns the location of the custom image registry
+// given to `skuba cluster init --image-repository`
+func ImageRepositoryFile() string {
+	return "image-repository"
+}
+
 func KubeadmUpgradeConfFile() string {
 	return "kubeadm-upgrade.conf"
 }
@@ -63,6 +70,22 @@ func MachineConfFile(target string) string {
 	return filepath.Join(JoinConfDir(), fmt.Sprintf("%s.conf", target))
 }
 
+// JournalDir returns the location of the journals of the states applied on nodes
+func JournalDir() string {
+	return "journal"
+}
+
+// NodeJournalFile returns the location of the journal of the states applied on target
+// by the operation
+func NodeJournalFile(target, operation string) string {
+	return filepath.Join(JournalDir(), fmt.Sprintf("%s-%s.yaml", target, operation))
+}
+
+// EtcdSnapshotsDir returns the default location of the etcd snapshots
+func EtcdSnapshotsDir() string {
+	return "etcd-snapshots"
+}
+
 func TemplatePathForRole(role deployments.Role) string {
 	switch role {
 	case deployments.MasterRole:
@@ -81,6 +104,23 @@ func ContainersDir() string {
 	return filepath.Join(AddonsDir(), "containers")
 }
 
+// RegistriesDir returns the location of the registries configuration
+func RegistriesDir() string {
+	return "registries"
+}
+
+// RegistriesConfFile returns the registries configuration rendered on the
+// nodes by the cri.registries state
+func RegistriesConfFile() string {
+	return filepath.Join(RegistriesDir(), "registries.yaml")
+}
+
+// RegistriesReadmeFile returns the README.md location for the registries
+// configuration
+func RegistriesReadmeFile() string {
+	return filepath.Join(RegistriesDir(), "README.md")
+}
+
 func CriDir() string {
 	return filepath.Join(AddonsDir(), "cri")
 }
@@ -204,8 +244,12 @@ func AWSReadmeFile() string {
 	return path.Join(AWSDir(), "README.md")
 }
 
-//ImageRepository returns the image registry of the cluster version
-func ImageRepository(clusterVersion *version.Version) string {
+//ImageRepository returns the image registry of the cluster version, or the
+//custom image registry given to `skuba cluster init --image-repository`
+func ImageRepository(clusterVersion *version.Version, customImageRepository string) string {
+	if customImageRepository != "" {
+		return strings.TrimSuffix(customImageRepository, "/")
+	}
 	result, _ := clusterVersion.Compare("1.18.0")
 	if result < 0 {
 		return imageRepositoryV4